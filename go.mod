@@ -1,3 +1,5 @@
 module vpn-web
 
 go 1.24.5
+
+require golang.org/x/crypto v0.48.0
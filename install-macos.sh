@@ -7,9 +7,9 @@ set -e
 APP_NAME="vpn-web"
 INSTALL_DIR="/usr/local/bin"
 WEB_INSTALL_DIR="/usr/local/share/vpn-web"
-VPNSLICE_DIR="$HOME/.bin/vpnslice_python"
-VPNSLICE_SCRIPT="$HOME/.bin/vpnslice"
 SUDOERS_FILE="/etc/sudoers.d/openconnect"
+HELPER_SERVICE="com.vpnweb.helper"
+HELPER_PLIST="/Library/LaunchDaemons/$HELPER_SERVICE.plist"
 
 # Script-Verzeichnis ermitteln
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
@@ -72,7 +72,7 @@ fi
 
 # Pakete installieren
 log "Installing packages..."
-packages=("openconnect" "expect" "python3")
+packages=("openconnect" "wireguard-tools" "openvpn")
 for pkg in "${packages[@]}"; do
     if brew list "$pkg" &>/dev/null; then
         success "$pkg already installed"
@@ -81,132 +81,26 @@ for pkg in "${packages[@]}"; do
     fi
 done
 
-# Python Version prüfen
-PYTHON_VERSION=$(python3 --version 2>&1 | cut -d' ' -f2 | cut -d'.' -f1-2)
-log "Python version: $PYTHON_VERSION"
-
-# vpn-slice installieren - verschiedene Methoden je nach Python-Version
-log "Setting up vpn-slice..."
-mkdir -p "$HOME/.bin" "$VPNSLICE_DIR"
-
-# Aktuelles Verzeichnis merken
-ORIGINAL_DIR="$(pwd)"
-cd "$VPNSLICE_DIR"
-
-# Methode 1: Versuche pipenv (falls erlaubt)
-if command -v pipenv &>/dev/null || pip3 install --user pipenv &>/dev/null; then
-    log "Using pipenv method..."
-    
-    if [[ ! -f "Pipfile" ]]; then
-        cat > Pipfile << 'EOF'
-[[source]]
-url = "https://pypi.org/simple"
-verify_ssl = true
-name = "pypi"
-
-[packages]
-vpn-slice = "*"
-
-[requires]
-python_version = "3"
-EOF
-    fi
-    
-    if pipenv install &>/dev/null; then
-        # Wrapper-Script für pipenv
-        cat > "$VPNSLICE_SCRIPT" << 'EOF'
-#!/bin/zsh
-cd "$HOME/.bin/vpnslice_python"
-pipenv run vpn-slice "$@"
-EOF
-        chmod +x "$VPNSLICE_SCRIPT"
-        success "vpn-slice installed with pipenv"
-    else
-        warn "pipenv failed, trying alternative method..."
-        PIPENV_FAILED=true
-    fi
-else
-    PIPENV_FAILED=true
-fi
-
-# Methode 2: Virtual Environment (Fallback)
-if [[ "$PIPENV_FAILED" == "true" ]]; then
-    log "Using virtual environment method..."
-    
-    # Alte Installation aufräumen
-    rm -rf "$VPNSLICE_DIR"
-    mkdir -p "$VPNSLICE_DIR"
-    cd "$VPNSLICE_DIR"
-    
-    # Virtual Environment erstellen
-    python3 -m venv venv
-    source venv/bin/activate
-    
-    # vpn-slice installieren
-    pip install vpn-slice
-    deactivate
-    
-    # Wrapper-Script für venv
-    cat > "$VPNSLICE_SCRIPT" << 'EOF'
-#!/bin/zsh
-cd "$HOME/.bin/vpnslice_python"
-source venv/bin/activate
-vpn-slice "$@"
-deactivate
-EOF
-    chmod +x "$VPNSLICE_SCRIPT"
-    success "vpn-slice installed with virtual environment"
-fi
-
-# Methode 3: Homebrew (letzter Ausweg)
-if ! "$VPNSLICE_SCRIPT" --version >/dev/null 2>&1; then
-    warn "Python methods failed, trying Homebrew..."
-    
-    if brew install vpn-slice &>/dev/null; then
-        # Direkter Link zu Homebrew-Installation
-        HOMEBREW_VPNSLICE="/opt/homebrew/bin/vpn-slice"
-        if [[ ! -f "$HOMEBREW_VPNSLICE" ]]; then
-            HOMEBREW_VPNSLICE="/usr/local/bin/vpn-slice"
-        fi
-        
-        if [[ -f "$HOMEBREW_VPNSLICE" ]]; then
-            ln -sf "$HOMEBREW_VPNSLICE" "$VPNSLICE_SCRIPT"
-            success "vpn-slice installed via Homebrew"
-        fi
-    fi
-fi
-
-# Zurück zum ursprünglichen Verzeichnis
-cd "$ORIGINAL_DIR"
-
-# Finale Validierung
-if "$VPNSLICE_SCRIPT" --version >/dev/null 2>&1; then
-    success "vpn-slice setup successful"
-    VPN_SLICE_VERSION=$("$VPNSLICE_SCRIPT" --version 2>&1 | head -n1)
-    log "vpn-slice version: $VPN_SLICE_VERSION"
-else
-    error "vpn-slice installation failed. Please install manually: pip3 install --user vpn-slice"
-fi
-
-# Passwordless sudo konfigurieren
+# Passwordless sudo konfigurieren (bei jeder Installation neu, damit die
+# Einträge zu den Kommandos dieser Version passen)
 log "Configuring passwordless sudo..."
-if [[ ! -f "$SUDOERS_FILE" ]]; then
-    sudo tee "$SUDOERS_FILE" << EOF > /dev/null
+SUDOERS_TMP=$(mktemp)
+cat > "$SUDOERS_TMP" << EOF
 # VPN Manager - passwordless sudo
 $(whoami) ALL=(ALL) NOPASSWD: /opt/homebrew/bin/openconnect, /usr/local/bin/openconnect, /usr/bin/openconnect, /usr/sbin/openconnect
+$(whoami) ALL=(ALL) NOPASSWD: /opt/homebrew/bin/wg-quick, /usr/local/bin/wg-quick
+$(whoami) ALL=(ALL) NOPASSWD: /opt/homebrew/sbin/openvpn, /usr/local/sbin/openvpn
 $(whoami) ALL=(ALL) NOPASSWD: /bin/kill, /usr/bin/kill
-$(whoami) ALL=(ALL) NOPASSWD: /usr/bin/killall openconnect
-$(whoami) ALL=(ALL) NOPASSWD: /usr/bin/pkill -f openconnect
-$(whoami) ALL=(ALL) NOPASSWD: /bin/rm /tmp/openconnect.pid
-$(whoami) ALL=(ALL) NOPASSWD: /bin/chmod /etc/hosts
-$(whoami) ALL=(ALL) NOPASSWD: /usr/bin/tee /etc/hosts
-$(whoami) ALL=(ALL) NOPASSWD: /sbin/route add *, /sbin/route delete *
+$(whoami) ALL=(ALL) NOPASSWD: /usr/bin/pkill -TERM -x openconnect, /usr/bin/pkill -KILL -x openconnect
+$(whoami) ALL=(ALL) NOPASSWD: /bin/rm -f /tmp/openconnect.pid
 EOF
-    sudo chmod 440 "$SUDOERS_FILE"
+if sudo visudo -c -f "$SUDOERS_TMP" > /dev/null; then
+    sudo install -m 440 -o root -g wheel "$SUDOERS_TMP" "$SUDOERS_FILE"
     success "Passwordless sudo configured"
 else
-    success "Passwordless sudo already configured"
+    warn "Invalid sudoers entries, $SUDOERS_FILE unchanged"
 fi
+rm -f "$SUDOERS_TMP"
 
 # Binary und Web-Assets installieren mit absoluten Pfaden
 log "Installing binary and web assets..."
@@ -224,6 +118,37 @@ else
     error "Binary not found: $SCRIPT_DIR/$APP_NAME"
 fi
 
+# Privilegierten Helper als LaunchDaemon einrichten (startet openconnect als
+# root, die Weboberfläche braucht dafür kein sudo)
+log "Installing privileged helper..."
+sudo launchctl unload "$HELPER_PLIST" 2>/dev/null || true
+sudo tee "$HELPER_PLIST" << EOF > /dev/null
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>$HELPER_SERVICE</string>
+    <key>ProgramArguments</key>
+    <array>
+        <string>$INSTALL_DIR/$APP_NAME</string>
+        <string>helper</string>
+        <string>--user</string>
+        <string>$(whoami)</string>
+    </array>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <true/>
+    <key>StandardErrorPath</key>
+    <string>/var/log/$APP_NAME-helper.log</string>
+</dict>
+</plist>
+EOF
+sudo chmod 644 "$HELPER_PLIST"
+sudo launchctl load "$HELPER_PLIST"
+success "Helper installed"
+
 # Web-Assets kopieren
 if [[ -d "$SCRIPT_DIR/web" ]]; then
     sudo mkdir -p "$WEB_INSTALL_DIR"
@@ -243,8 +168,9 @@ cat << EOF
   • Binary: $INSTALL_DIR/$APP_NAME
   • Web assets: $WEB_INSTALL_DIR/web
   • OpenConnect: $(which openconnect)
-  • vpn-slice: $VPNSLICE_SCRIPT
+  • vpnc-script: $INSTALL_DIR/$APP_NAME vpnc-script
   • Passwordless sudo: $SUDOERS_FILE
+  • Privileged helper: $HELPER_PLIST
 
 🚀 Usage:
   • Start: $INSTALL_DIR/$APP_NAME
@@ -258,5 +184,5 @@ cat << EOF
 💡 Troubleshooting:
   • Logs: Check terminal output
   • Permissions: Ensure passwordless sudo works
-  • VPN-slice: Test with: $VPNSLICE_SCRIPT --version
+  • Routen: Befehle anzeigen mit reason=connect TUNDEV=utun9 $APP_NAME vpnc-script --dry-run=- 10.0.0.0/8
 EOF
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// CertificatesHandler listet die gespeicherten Zertifikate, Schlüssel und
+// CA-Bundles (GET) oder ordnet eines einem Profil zu (action=select), hebt
+// die Zuordnung auf (action=unselect, kind=pkcs12|pem|key|ca), löscht ein
+// unbenutztes (action=delete) bzw. alle unbenutzten (action=prune).
+func (h *Handlers) CertificatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		certs, err := h.vpnManager.Certificates()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.writeJSON(w, certs)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.FormValue("action") {
+	case "select":
+		if err := h.vpnManager.SelectCertificate(r.FormValue("profile"), r.FormValue("id")); err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Zertifikat übernommen")
+	case "unselect":
+		if err := h.vpnManager.UnselectCertificate(r.FormValue("profile"), r.FormValue("kind")); err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Zuordnung entfernt")
+	case "delete":
+		if err := h.vpnManager.DeleteCertificate(r.FormValue("id")); err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Zertifikat gelöscht")
+	case "prune":
+		removed, err := h.vpnManager.PruneCertificates()
+		if err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, fmt.Sprintf("%d unbenutzte Zertifikate gelöscht", removed))
+	default:
+		h.sendJSON(w, false, "Unbekannte Aktion")
+	}
+}
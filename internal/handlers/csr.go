@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+
+	"vpn-web/internal/vpn"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// CSRHandler liefert die offene Zertifikatsanforderung eines Profils zum
+// Herunterladen (GET) oder erzeugt Schlüssel und Anforderung
+// (action=generate), übernimmt das signierte Zertifikat (action=import,
+// Datei "certificate") bzw. verwirft die Anforderung (action=cancel).
+func (h *Handlers) CSRHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		profile, ok := h.vpnManager.Profile(r.URL.Query().Get("profile"))
+		if !ok || profile.CSR == nil {
+			http.Error(w, "Keine offene Zertifikatsanforderung", http.StatusNotFound)
+			return
+		}
+		name := unsafeFileChars.ReplaceAllString(profile.Name, "_") + ".csr"
+		w.Header().Set("Content-Type", "application/pkcs10")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.Write([]byte(profile.CSR.PEM))
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		h.sendJSON(w, false, "Form error: "+err.Error())
+		return
+	}
+
+	profileID := r.FormValue("profile")
+	switch r.FormValue("action") {
+	case "generate":
+		subject := vpn.CSRSubject{
+			CommonName:         r.FormValue("cn"),
+			Organization:       r.FormValue("o"),
+			OrganizationalUnit: r.FormValue("ou"),
+			Locality:           r.FormValue("l"),
+			Province:           r.FormValue("st"),
+			Country:            r.FormValue("c"),
+			Email:              r.FormValue("email"),
+		}
+		if _, err := h.vpnManager.GenerateCSR(profileID, subject, r.FormValue("key_type")); err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Zertifikatsanforderung erzeugt")
+	case "import":
+		file, header, err := r.FormFile("certificate")
+		if err != nil {
+			h.sendJSON(w, false, "Keine Zertifikat-Datei")
+			return
+		}
+		defer file.Close()
+		if _, err := h.vpnManager.ImportSignedCertificate(profileID, header.Filename, file); err != nil {
+			h.sendJSON(w, false, "Zertifikat abgelehnt: "+err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Signiertes Zertifikat übernommen")
+	case "cancel":
+		if err := h.vpnManager.CancelCSR(profileID); err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Zertifikatsanforderung verworfen")
+	default:
+		h.sendJSON(w, false, "Unbekannte Aktion")
+	}
+}
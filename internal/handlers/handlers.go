@@ -3,11 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"html/template"
-	"io"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"vpn-web/internal/models"
 	"vpn-web/internal/vpn"
 )
 
@@ -31,23 +31,46 @@ func (h *Handlers) IndexHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Passwort-Status aus Keychain abrufen
-	passwordStatus := h.vpnManager.HasStoredPasswords()
+	profile, ok := h.vpnManager.Profile(r.URL.Query().Get("profile"))
+	if !ok {
+		profile, _ = h.vpnManager.DefaultProfile()
+	}
+
+	// Passwort-Status aus dem Passwort-Speicher abrufen
+	passwordStatus := h.vpnManager.HasStoredPasswords(profile.ID)
+	certificates, _ := h.vpnManager.Certificates()
 
 	data := struct {
-		Settings       interface{}
+		Profile        interface{}
+		Profiles       interface{}
+		DefaultProfile string
 		PasswordStatus map[string]bool
+		SecretStore    interface{}
+		SudoCommand    models.SecretCommand
+		Reconnect      models.ReconnectPolicy
+		CertWarnDays   int
+		CertWarning    *vpn.CertWarning
+		Certificates   []vpn.Certificate
+		Protocols      []vpn.OpenConnectProtocol
 	}{
-		Settings:       h.vpnManager.Settings,
+		Profile:        profile,
+		Profiles:       h.vpnManager.Profiles(),
+		DefaultProfile: h.vpnManager.DefaultProfileID(),
 		PasswordStatus: passwordStatus,
+		SecretStore:    h.vpnManager.SecretStoreStatus(),
+		SudoCommand:    h.vpnManager.SudoCommand(),
+		Reconnect:      h.vpnManager.ReconnectPolicy(),
+		CertWarnDays:   h.vpnManager.CertWarnDays(),
+		CertWarning:    h.vpnManager.CertWarning(profile.ID),
+		Certificates:   certificates,
+		Protocols:      vpn.OpenConnectProtocols(),
 	}
 
 	tmpl.Execute(w, data)
 }
 
 func (h *Handlers) StatusHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"connected": h.vpnManager.IsConnected()})
+	h.writeJSON(w, h.vpnManager.Status())
 }
 
 func (h *Handlers) SettingsHandler(w http.ResponseWriter, r *http.Request) {
@@ -61,32 +84,91 @@ func (h *Handlers) SettingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Alter Benutzername für Keychain-Bereinigung
-	oldUsername := h.vpnManager.Settings.Username
+	profile, ok := h.vpnManager.Profile(r.FormValue("profile"))
+	if !ok {
+		h.sendJSON(w, false, "Profil nicht gefunden")
+		return
+	}
 
 	// Update settings (ohne Passwörter)
-	h.vpnManager.Settings.VPNServer = r.FormValue("vpn_server")
-	h.vpnManager.Settings.AuthGroup = r.FormValue("auth_group")
-	h.vpnManager.Settings.Username = r.FormValue("username")
-	h.vpnManager.Settings.Networks = r.FormValue("networks")
+	if r.Form.Has("type") {
+		profile.Type = r.FormValue("type")
+	}
+	if r.Form.Has("protocol") {
+		profile.Protocol = r.FormValue("protocol")
+	}
+	profile.VPNServer = r.FormValue("vpn_server")
+	profile.AuthGroup = r.FormValue("auth_group")
+	profile.Username = r.FormValue("username")
+	if r.Form.Has("sso") {
+		profile.SSO = r.FormValue("sso") == "1"
+	}
+	if r.Form.Has("pin_server_cert") {
+		profile.PinServerCert = r.FormValue("pin_server_cert") == "1"
+	}
+	if r.Form.Has("routes_include") {
+		profile.Routes = models.Routes{
+			Include: vpn.SplitRouteList(r.FormValue("routes_include")),
+			Exclude: vpn.SplitRouteList(r.FormValue("routes_exclude")),
+		}
+	}
+	if r.Form.Has("dns_domains") {
+		profile.DNSDomains = vpn.SplitRouteList(r.FormValue("dns_domains"))
+	}
+
+	// erst prüfen, dann speichern: ein abgelehntes Profil darf keine
+	// Zertifikate, Passwörter oder Kommandos halb gespeichert zurücklassen
+	if err := h.vpnManager.ValidateProfile(profile); err != nil {
+		h.sendJSON(w, false, "Save error: "+err.Error())
+		return
+	}
 
-	// Bei Benutzername-Änderung alte Passwörter löschen
-	if oldUsername != "" && oldUsername != h.vpnManager.Settings.Username {
-		h.vpnManager.ClearStoredPasswords(oldUsername)
+	// Zertifikat, privater Schlüssel und CA-Bundle: nur speichern, wenn sie
+	// sich lesen lassen und Schlüssel und Zertifikat zusammenpassen
+	var upload [3]*vpn.CertificateFile
+	for i, field := range []string{"certificate", "private_key", "ca_bundle"} {
+		if file, header, err := r.FormFile(field); err == nil {
+			defer file.Close()
+			upload[i] = &vpn.CertificateFile{Name: header.Filename, Data: file}
+		}
+	}
+	var uploaded []vpn.Certificate
+	if upload[0] != nil || upload[1] != nil || upload[2] != nil {
+		var err error
+		uploaded, err = h.vpnManager.ImportCertificates(profile.ID, upload[0], upload[1], upload[2], r.FormValue("cert_password"))
+		if err != nil {
+			h.sendJSON(w, false, "Zertifikat abgelehnt: "+err.Error())
+			return
+		}
 	}
 
-	// Passwörter in Keychain speichern
+	// Passwörter im Passwort-Speicher ablegen
 	var errors []string
 
 	if vpnPassword := r.FormValue("password"); vpnPassword != "" {
-		if err := h.vpnManager.SaveVPNPassword(vpnPassword); err != nil {
+		if err := h.vpnManager.SaveVPNPassword(profile.ID, vpnPassword); err != nil {
 			errors = append(errors, "VPN-Passwort: "+err.Error())
 		}
 	}
 
 	if certPassword := r.FormValue("cert_password"); certPassword != "" {
-		if err := h.vpnManager.SaveCertPassword(certPassword); err != nil {
+		if err := h.vpnManager.SaveCertPassword(profile.ID, certPassword); err != nil {
 			errors = append(errors, "Zertifikat-Passwort: "+err.Error())
+		} else if len(uploaded) == 0 && profile.CertFile != "" {
+			// vorhandenes Zertifikat mit dem neuen Passwort erneut lesen
+			if err := h.vpnManager.RefreshCertificate(profile.ID, certPassword); err != nil {
+				errors = append(errors, "Zertifikat: "+err.Error())
+			}
+		}
+	}
+
+	if r.FormValue("totp_remove") == "1" {
+		if err := h.vpnManager.RemoveTOTPSeed(profile.ID); err != nil {
+			errors = append(errors, "TOTP: "+err.Error())
+		}
+	} else if seed := r.FormValue("totp_seed"); strings.TrimSpace(seed) != "" {
+		if err := h.vpnManager.ImportTOTPSeed(profile.ID, seed); err != nil {
+			errors = append(errors, "TOTP: "+err.Error())
 		}
 	}
 
@@ -96,46 +178,96 @@ func (h *Handlers) SettingsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Handle certificate upload
-	if file, header, err := r.FormFile("certificate"); err == nil {
-		defer file.Close()
+	// Passwörter per Kommando (pass, op, bw ...)
+	if r.Form.Has("vpn_password_command") {
+		if err := h.vpnManager.SetSecretCommand(profile.ID, "vpn", secretCommandFromForm(r, "vpn_password")); err != nil {
+			errors = append(errors, "VPN-Passwort-Kommando: "+err.Error())
+		}
+	}
+	if r.Form.Has("cert_password_command") {
+		if err := h.vpnManager.SetSecretCommand(profile.ID, "cert", secretCommandFromForm(r, "cert_password")); err != nil {
+			errors = append(errors, "Zertifikat-Passwort-Kommando: "+err.Error())
+		}
+	}
+	if r.Form.Has("sudo_password_command") {
+		if err := h.vpnManager.SetSudoCommand(secretCommandFromForm(r, "sudo_password")); err != nil {
+			errors = append(errors, "Sudo-Passwort-Kommando: "+err.Error())
+		}
+	}
 
-		filename := header.Filename
-		if !strings.HasSuffix(strings.ToLower(filename), ".pfx") &&
-			!strings.HasSuffix(strings.ToLower(filename), ".p12") {
-			h.sendJSON(w, false, "Nur .pfx und .p12 Dateien erlaubt")
-			return
+	if r.Form.Has("reconnect_attempts") {
+		attempts, err := strconv.Atoi(r.FormValue("reconnect_attempts"))
+		if err == nil {
+			err = h.vpnManager.SetReconnectAttempts(attempts)
+		}
+		if err != nil {
+			errors = append(errors, "Wiederverbindung: "+err.Error())
 		}
+	}
 
-		certPath := filepath.Join(h.vpnManager.GetCertDir(), filename)
-		if outFile, err := os.Create(certPath); err == nil {
-			defer outFile.Close()
-			io.Copy(outFile, file)
-			h.vpnManager.Settings.CertFile = certPath
-			h.vpnManager.Settings.CertFileName = filename
+	if r.Form.Has("cert_warn_days") {
+		days, err := strconv.Atoi(r.FormValue("cert_warn_days"))
+		if err == nil {
+			err = h.vpnManager.SetCertWarnDays(days)
+		}
+		if err != nil {
+			errors = append(errors, "Ablaufwarnung: "+err.Error())
 		}
 	}
 
-	if err := h.vpnManager.SaveSettings(); err != nil {
+	if err := h.vpnManager.UpdateProfile(profile); err != nil {
 		h.sendJSON(w, false, "Save error: "+err.Error())
 		return
 	}
 
+	if len(uploaded) > 0 {
+		ids := make([]string, len(uploaded))
+		for i, cert := range uploaded {
+			ids[i] = cert.ID
+		}
+		if err := h.vpnManager.SelectCertificate(profile.ID, ids...); err != nil {
+			errors = append(errors, "Zertifikat: "+err.Error())
+		}
+	}
+
+	// WireGuard-Konfiguration (PrivateKey wandert in den Passwort-Speicher)
+	if config := r.FormValue("wireguard_config"); profile.Type == vpn.BackendWireGuard && strings.TrimSpace(config) != "" {
+		if err := h.vpnManager.ImportWireGuardConfig(profile.ID, config); err != nil {
+			errors = append(errors, "WireGuard: "+err.Error())
+		}
+	}
+
+	// OpenVPN-Konfiguration (private Schlüssel wandern in den Passwort-Speicher)
+	if config := r.FormValue("openvpn_config"); profile.Type == vpn.BackendOpenVPN && strings.TrimSpace(config) != "" {
+		if err := h.vpnManager.ImportOpenVPNConfig(profile.ID, config); err != nil {
+			errors = append(errors, "OpenVPN: "+err.Error())
+		}
+	}
+
 	message := "Einstellungen gespeichert"
 	if len(errors) > 0 {
-		message += " (Keychain-Fehler: " + strings.Join(errors, ", ") + ")"
+		message += " (Fehler: " + strings.Join(errors, ", ") + ")"
 	}
 
 	h.sendJSON(w, true, message)
 }
 
+// secretCommandFromForm liest <prefix>_command und <prefix>_timeout.
+func secretCommandFromForm(r *http.Request, prefix string) models.SecretCommand {
+	timeout, _ := strconv.Atoi(r.FormValue(prefix + "_timeout"))
+	return models.SecretCommand{
+		Command: strings.TrimSpace(r.FormValue(prefix + "_command")),
+		Timeout: timeout,
+	}
+}
+
 func (h *Handlers) ConnectHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	success, message := h.vpnManager.Connect()
+	success, message := h.vpnManager.Connect(r.FormValue("profile"))
 	h.sendJSON(w, success, message)
 }
 
@@ -150,9 +282,13 @@ func (h *Handlers) DisconnectHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) sendJSON(w http.ResponseWriter, success bool, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	h.writeJSON(w, map[string]interface{}{
 		"success": success,
 		"message": message,
 	})
 }
+
+func (h *Handlers) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// LocalOnly lässt nur Anfragen an localhost durch und verwirft POSTs, die
+// eine fremde Seite im Browser auslöst. Über /settings lassen sich Kommandos
+// hinterlegen, die beim Verbinden laufen (mit dem Sudo-Passwort auch als
+// root) – ohne diese Prüfung genügte dafür der Besuch einer Webseite.
+func LocalOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Host prüfen, damit DNS-Rebinding nicht über einen fremden Namen
+		// auf 127.0.0.1 zugreift
+		if !isLocalHost(r.Host) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if r.Method != "GET" && r.Method != "HEAD" && !sameOrigin(r) {
+			http.Error(w, "Forbidden (fremde Herkunft)", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin prüft Origin bzw. Referer. Browser senden bei POST immer eines
+// davon; fehlen beide, kommt die Anfrage nicht aus einem Browser (etwa die
+// SSO-Rückmeldung von vpn-web selbst) und ist wegen des Listeners auf
+// 127.0.0.1 lokal.
+func sameOrigin(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false // auch "null" aus Sandbox-iframes und data:-URLs
+	}
+	return isLocalHost(u.Host) && strings.EqualFold(u.Host, r.Host)
+}
+
+// isLocalHost prüft, ob host (mit oder ohne Port) localhost oder eine
+// Loopback-Adresse ist.
+func isLocalHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
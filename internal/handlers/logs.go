@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"vpn-web/internal/vpn"
+)
+
+// LogsHandler liefert das Verbindungsprotokoll aus dem Speicher.
+//
+// Parameter: session (ID, "all"; Standard: aktuelle Sitzung), level
+// (Mindeststufe info/warn/error), source (stdout/stderr/manager), since
+// (RFC3339 oder Unix-Sekunden) und format=text für einen Download.
+func (h *Handlers) LogsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := vpn.LogFilter{
+		Level:  q.Get("level"),
+		Source: q.Get("source"),
+	}
+
+	switch session := q.Get("session"); session {
+	case "", "current":
+	case "all":
+		filter.Session = -1
+	default:
+		id, err := strconv.Atoi(session)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid session", http.StatusBadRequest)
+			return
+		}
+		filter.Session = id
+	}
+
+	if since := q.Get("since"); since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		filter.Since = t
+	}
+
+	entries := h.vpnManager.Logs(filter)
+
+	if q.Get("format") == "text" {
+		name := fmt.Sprintf("vpn-web-%s.log", time.Now().Format("20060102-150405"))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		for _, e := range entries {
+			fmt.Fprintln(w, e.String())
+		}
+		return
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"sessions": h.vpnManager.LogSessions(),
+		"entries":  entries,
+	})
+}
+
+func parseSince(s string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func (h *Handlers) ProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"profiles":        h.vpnManager.Profiles(),
+		"default_profile": h.vpnManager.DefaultProfileID(),
+		"active_profile":  h.vpnManager.ActiveProfile(),
+	})
+}
+
+func (h *Handlers) CreateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := h.vpnManager.CreateProfile(r.FormValue("name"))
+	if err != nil {
+		h.sendJSON(w, false, err.Error())
+		return
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Profil \"" + profile.Name + "\" angelegt",
+		"profile": profile,
+	})
+}
+
+func (h *Handlers) CloneProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := h.vpnManager.CloneProfile(r.FormValue("profile"), r.FormValue("name"))
+	if err != nil {
+		h.sendJSON(w, false, err.Error())
+		return
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Profil \"" + profile.Name + "\" angelegt",
+		"profile": profile,
+	})
+}
+
+func (h *Handlers) RenameProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.vpnManager.RenameProfile(r.FormValue("profile"), r.FormValue("name")); err != nil {
+		h.sendJSON(w, false, err.Error())
+		return
+	}
+
+	h.sendJSON(w, true, "Profil umbenannt")
+}
+
+func (h *Handlers) DeleteProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.vpnManager.DeleteProfile(r.FormValue("profile")); err != nil {
+		h.sendJSON(w, false, err.Error())
+		return
+	}
+
+	h.sendJSON(w, true, "Profil gelöscht")
+}
+
+func (h *Handlers) DefaultProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.vpnManager.SetDefaultProfile(r.FormValue("profile")); err != nil {
+		h.sendJSON(w, false, err.Error())
+		return
+	}
+
+	h.sendJSON(w, true, "Standardprofil gesetzt")
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func (h *Handlers) SecretsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeJSON(w, h.vpnManager.SecretStoreStatus())
+}
+
+func (h *Handlers) UnlockSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.vpnManager.UnlockSecrets(r.FormValue("passphrase")); err != nil {
+		h.sendJSON(w, false, "Entsperren fehlgeschlagen: "+err.Error())
+		return
+	}
+
+	h.sendJSON(w, true, "Passwort-Speicher entsperrt")
+}
+
+// CreateSecretsHandler legt die Master-Passphrase fest (zweimal eingegeben).
+func (h *Handlers) CreateSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.vpnManager.CreateSecrets(r.FormValue("passphrase"), r.FormValue("confirm")); err != nil {
+		h.sendJSON(w, false, "Anlegen fehlgeschlagen: "+err.Error())
+		return
+	}
+
+	h.sendJSON(w, true, "Master-Passphrase festgelegt, Passwort-Speicher entsperrt")
+}
+
+func (h *Handlers) LockSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.vpnManager.LockSecrets(); err != nil {
+		h.sendJSON(w, false, err.Error())
+		return
+	}
+
+	h.sendJSON(w, true, "Passwort-Speicher gesperrt")
+}
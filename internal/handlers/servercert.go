@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// ServerCertHandler beantwortet die Rückfrage zu einem unbekannten
+// Server-Zertifikat (action=accept|reject) oder entfernt einen gespeicherten
+// Fingerprint (action=forget).
+func (h *Handlers) ServerCertHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profileID := r.FormValue("profile")
+	switch r.FormValue("action") {
+	case "accept":
+		if err := h.vpnManager.AcceptServerCert(profileID, r.FormValue("fingerprint")); err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Server-Zertifikat gespeichert")
+	case "reject":
+		h.vpnManager.RejectServerCert()
+		h.sendJSON(w, true, "Server-Zertifikat abgelehnt")
+	case "forget":
+		if err := h.vpnManager.ForgetServerCert(profileID); err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Gespeichertes Server-Zertifikat entfernt")
+	default:
+		h.sendJSON(w, false, "Unbekannte Aktion")
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// SSOCallbackHandler nimmt die Login-URL entgegen, die openconnect bei der
+// Anmeldung per Browser meldet (action=url, nur mit passendem token), und
+// ein von Hand übergebenes Sitzungs-Cookie aus der Oberfläche (action=cookie).
+func (h *Handlers) SSOCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.FormValue("action") {
+	case "url":
+		if err := h.vpnManager.SetSSOURL(r.FormValue("token"), r.FormValue("url")); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		h.sendJSON(w, true, "Login-URL übernommen")
+	case "cookie":
+		if err := h.vpnManager.SubmitSSOCookie(r.FormValue("profile"), r.FormValue("cookie")); err != nil {
+			h.sendJSON(w, false, err.Error())
+			return
+		}
+		h.sendJSON(w, true, "Cookie übernommen, Tunnel wird gestartet...")
+	default:
+		h.sendJSON(w, false, "Unbekannte Aktion")
+	}
+}
@@ -0,0 +1,132 @@
+package helper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Client spricht mit dem Helper über dessen Unix-Socket.
+type Client struct {
+	Socket string
+}
+
+func NewClient(socket string) *Client {
+	return &Client{Socket: socket}
+}
+
+// Available meldet, ob ein Helper installiert ist (der Socket existiert).
+// Ob er antwortet, zeigt erst der Aufruf.
+func (c *Client) Available() bool {
+	if c == nil {
+		return false
+	}
+	info, err := os.Stat(c.Socket)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// dial öffnet eine Verbindung und sendet die Anfrage.
+func (c *Client) dial(req Request) (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("unix", c.Socket, 2*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Helper nicht erreichbar: %v", err)
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("Helper nicht erreichbar: %v", err)
+	}
+	return conn, bufio.NewReader(conn), nil
+}
+
+func readMessage(r *bufio.Reader) (Message, error) {
+	var msg Message
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return msg, err
+	}
+	err = json.Unmarshal(line, &msg)
+	return msg, err
+}
+
+// call stellt eine Anfrage und wartet höchstens timeout auf das Ergebnis.
+func (c *Client) call(req Request, timeout time.Duration) (Message, error) {
+	conn, r, err := c.dial(req)
+	if err != nil {
+		return Message{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	msg, err := readMessage(r)
+	if err != nil {
+		return msg, fmt.Errorf("Keine Antwort vom Helper: %v", err)
+	}
+	if !msg.OK {
+		return msg, errors.New(msg.Error)
+	}
+	return msg, nil
+}
+
+// StartTunnel lässt den Helper openconnect starten. output erhält jede
+// Ausgabezeile; exited liefert das Ende des Prozesses (oder den Abbruch der
+// Verbindung zum Helper, Code -1).
+func (c *Client) StartTunnel(spec TunnelSpec, output func(source, line string)) (pid int, exited <-chan Message, err error) {
+	conn, r, err := c.dial(Request{Op: OpStart, Tunnel: &spec})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	msg, err := readMessage(r)
+	if err != nil {
+		conn.Close()
+		return 0, nil, fmt.Errorf("Keine Antwort vom Helper: %v", err)
+	}
+	if !msg.OK {
+		conn.Close()
+		return 0, nil, errors.New(msg.Error)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	ch := make(chan Message, 1)
+	go func() {
+		defer conn.Close()
+		for {
+			msg, err := readMessage(r)
+			if err != nil {
+				ch <- Message{Type: MsgExit, Code: -1, Reason: "Verbindung zum Helper unterbrochen"}
+				return
+			}
+			switch msg.Type {
+			case MsgOutput:
+				output(msg.Source, msg.Line)
+			case MsgExit:
+				ch <- msg
+				return
+			}
+		}
+	}()
+	return msg.Pid, ch, nil
+}
+
+// Stop beendet den vom Helper gestarteten Tunnel.
+func (c *Client) Stop() error {
+	_, err := c.call(Request{Op: OpStop}, 20*time.Second)
+	return err
+}
+
+// Cleanup lässt den Helper die Reste beendeter Tunnel entfernen.
+func (c *Client) Cleanup() error {
+	_, err := c.call(Request{Op: OpCleanup}, 10*time.Second)
+	return err
+}
+
+// Status meldet, ob der vom Helper gestartete Tunnel läuft.
+func (c *Client) Status() (bool, error) {
+	msg, err := c.call(Request{Op: OpStatus}, 5*time.Second)
+	return msg.Running, err
+}
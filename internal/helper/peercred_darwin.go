@@ -0,0 +1,44 @@
+package helper
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// xucred aus <sys/ucred.h>
+type xucred struct {
+	version uint32
+	uid     uint32
+	ngroups int16
+	groups  [16]uint32
+}
+
+const (
+	solLocal      = 0 // SOL_LOCAL
+	localPeercred = 1 // LOCAL_PEERCRED
+)
+
+// peerUID liefert die Benutzer-ID des Prozesses am anderen Ende (LOCAL_PEERCRED).
+func peerUID(conn *net.UnixConn) (int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	var cred xucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(cred))
+		_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, solLocal, localPeercred,
+			uintptr(unsafe.Pointer(&cred)), uintptr(unsafe.Pointer(&size)), 0)
+		if errno != 0 {
+			credErr = errno
+		}
+	}); err != nil {
+		return -1, err
+	}
+	if credErr != nil {
+		return -1, credErr
+	}
+	return int(cred.uid), nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package helper
+
+import (
+	"errors"
+	"net"
+)
+
+func peerUID(conn *net.UnixConn) (int, error) {
+	return -1, errors.New("Peer-Credentials werden auf diesem System nicht unterstützt")
+}
@@ -0,0 +1,65 @@
+// Package helper enthält den privilegierten Helper-Dienst von vpn-web und
+// den Client dafür. Der Helper läuft als root, lauscht auf einem Unix-Socket
+// und führt nur eine feste Menge von Operationen aus: Tunnel mit geprüften
+// Argumenten starten, den gestarteten Tunnel beenden und dessen Routen
+// aufräumen. Die Weboberfläche braucht dafür weder sudo noch ein
+// Sudo-Passwort.
+package helper
+
+// DefaultSocket ist der Socket, auf dem der Helper standardmäßig lauscht.
+const DefaultSocket = "/var/run/vpn-web-helper.sock"
+
+// Operationen des Helpers. Je Verbindung wird genau eine Anfrage gestellt.
+// Routen räumt der Helper selbst auf, wenn openconnect dazu nicht mehr kam;
+// cleanup holt das nach, etwa wenn der Helper dabei nicht lief.
+const (
+	OpStart   = "start"   // Tunnel starten, danach Ausgabe bis zum Prozessende
+	OpStop    = "stop"    // gestarteten Tunnel beenden
+	OpStatus  = "status"  // läuft der gestartete Tunnel?
+	OpCleanup = "cleanup" // Reste beendeter Tunnel entfernen (Routen, Hosts, Resolver)
+)
+
+// Nachrichtentypen vom Helper an den Client.
+const (
+	MsgResult = "result" // Antwort auf die Anfrage (bei start: Prozess läuft)
+	MsgOutput = "output" // Ausgabezeile von openconnect
+	MsgExit   = "exit"   // Tunnel-Prozess beendet
+)
+
+// Request ist eine Anfrage an den Helper (eine JSON-Zeile).
+type Request struct {
+	Op     string      `json:"op"`
+	Tunnel *TunnelSpec `json:"tunnel,omitempty"` // nur bei start
+}
+
+// TunnelSpec beschreibt einen openconnect-Tunnel. Der Helper prüft jedes
+// Feld und baut die Kommandozeile selbst; Programmpfade kommen nie vom Client.
+type TunnelSpec struct {
+	Protocol   string   `json:"protocol"`              // openconnect --protocol
+	Server     string   `json:"server"`                // Host oder https-URL
+	ServerCert string   `json:"server_cert,omitempty"` // pin-sha256:...
+	Resolve    string   `json:"resolve,omitempty"`     // host:ip
+	Networks   []string `json:"networks"`              // Routen durch den Tunnel
+	Exclude    []string `json:"exclude,omitempty"`     // Netze am Tunnel vorbei
+	Domains    []string `json:"domains,omitempty"`     // Split-DNS über die DNS-Server des VPN
+	Cookie     string   `json:"cookie"`                // aus openconnect --authenticate
+}
+
+// Message ist eine Nachricht des Helpers (eine JSON-Zeile).
+type Message struct {
+	Type string `json:"type"`
+
+	// result
+	OK      bool   `json:"ok,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Running bool   `json:"running,omitempty"`
+	Pid     int    `json:"pid,omitempty"`
+
+	// output
+	Source string `json:"source,omitempty"` // "stdout" oder "stderr"
+	Line   string `json:"line,omitempty"`
+
+	// exit
+	Code   int    `json:"code,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
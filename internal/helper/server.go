@@ -0,0 +1,411 @@
+package helper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"vpn-web/internal/vpnc"
+)
+
+// Server ist der Helper-Dienst. Er verwaltet höchstens einen Tunnel.
+type Server struct {
+	Socket     string
+	AllowedUID int // neben root der einzige Benutzer, der den Socket nutzen darf
+
+	// Programmpfade, beim Start des Helpers festgelegt
+	OpenConnect string
+	VPNCScript  string // vpn-web selbst, aufgerufen als "<Pfad> vpnc-script"
+
+	mu     sync.Mutex
+	tunnel *tunnel // zuletzt gestarteter Tunnel
+}
+
+// tunnel ist ein vom Helper gestarteter openconnect-Prozess.
+type tunnel struct {
+	cmd     *exec.Cmd
+	exclude []netip.Prefix // Ausnahmen, aufzuräumen nach einem Absturz
+	done    chan struct{}  // geschlossen, sobald der Prozess beendet ist
+
+	mu   sync.Mutex
+	sink func(Message) error // Verbindung des startenden Clients, nil = verworfen
+}
+
+// emit leitet eine Nachricht an den startenden Client weiter. Ist er nicht
+// mehr verbunden, läuft der Tunnel trotzdem weiter.
+func (t *tunnel) emit(msg Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sink != nil && t.sink(msg) != nil {
+		t.sink = nil
+	}
+}
+
+func (t *tunnel) detach() {
+	t.mu.Lock()
+	t.sink = nil
+	t.mu.Unlock()
+}
+
+func (t *tunnel) running() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Suchpfade für Programme; nur Systemverzeichnisse, nie Pfade vom Client.
+var programDirs = []string{"/usr/sbin", "/usr/bin", "/usr/local/sbin", "/usr/local/bin", "/opt/homebrew/sbin", "/opt/homebrew/bin"}
+
+func findProgram(name string) string {
+	for _, dir := range programDirs {
+		path := dir + "/" + name
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// Main startet den Helper (Aufruf: vpn-web helper --user <name>).
+func Main(args []string) error {
+	flags := flag.NewFlagSet("helper", flag.ContinueOnError)
+	socket := flags.String("socket", DefaultSocket, "Pfad des Unix-Sockets")
+	userName := flags.String("user", "", "Benutzer (Name oder UID), der den Helper verwenden darf")
+	openconnect := flags.String("openconnect", "", "Pfad zu openconnect (Standard: suchen)")
+	flags.String("vpn-slice", "", "veraltet, wird ignoriert (Routen setzt vpn-web selbst)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if os.Geteuid() != 0 {
+		return errors.New("Der Helper muss als root laufen")
+	}
+	if *userName == "" {
+		return errors.New("--user fehlt")
+	}
+	u, err := user.Lookup(*userName)
+	if err != nil {
+		if u, err = user.LookupId(*userName); err != nil {
+			return fmt.Errorf("Benutzer \"%s\" nicht gefunden", *userName)
+		}
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+
+	srv := &Server{
+		Socket:      *socket,
+		AllowedUID:  uid,
+		OpenConnect: *openconnect,
+	}
+	if srv.VPNCScript, err = os.Executable(); err != nil {
+		return fmt.Errorf("Programmpfad nicht ermittelbar: %v", err)
+	}
+	if srv.OpenConnect == "" {
+		srv.OpenConnect = findProgram("openconnect")
+	}
+	if srv.OpenConnect == "" {
+		return errors.New("openconnect nicht gefunden, bitte --openconnect angeben")
+	}
+	return srv.ListenAndServe()
+}
+
+// ListenAndServe legt den Socket an (nur für AllowedUID les- und
+// schreibbar) und bearbeitet Anfragen bis zu einem Fehler.
+func (srv *Server) ListenAndServe() error {
+	os.Remove(srv.Socket) // Socket eines früheren Laufs
+	listener, err := net.Listen("unix", srv.Socket)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	if err := os.Chown(srv.Socket, srv.AllowedUID, -1); err != nil {
+		return err
+	}
+	if err := os.Chmod(srv.Socket, 0600); err != nil {
+		return err
+	}
+
+	log.Printf("vpn-web helper: %s (UID %d), openconnect %s, vpnc-script %s", srv.Socket, srv.AllowedUID, srv.OpenConnect, srv.VPNCScript)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.handle(conn.(*net.UnixConn))
+	}
+}
+
+func (srv *Server) handle(conn *net.UnixConn) {
+	defer conn.Close()
+
+	uid, err := peerUID(conn)
+	if err != nil || (uid != srv.AllowedUID && uid != 0) {
+		log.Printf("Rejected connection (UID %d): %v", uid, err)
+		return
+	}
+
+	var req Request
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if err := json.NewDecoder(io.LimitReader(conn, 64*1024)).Decode(&req); err != nil {
+		log.Printf("Invalid request: %v", err)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	var writeMu sync.Mutex
+	enc := json.NewEncoder(conn)
+	send := func(msg Message) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		return enc.Encode(msg)
+	}
+	result := func(err error) {
+		if err != nil {
+			send(Message{Type: MsgResult, Error: err.Error()})
+			return
+		}
+		send(Message{Type: MsgResult, OK: true})
+	}
+
+	switch req.Op {
+	case OpStart:
+		srv.start(conn, req.Tunnel, send)
+	case OpStop:
+		result(srv.stop())
+	case OpCleanup:
+		result(srv.cleanup())
+	case OpStatus:
+		msg := Message{Type: MsgResult, OK: true}
+		if t := srv.current(); t != nil && t.running() {
+			msg.Running, msg.Pid = true, t.cmd.Process.Pid
+		}
+		send(msg)
+	default:
+		result(fmt.Errorf("Unbekannte Operation \"%s\"", req.Op))
+	}
+}
+
+func (srv *Server) current() *tunnel {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.tunnel
+}
+
+// start startet openconnect mit --cookie-on-stdin und leitet die Ausgabe an
+// den Client weiter, bis der Prozess endet oder der Client sich trennt.
+func (srv *Server) start(conn net.Conn, spec *TunnelSpec, send func(Message) error) {
+	fail := func(err error) {
+		log.Printf("Start rejected: %v", err)
+		send(Message{Type: MsgResult, Error: err.Error()})
+	}
+	if spec == nil {
+		fail(errors.New("Tunnel-Angaben fehlen"))
+		return
+	}
+	if err := spec.Validate(); err != nil {
+		fail(err)
+		return
+	}
+
+	args := []string{
+		"--cookie-on-stdin",
+		"--protocol=" + spec.Protocol,
+		"-s", strings.Join(append([]string{shellQuote(srv.VPNCScript), "vpnc-script"}, spec.vpncScriptArgs()...), " "),
+	}
+	if spec.ServerCert != "" {
+		args = append(args, "--servercert="+spec.ServerCert)
+	}
+	if spec.Resolve != "" {
+		args = append(args, "--resolve="+spec.Resolve)
+	}
+	args = append(args, spec.Server)
+
+	cmd := exec.Command(srv.OpenConnect, args...)
+	cmd.Env = []string{"PATH=" + strings.Join(programDirs, ":") + ":/sbin:/bin"}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	srv.mu.Lock()
+	if srv.tunnel != nil && srv.tunnel.running() {
+		srv.mu.Unlock()
+		fail(errors.New("Es läuft bereits ein Tunnel"))
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		srv.mu.Unlock()
+		fail(err)
+		return
+	}
+	t := &tunnel{cmd: cmd, exclude: excludePrefixes(spec.Exclude), done: make(chan struct{}), sink: send}
+	srv.tunnel = t
+	srv.mu.Unlock()
+
+	log.Printf("Tunnel started: PID %d, %s (%s)", cmd.Process.Pid, spec.Server, spec.Protocol)
+	send(Message{Type: MsgResult, OK: true, Running: true, Pid: cmd.Process.Pid})
+
+	fmt.Fprintf(stdin, "%s\n", spec.Cookie)
+	stdin.Close()
+
+	var wg sync.WaitGroup
+	forward := func(r io.Reader, source string) {
+		defer wg.Done()
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			t.emit(Message{Type: MsgOutput, Source: source, Line: scanner.Text()})
+		}
+	}
+	wg.Add(2)
+	go forward(stdout, "stdout")
+	go forward(stderr, "stderr")
+
+	go func() {
+		wg.Wait()
+		exit := exitMessage(cmd.Wait(), cmd.ProcessState)
+		log.Printf("Tunnel ended: %s", exit.Reason)
+		// ohne eigenes Ende konnte das vpnc-script Routen, Hosts-Einträge
+		// und Split-DNS nicht entfernen
+		if cmd.ProcessState != nil && !cmd.ProcessState.Exited() {
+			if err := vpnc.RemoveExcludes(t.exclude); err != nil {
+				log.Printf("Cleanup: %v", err)
+			}
+			if err := vpnc.Cleanup(vpnc.DefaultHostsFile, vpnc.DefaultResolverDir); err != nil {
+				log.Printf("Cleanup: %v", err)
+			}
+		}
+		t.emit(exit)
+		close(t.done)
+	}()
+
+	// Trennt sich der Client, läuft der Tunnel weiter (Ausgabe verworfen)
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+	select {
+	case <-t.done:
+	case <-gone:
+		t.detach()
+	}
+}
+
+// exitMessage beschreibt das Ende des Tunnel-Prozesses.
+func exitMessage(err error, state *os.ProcessState) Message {
+	msg := Message{Type: MsgExit, Code: -1}
+	switch {
+	case state == nil:
+		msg.Reason = err.Error()
+	case !state.Exited():
+		msg.Reason = "openconnect beendet: " + state.String()
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			msg.Reason = "openconnect durch Signal beendet: " + ws.Signal().String()
+		}
+	default:
+		msg.Code = state.ExitCode()
+		msg.Reason = fmt.Sprintf("openconnect beendet mit Exit-Code %d", msg.Code)
+	}
+	return msg
+}
+
+// stop beendet den gestarteten Tunnel per SIGTERM (das vpnc-script räumt
+// dann selbst auf), nach Timeout per SIGKILL (aufgeräumt wird dann beim
+// Prozessende).
+func (srv *Server) stop() error {
+	t := srv.current()
+	if t == nil || !t.running() {
+		return nil
+	}
+	t.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-t.done:
+		return nil
+	case <-time.After(10 * time.Second):
+	}
+
+	log.Printf("Tunnel did not exit after SIGTERM, sending SIGKILL")
+	t.cmd.Process.Kill()
+	select {
+	case <-t.done:
+		return nil
+	case <-time.After(3 * time.Second):
+		return errors.New("openconnect lässt sich nicht beenden")
+	}
+}
+
+// cleanup entfernt Ausnahme-Routen, Hosts-Einträge und Resolver-Dateien
+// beendeter Tunnel. Solange ein Tunnel läuft, wird nichts angefasst: unter
+// Linux würden sonst auch dessen Routen gelöscht.
+func (srv *Server) cleanup() error {
+	t := srv.current()
+	if t != nil && t.running() {
+		return errors.New("Es läuft ein Tunnel, Aufräumen erst nach dem Trennen")
+	}
+
+	var exclude []netip.Prefix
+	if t != nil {
+		exclude = t.exclude
+	}
+	var failed []string
+	if err := vpnc.RemoveExcludes(exclude); err != nil {
+		failed = append(failed, err.Error())
+	}
+	if err := vpnc.Cleanup(vpnc.DefaultHostsFile, vpnc.DefaultResolverDir); err != nil {
+		failed = append(failed, err.Error())
+	}
+	if len(failed) > 0 {
+		log.Printf("Cleanup: %s", strings.Join(failed, "; "))
+		return fmt.Errorf("Aufräumen unvollständig: %s", strings.Join(failed, "; "))
+	}
+	log.Printf("Cleanup done")
+	return nil
+}
+
+// shellQuote setzt einen Pfad in einfache Anführungszeichen (openconnect
+// führt -s per /bin/sh aus).
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// excludePrefixes wandelt die (geprüften) Ausnahmen in Netze um.
+func excludePrefixes(exclude []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range exclude {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,55 @@
+package helper
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveOnce startet srv auf einem Socket im Testverzeichnis und bearbeitet
+// genau eine Verbindung.
+func serveOnce(t *testing.T, srv *Server) *Client {
+	t.Helper()
+	srv.Socket = filepath.Join(t.TempDir(), "helper.sock")
+	listener, err := net.Listen("unix", srv.Socket)
+	if err != nil {
+		t.Skipf("Unix-Socket nicht verfügbar: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		srv.handle(conn.(*net.UnixConn))
+	}()
+	return NewClient(srv.Socket)
+}
+
+func TestHandleRejectsOtherUser(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root ist immer zugelassen")
+	}
+	client := serveOnce(t, &Server{AllowedUID: os.Getuid() + 1})
+	if err := client.Cleanup(); err == nil || !strings.Contains(err.Error(), "Keine Antwort") {
+		t.Errorf("Cleanup = %v, want abgewiesene Verbindung", err)
+	}
+}
+
+func TestCleanupRefusedWhileTunnelRunning(t *testing.T) {
+	srv := &Server{AllowedUID: os.Getuid(), tunnel: &tunnel{done: make(chan struct{})}}
+	client := serveOnce(t, srv)
+	if err := client.Cleanup(); err == nil || !strings.Contains(err.Error(), "Es läuft ein Tunnel") {
+		t.Errorf("Cleanup = %v, want Ablehnung", err)
+	}
+}
+
+func TestUnknownOperation(t *testing.T) {
+	client := serveOnce(t, &Server{AllowedUID: os.Getuid()})
+	if _, err := client.call(Request{Op: "exec"}, 5*time.Second); err == nil || !strings.Contains(err.Error(), "Unbekannte Operation") {
+		t.Errorf("call = %v, want Unbekannte Operation", err)
+	}
+}
@@ -0,0 +1,112 @@
+package helper
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// allowedProtocols sind die openconnect-Protokolle, die der Helper startet.
+var allowedProtocols = map[string]bool{
+	"anyconnect": true,
+	"gp":         true,
+	"nc":         true,
+	"pulse":      true,
+	"fortinet":   true,
+	"array":      true,
+}
+
+var (
+	hostPattern    = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+	pinPattern     = regexp.MustCompile(`^pin-sha256:[A-Za-z0-9+/=]+$`)
+	hexCertPattern = regexp.MustCompile(`^(sha1:|sha256:)?[0-9A-Fa-f]{40,64}$`)
+)
+
+// Validate prüft die Angaben, bevor daraus eine Kommandozeile für root wird.
+func (t *TunnelSpec) Validate() error {
+	if !allowedProtocols[t.Protocol] {
+		return fmt.Errorf("Unbekanntes Protokoll \"%s\"", t.Protocol)
+	}
+	if err := validateServer(t.Server); err != nil {
+		return err
+	}
+	if t.ServerCert != "" && !pinPattern.MatchString(t.ServerCert) && !hexCertPattern.MatchString(t.ServerCert) {
+		return fmt.Errorf("Ungültiger Server-Fingerprint")
+	}
+	if t.Resolve != "" {
+		host, ip, ok := strings.Cut(t.Resolve, ":")
+		if !ok || !hostPattern.MatchString(host) || net.ParseIP(ip) == nil {
+			return fmt.Errorf("Ungültige Angabe für --resolve \"%s\"", t.Resolve)
+		}
+	}
+	for _, network := range t.Networks {
+		if !validNetwork(network) {
+			return fmt.Errorf("Ungültiges Netzwerk \"%s\"", network)
+		}
+	}
+	for _, domain := range t.Domains {
+		if !hostPattern.MatchString(domain) {
+			return fmt.Errorf("Ungültige DNS-Domain \"%s\"", domain)
+		}
+	}
+	for _, network := range t.Exclude {
+		if _, _, err := net.ParseCIDR(network); err != nil && net.ParseIP(network) == nil {
+			return fmt.Errorf("Ungültige Ausnahme \"%s\"", network)
+		}
+	}
+	if t.Cookie == "" || strings.ContainsAny(t.Cookie, "\r\n\x00") {
+		return fmt.Errorf("Ungültiges Cookie")
+	}
+	return nil
+}
+
+// vpncScriptArgs liefert die Argumente für "vpn-web vpnc-script": Domains
+// für Split-DNS, Netzwerke unverändert, Ausnahmen mit vorangestelltem "%".
+func (t *TunnelSpec) vpncScriptArgs() []string {
+	var args []string
+	for _, domain := range t.Domains {
+		args = append(args, "--domain", domain)
+	}
+	args = append(args, t.Networks...)
+	for _, network := range t.Exclude {
+		args = append(args, "%"+network)
+	}
+	return args
+}
+
+// validateServer akzeptiert host, host:port oder eine https-URL ohne
+// Zugangsdaten.
+func validateServer(server string) error {
+	host := server
+	if strings.Contains(server, "://") {
+		u, err := url.Parse(server)
+		if err != nil || u.Scheme != "https" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("Ungültige Server-URL \"%s\"", server)
+		}
+		if strings.ContainsAny(u.Path, " \t\r\n") {
+			return fmt.Errorf("Ungültige Server-URL \"%s\"", server)
+		}
+		host = u.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if !hostPattern.MatchString(host) && net.ParseIP(host) == nil {
+		return fmt.Errorf("Ungültiger Server \"%s\"", server)
+	}
+	return nil
+}
+
+// validNetwork akzeptiert CIDR, IP-Adressen und Hostnamen (das vpnc-script
+// löst diese selbst auf).
+func validNetwork(network string) bool {
+	if _, _, err := net.ParseCIDR(network); err == nil {
+		return true
+	}
+	if net.ParseIP(network) != nil {
+		return true
+	}
+	return hostPattern.MatchString(network)
+}
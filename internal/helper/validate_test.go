@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validSpec() TunnelSpec {
+	return TunnelSpec{
+		Protocol: "anyconnect",
+		Server:   "vpn.example.com",
+		Networks: []string{"10.0.0.0/8"},
+		Cookie:   "webvpn=ABC123",
+	}
+}
+
+func TestValidateServer(t *testing.T) {
+	tests := []struct {
+		server string
+		ok     bool
+	}{
+		{"vpn.example.com", true},
+		{"vpn.example.com:8443", true},
+		{"203.0.113.10", true},
+		{"[2001:db8::1]:443", true},
+		{"https://vpn.example.com/group", true},
+		{"https://vpn.example.com:8443/", true},
+		{"", false},
+		{"http://vpn.example.com", false},
+		{"https://user:pw@vpn.example.com", false},
+		{"https://vpn.example.com/?a=b", false},
+		{"https://vpn.example.com/#x", false},
+		{"https://vpn.example.com/a b", false},
+		{"--script=/tmp/x", false},
+		{"-vpn.example.com", false},
+		{"vpn.example.com;id", false},
+		{"vpn example.com", false},
+	}
+	for _, tt := range tests {
+		if err := validateServer(tt.server); (err == nil) != tt.ok {
+			t.Errorf("validateServer(%q) = %v, want ok=%v", tt.server, err, tt.ok)
+		}
+	}
+}
+
+func TestValidateTunnelSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*TunnelSpec)
+		want   string // leer = gültig
+	}{
+		{"gültig", func(*TunnelSpec) {}, ""},
+		{"Protokoll", func(s *TunnelSpec) { s.Protocol = "ssh" }, "Unbekanntes Protokoll"},
+		{"Server", func(s *TunnelSpec) { s.Server = "-s /tmp/x" }, "Ungültiger Server"},
+		{"pin-sha256", func(s *TunnelSpec) { s.ServerCert = "pin-sha256:AbCd+/0123456789=" }, ""},
+		{"sha256-Hex", func(s *TunnelSpec) { s.ServerCert = "sha256:" + strings.Repeat("ab", 32) }, ""},
+		{"sha1-Hex", func(s *TunnelSpec) { s.ServerCert = strings.Repeat("AB", 20) }, ""},
+		{"Pin mit Leerzeichen", func(s *TunnelSpec) { s.ServerCert = "pin-sha256:abc def" }, "Ungültiger Server-Fingerprint"},
+		{"Pin mit Option", func(s *TunnelSpec) { s.ServerCert = "pin-sha256:abc --script=x" }, "Ungültiger Server-Fingerprint"},
+		{"Hex zu kurz", func(s *TunnelSpec) { s.ServerCert = "sha1:abcdef" }, "Ungültiger Server-Fingerprint"},
+		{"resolve", func(s *TunnelSpec) { s.Resolve = "vpn.example.com:203.0.113.10" }, ""},
+		{"resolve IPv6", func(s *TunnelSpec) { s.Resolve = "vpn.example.com:2001:db8::1" }, ""},
+		{"resolve ohne IP", func(s *TunnelSpec) { s.Resolve = "vpn.example.com" }, "--resolve"},
+		{"resolve Hostname als IP", func(s *TunnelSpec) { s.Resolve = "vpn.example.com:evil.example.com" }, "--resolve"},
+		{"resolve ungültiger Host", func(s *TunnelSpec) { s.Resolve = "-x:203.0.113.10" }, "--resolve"},
+		{"Netzwerke", func(s *TunnelSpec) {
+			s.Networks = []string{"10.0.0.0/8", "2001:db8::/32", "10.1.2.3", "intranet.example.com"}
+		}, ""},
+		{"Netzwerk mit Shell", func(s *TunnelSpec) { s.Networks = []string{"10.0.0.0/8;reboot"} }, "Ungültiges Netzwerk"},
+		{"Netzwerk als Option", func(s *TunnelSpec) { s.Networks = []string{"--domain"} }, "Ungültiges Netzwerk"},
+		{"Domain", func(s *TunnelSpec) { s.Domains = []string{"corp.example.com"} }, ""},
+		{"Domain mit Leerzeichen", func(s *TunnelSpec) { s.Domains = []string{"corp example.com"} }, "Ungültige DNS-Domain"},
+		{"Ausnahmen", func(s *TunnelSpec) { s.Exclude = []string{"10.1.0.0/16", "10.2.0.1"} }, ""},
+		{"Ausnahme als Hostname", func(s *TunnelSpec) { s.Exclude = []string{"intranet.example.com"} }, "Ungültige Ausnahme"},
+		{"ohne Cookie", func(s *TunnelSpec) { s.Cookie = "" }, "Ungültiges Cookie"},
+		{"Cookie mit Zeilenumbruch", func(s *TunnelSpec) { s.Cookie = "webvpn=A\n--script=/tmp/x" }, "Ungültiges Cookie"},
+		{"Cookie mit NUL", func(s *TunnelSpec) { s.Cookie = "webvpn=A\x00" }, "Ungültiges Cookie"},
+	}
+	for _, tt := range tests {
+		spec := validSpec()
+		tt.modify(&spec)
+		err := spec.Validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVPNCScriptArgs(t *testing.T) {
+	spec := validSpec()
+	spec.Networks = []string{"10.0.0.0/8", "intranet.example.com"}
+	spec.Exclude = []string{"10.1.0.0/16"}
+	spec.Domains = []string{"corp.example.com"}
+	want := []string{"--domain", "corp.example.com", "10.0.0.0/8", "intranet.example.com", "%10.1.0.0/16"}
+	if got := spec.vpncScriptArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("vpncScriptArgs = %q, want %q", got, want)
+	}
+}
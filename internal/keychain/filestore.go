@@ -0,0 +1,270 @@
+package keychain
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase wird geliefert, wenn die Datei nicht entschlüsselt werden kann.
+var ErrWrongPassphrase = errors.New("Falsche Passphrase")
+
+// scrypt-Parameter (Empfehlung für interaktive Logins, ~64 MiB Speicher)
+const (
+	scryptN      = 1 << 16
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// FileStore ist ein portables SecretStore-Backend: alle Geheimnisse liegen
+// als JSON-Map in einer Datei, verschlüsselt mit AES-256-GCM. Der Schlüssel
+// wird per scrypt aus einer Master-Passphrase abgeleitet und nur im
+// entsperrten Zustand im Speicher gehalten.
+type FileStore struct {
+	path    string
+	mu      sync.Mutex
+	key     []byte
+	salt    []byte
+	secrets map[string]string
+}
+
+// secretFile ist das Format der verschlüsselten Datei.
+type secretFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (f *FileStore) Initialized() bool {
+	_, err := os.Stat(f.path)
+	return err == nil
+}
+
+func (f *FileStore) Locked() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.key == nil
+}
+
+// Create legt die Datei mit einer neuen Passphrase an und entsperrt sie.
+// Die Passphrase muss zweimal gleich eingegeben werden, damit ein Tippfehler
+// keinen Speicher erzeugt, der sich nie wieder öffnen lässt.
+func (f *FileStore) Create(passphrase, confirm string) error {
+	if passphrase == "" {
+		return fmt.Errorf("Passphrase erforderlich")
+	}
+	if passphrase != confirm {
+		return fmt.Errorf("Passphrasen stimmen nicht überein")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := os.Stat(f.path); err == nil {
+		return fmt.Errorf("Passwort-Datei existiert bereits")
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return err
+	}
+	f.key, f.salt, f.secrets = key, salt, map[string]string{}
+	if err := f.saveLocked(); err != nil {
+		f.key, f.salt, f.secrets = nil, nil, nil
+		return err
+	}
+	return nil
+}
+
+// Unlock entschlüsselt die Datei. Angelegt wird sie nur mit Create.
+func (f *FileStore) Unlock(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("Passphrase erforderlich")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("Noch keine Master-Passphrase festgelegt")
+	}
+	if err != nil {
+		return err
+	}
+
+	var file secretFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("Passwort-Datei beschädigt: %v", err)
+	}
+	if file.Version != 1 || file.KDF != "scrypt" {
+		return fmt.Errorf("Passwort-Datei: unbekanntes Format")
+	}
+	// die Parameter sind vor dem Entschlüsseln nicht authentisiert: nur die
+	// eigenen zulassen, sonst ließe sich scrypt beliebig teuer machen
+	if file.N != scryptN || file.R != scryptR || file.P != scryptP {
+		return fmt.Errorf("Passwort-Datei beschädigt: unerwartete scrypt-Parameter")
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), file.Salt, file.N, file.R, file.P, scryptKeyLen)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return fmt.Errorf("Passwort-Datei beschädigt: ungültige Nonce")
+	}
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, file.header())
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("Passwort-Datei beschädigt: %v", err)
+	}
+
+	f.key, f.salt, f.secrets = key, file.Salt, secrets
+	return nil
+}
+
+// Lock verwirft Schlüssel und entschlüsselte Geheimnisse aus dem Speicher.
+func (f *FileStore) Lock() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.key {
+		f.key[i] = 0
+	}
+	f.key, f.secrets = nil, nil
+}
+
+func (f *FileStore) Store(account, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return ErrLocked
+	}
+	f.secrets[account] = secret
+	return f.saveLocked()
+}
+
+func (f *FileStore) Get(account string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return "", ErrLocked
+	}
+	secret, ok := f.secrets[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (f *FileStore) Delete(account string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return ErrLocked
+	}
+	if _, ok := f.secrets[account]; !ok {
+		return ErrNotFound
+	}
+	delete(f.secrets, account)
+	return f.saveLocked()
+}
+
+func (f *FileStore) List() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return nil, ErrLocked
+	}
+	accounts := make([]string, 0, len(f.secrets))
+	for account := range f.secrets {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts, nil
+}
+
+// saveLocked verschlüsselt die Map mit frischer Nonce und ersetzt die
+// Datei atomar (temporäre Datei + rename).
+func (f *FileStore) saveLocked() error {
+	plain, err := json.Marshal(f.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(f.key)
+	if err != nil {
+		return err
+	}
+
+	file := secretFile{Version: 1, KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP, Salt: f.salt}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plain, file.header())
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".vpn_web_secrets-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// header bindet Format und KDF-Parameter als Additional Data an den Chiffretext.
+func (s secretFile) header() []byte {
+	return []byte(fmt.Sprintf("v%d:%s:%d:%d:%d", s.Version, s.KDF, s.N, s.R, s.P))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,127 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFileStore(t *testing.T) (*FileStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	return NewFileStore(path), path
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	store, path := newTestFileStore(t)
+	if err := store.Create("richtig", "richtig"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store("profile1_vpn", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	store.Lock()
+	if _, err := store.Get("profile1_vpn"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Get (gesperrt) = %v, want ErrLocked", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Error("Geheimnis im Klartext in der Datei")
+	}
+
+	reopened := NewFileStore(path)
+	if err := reopened.Unlock("falsch"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Unlock (falsch) = %v, want ErrWrongPassphrase", err)
+	}
+	if err := reopened.Unlock("richtig"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Get("profile1_vpn"); err != nil || got != "s3cret" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	if err := reopened.Delete("profile1_vpn"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Delete("profile1_vpn"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete (fehlt) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileStoreCorruptFile(t *testing.T) {
+	store, path := newTestFileStore(t)
+	if err := store.Create("richtig", "richtig"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(f map[string]any)
+		want   string
+	}{
+		{"kurze Nonce", func(f map[string]any) { f["nonce"] = "AAAA" }, "beschädigt"},
+		{"ohne Nonce", func(f map[string]any) { delete(f, "nonce") }, "beschädigt"},
+		{"scrypt N", func(f map[string]any) { f["n"] = 1 << 30 }, "scrypt-Parameter"},
+		{"scrypt P", func(f map[string]any) { f["p"] = 64 }, "scrypt-Parameter"},
+		{"Version", func(f map[string]any) { f["version"] = 2 }, "unbekanntes Format"},
+		{"Daten", func(f map[string]any) { f["data"] = "AAAAAAAAAAAAAAAAAAAAAA==" }, ErrWrongPassphrase.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file map[string]any
+			if err := json.Unmarshal(original, &file); err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(file)
+			data, _ := json.Marshal(file)
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := NewFileStore(path).Unlock("richtig")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Unlock = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if err := os.WriteFile(path, []byte("{kein json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewFileStore(path).Unlock("richtig"); err == nil || !strings.Contains(err.Error(), "beschädigt") {
+		t.Fatalf("Unlock (kein JSON) = %v", err)
+	}
+}
+
+func TestFileStoreCreate(t *testing.T) {
+	store, _ := newTestFileStore(t)
+
+	// ohne Datei legt Unlock nichts an
+	if err := store.Unlock("richtig"); err == nil || store.Initialized() {
+		t.Fatalf("Unlock ohne Datei = %v, Initialized = %v", err, store.Initialized())
+	}
+	if err := store.Create("richtig", "rihctig"); err == nil || store.Initialized() {
+		t.Fatalf("Create mit abweichender Wiederholung = %v", err)
+	}
+	if err := store.Create("richtig", "richtig"); err != nil {
+		t.Fatal(err)
+	}
+	if store.Locked() || !store.Initialized() {
+		t.Error("nach Create nicht entsperrt")
+	}
+	if err := store.Create("anders", "anders"); err == nil {
+		t.Error("bestehende Datei überschrieben")
+	}
+}
@@ -2,19 +2,55 @@
 package keychain
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"os/exec"
+	"sort"
 	"strings"
 )
 
+const serviceName = "vpn-web-manager"
+
+var (
+	// ErrNotFound wird geliefert, wenn für den Account kein Geheimnis existiert.
+	ErrNotFound = errors.New("Eintrag nicht gefunden")
+	// ErrLocked wird von sperrbaren Backends im gesperrten Zustand geliefert.
+	ErrLocked = errors.New("Passwort-Speicher ist gesperrt")
+)
+
+// SecretStore speichert Geheimnisse (Passwörter) unter einem Account-Namen.
+type SecretStore interface {
+	Store(account, secret string) error
+	Get(account string) (string, error)
+	Delete(account string) error
+	List() ([]string, error)
+}
+
+// Locker wird von Backends implementiert, die mit einer Master-Passphrase
+// entsperrt werden müssen.
+type Locker interface {
+	// Create legt den Speicher mit einer neuen Passphrase an; confirm muss
+	// mit ihr übereinstimmen.
+	Create(passphrase, confirm string) error
+	Unlock(passphrase string) error
+	Lock()
+	Locked() bool
+	// Initialized meldet, ob bereits eine Passphrase festgelegt wurde.
+	Initialized() bool
+}
+
+// KeychainManager ist das SecretStore-Backend für die macOS Keychain
+// (über das Kommandozeilenwerkzeug `security`).
 type KeychainManager struct {
 	serviceName string
 }
 
 func NewKeychainManager() *KeychainManager {
-	return &KeychainManager{serviceName: "vpn-web-manager"}
+	return &KeychainManager{serviceName: serviceName}
 }
 
-func (k *KeychainManager) StorePassword(account, password string) error {
+func (k *KeychainManager) Store(account, password string) error {
 	cmd := exec.Command("security", "add-generic-password",
 		"-s", k.serviceName,
 		"-a", account,
@@ -23,7 +59,7 @@ func (k *KeychainManager) StorePassword(account, password string) error {
 	return cmd.Run()
 }
 
-func (k *KeychainManager) GetPassword(account string) (string, error) {
+func (k *KeychainManager) Get(account string) (string, error) {
 	cmd := exec.Command("security", "find-generic-password",
 		"-s", k.serviceName,
 		"-a", account,
@@ -31,15 +67,75 @@ func (k *KeychainManager) GetPassword(account string) (string, error) {
 
 	output, err := cmd.Output()
 	if err != nil {
+		// Exit-Code 44: errSecItemNotFound
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+			return "", ErrNotFound
+		}
 		return "", err
 	}
 
 	return strings.TrimSpace(string(output)), nil
 }
 
-func (k *KeychainManager) DeletePassword(account string) error {
+func (k *KeychainManager) Delete(account string) error {
 	cmd := exec.Command("security", "delete-generic-password",
 		"-s", k.serviceName,
 		"-a", account)
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		// Exit-Code 44: errSecItemNotFound
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// List liefert alle Accounts des Dienstes aus `security dump-keychain`.
+func (k *KeychainManager) List() ([]string, error) {
+	output, err := exec.Command("security", "dump-keychain").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseDumpKeychain(output, k.serviceName), nil
+}
+
+// parseDumpKeychain wertet die Einträge von `security dump-keychain` aus.
+// Jeder Eintrag beginnt mit "keychain:"; relevant sind die Attribute
+// "svce" (Dienst) und "acct" (Account).
+func parseDumpKeychain(output []byte, service string) []string {
+	var accounts []string
+	var acct, svce string
+
+	flush := func() {
+		if svce == service && acct != "" {
+			accounts = append(accounts, acct)
+		}
+		acct, svce = "", ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "keychain:"):
+			flush()
+		case strings.HasPrefix(line, `"acct"<blob>=`):
+			acct = blobValue(line)
+		case strings.HasPrefix(line, `"svce"<blob>=`):
+			svce = blobValue(line)
+		}
+	}
+	flush()
+
+	sort.Strings(accounts)
+	return accounts
+}
+
+func blobValue(line string) string {
+	value := line[strings.Index(line, "=")+1:]
+	if value == "<NULL>" {
+		return ""
+	}
+	return strings.Trim(value, `"`)
 }
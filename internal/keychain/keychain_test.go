@@ -0,0 +1,35 @@
+package keychain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeSecurity bildet `security` für einen fehlenden Eintrag nach
+// (errSecItemNotFound, Exit-Code 44).
+const fakeSecurity = `#!/bin/sh
+echo "security: SecKeychainSearchCopyNext: The specified item could not be found in the keychain." >&2
+exit 44
+`
+
+func TestKeychainMissingItem(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake security benötigt /bin/sh")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "security"), []byte(fakeSecurity), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	k := NewKeychainManager()
+	if _, err := k.Get("profile1_totp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get = %v, want ErrNotFound", err)
+	}
+	if err := k.Delete("profile1_totp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,134 @@
+package keychain
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// SecretServiceStore ist das SecretStore-Backend für den freedesktop Secret
+// Service (GNOME Keyring, KWallet) über das libsecret-Werkzeug `secret-tool`.
+// Einträge tragen die Attribute service=vpn-web-manager und account=<Account>.
+type SecretServiceStore struct {
+	serviceName string
+	tool        string
+}
+
+func NewSecretServiceStore() *SecretServiceStore {
+	return NewSecretServiceStoreWithTool("secret-tool")
+}
+
+// NewSecretServiceStoreWithTool verwendet ein anderes secret-tool-Binary
+// (z.B. einen absoluten Pfad).
+func NewSecretServiceStoreWithTool(tool string) *SecretServiceStore {
+	return &SecretServiceStore{serviceName: serviceName, tool: tool}
+}
+
+// SecretServiceAvailable meldet, ob `secret-tool` im PATH liegt.
+func SecretServiceAvailable() bool {
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+func (s *SecretServiceStore) Store(account, secret string) error {
+	cmd := exec.Command(s.tool, "store",
+		"--label="+s.serviceName+": "+account,
+		"service", s.serviceName,
+		"account", account)
+	// Geheimnis über stdin, nicht über argv
+	cmd.Stdin = strings.NewReader(secret)
+	return s.run(cmd)
+}
+
+func (s *SecretServiceStore) Get(account string) (string, error) {
+	cmd := exec.Command(s.tool, "lookup",
+		"service", s.serviceName,
+		"account", account)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		// secret-tool lookup beendet sich ohne Ausgabe mit Exit-Code 1,
+		// wenn kein Eintrag existiert.
+		if _, ok := err.(*exec.ExitError); ok && len(output) == 0 && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", toolError(err, stderr.String())
+	}
+
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (s *SecretServiceStore) Delete(account string) error {
+	cmd := exec.Command(s.tool, "clear",
+		"service", s.serviceName,
+		"account", account)
+	return s.run(cmd)
+}
+
+// List liefert die Accounts aller Einträge des Dienstes aus `secret-tool search`.
+func (s *SecretServiceStore) List() ([]string, error) {
+	cmd := exec.Command(s.tool, "search", "--all",
+		"service", s.serviceName)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		// Keine Treffer: Exit-Code 1 ohne Fehlermeldung
+		if _, ok := err.(*exec.ExitError); ok && len(output) == 0 && stderr.Len() == 0 {
+			return nil, nil
+		}
+		return nil, toolError(err, stderr.String())
+	}
+
+	return parseSecretToolSearch(output), nil
+}
+
+func (s *SecretServiceStore) run(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return toolError(err, stderr.String())
+	}
+	return nil
+}
+
+// parseSecretToolSearch liest die "attribute.account = ..."-Zeilen aus
+// der Ausgabe von `secret-tool search`. Geheimnisse werden ignoriert.
+func parseSecretToolSearch(output []byte) []string {
+	var accounts []string
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " = ")
+		if !ok || strings.TrimSpace(key) != "attribute.account" {
+			continue
+		}
+		if !seen[value] {
+			seen[value] = true
+			accounts = append(accounts, value)
+		}
+	}
+
+	sort.Strings(accounts)
+	return accounts
+}
+
+// toolError übernimmt die Fehlermeldung von secret-tool. Eine gesperrte
+// Sammlung (Entsperren am Desktop abgelehnt) wird als ErrLocked gemeldet.
+func toolError(err error, stderr string) error {
+	msg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(stderr), "secret-tool:"))
+	if strings.Contains(strings.ToLower(msg), "locked") {
+		return fmt.Errorf("%w (secret-tool: %s)", ErrLocked, msg)
+	}
+	if msg != "" {
+		return fmt.Errorf("secret-tool: %s", msg)
+	}
+	return fmt.Errorf("secret-tool: %v", err)
+}
@@ -0,0 +1,196 @@
+package keychain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSecretTool bildet `secret-tool` nach: Einträge liegen als Dateien
+// (Name = account) im Verzeichnis des Skripts, jeder Aufruf wird in .argv
+// protokolliert. Existiert .locked, verhält es sich wie eine gesperrte
+// Sammlung, deren Entsperren abgelehnt wurde.
+const fakeSecretTool = `#!/bin/sh
+dir=$(dirname "$0")/entries
+echo "$@" >> "$dir/.argv"
+cmd=$1
+shift
+account=
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--*) shift ;;
+	account) account=$2; shift 2 ;;
+	*) shift 2 ;;
+	esac
+done
+
+if [ -e "$dir/.locked" ]; then
+	case "$cmd" in
+	store) echo "secret-tool: Cannot create an item in a locked collection" >&2 ;;
+	*) echo "secret-tool: Cannot get secret of a locked object" >&2 ;;
+	esac
+	exit 1
+fi
+
+case "$cmd" in
+store)
+	cat > "$dir/$account"
+	;;
+lookup)
+	[ -f "$dir/$account" ] || exit 1
+	cat "$dir/$account"
+	;;
+clear)
+	rm -f "$dir/$account"
+	;;
+search)
+	found=1
+	for f in "$dir"/*; do
+		[ -f "$f" ] || continue
+		found=0
+		name=$(basename "$f")
+		echo "[/org/freedesktop/secrets/collection/login/$name]"
+		echo "label = vpn-web-manager: $name"
+		echo "secret = $(cat "$f")"
+		echo "attribute.service = vpn-web-manager"
+		echo "attribute.account = $name"
+	done
+	exit $found
+	;;
+*)
+	echo "secret-tool: unknown command $cmd" >&2
+	exit 2
+	;;
+esac
+`
+
+// newFakeSecretService liefert einen SecretServiceStore mit dem Fake und
+// das Verzeichnis der Einträge.
+func newFakeSecretService(t *testing.T) (*SecretServiceStore, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake secret-tool benötigt /bin/sh")
+	}
+	dir := t.TempDir()
+	entries := filepath.Join(dir, "entries")
+	if err := os.Mkdir(entries, 0700); err != nil {
+		t.Fatal(err)
+	}
+	tool := filepath.Join(dir, "secret-tool")
+	if err := os.WriteFile(tool, []byte(fakeSecretTool), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return NewSecretServiceStoreWithTool(tool), entries
+}
+
+func TestSecretServiceStoreAndGet(t *testing.T) {
+	store, entries := newFakeSecretService(t)
+
+	if err := store.Store("profile1:vpn", "erstes Passwort"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store("profile1:vpn", "s3cret pass"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get("profile1:vpn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "s3cret pass" {
+		t.Errorf("Get = %q, want %q", got, "s3cret pass")
+	}
+
+	// das Geheimnis geht über stdin, nie über die Kommandozeile
+	argv, err := os.ReadFile(filepath.Join(entries, ".argv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(argv), "s3cret") {
+		t.Errorf("Geheimnis in argv: %q", argv)
+	}
+	if !strings.Contains(string(argv), "store --label=vpn-web-manager: profile1:vpn service vpn-web-manager account profile1:vpn") {
+		t.Errorf("argv = %q", argv)
+	}
+}
+
+func TestSecretServiceGetNotFound(t *testing.T) {
+	store, _ := newFakeSecretService(t)
+
+	if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSecretServiceDelete(t *testing.T) {
+	store, _ := newFakeSecretService(t)
+
+	if err := store.Store("profile1:cert", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("profile1:cert"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("profile1:cert"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get nach Delete = %v, want ErrNotFound", err)
+	}
+	// Löschen eines fehlenden Eintrags ist kein Fehler
+	if err := store.Delete("profile1:cert"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecretServiceList(t *testing.T) {
+	store, _ := newFakeSecretService(t)
+
+	accounts, err := store.List()
+	if err != nil || accounts != nil {
+		t.Fatalf("List (leer) = %v, %v", accounts, err)
+	}
+	for _, account := range []string{"b:vpn", "a:vpn", "sudo"} {
+		if err := store.Store(account, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	accounts, err = store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a:vpn", "b:vpn", "sudo"}; !reflect.DeepEqual(accounts, want) {
+		t.Errorf("List = %q, want %q", accounts, want)
+	}
+}
+
+func TestSecretServiceLockedCollection(t *testing.T) {
+	store, entries := newFakeSecretService(t)
+
+	if err := store.Store("profile1:vpn", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(entries, ".locked"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := store.Store("profile1:vpn", "y")
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), "locked collection") {
+		t.Errorf("Store = %v, want ErrLocked", err)
+	}
+	if _, err := store.Get("profile1:vpn"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Get = %v, want ErrLocked", err)
+	}
+	if _, err := store.List(); !errors.Is(err, ErrLocked) {
+		t.Errorf("List = %v, want ErrLocked", err)
+	}
+}
+
+func TestSecretServiceToolFailure(t *testing.T) {
+	store := NewSecretServiceStoreWithTool(filepath.Join(t.TempDir(), "missing-secret-tool"))
+
+	_, err := store.Get("profile1:vpn")
+	if err == nil || errors.Is(err, ErrNotFound) || !strings.HasPrefix(err.Error(), "secret-tool: ") {
+		t.Fatalf("Get = %v", err)
+	}
+}
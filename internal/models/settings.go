@@ -1,13 +1,120 @@
 package models
 
-type Settings struct {
-	VPNServer    string `json:"vpn_server"`
-	AuthGroup    string `json:"auth_group"`
-	Username     string `json:"username"`
-	Networks     string `json:"networks"`
-	CertFile     string `json:"certificate_file"`
-	CertFileName string `json:"certificate_filename"`
+import "time"
+
+// Profile beschreibt eine benannte VPN-Verbindung (z.B. Firma, Labor).
+type Profile struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Type          string    `json:"type,omitempty"`     // "openconnect" (Standard), "wireguard" oder "openvpn"
+	Protocol      string    `json:"protocol,omitempty"` // openconnect --protocol, leer = "anyconnect"
+	VPNServer     string    `json:"vpn_server"`
+	AuthGroup     string    `json:"auth_group"`
+	Username      string    `json:"username"`
+	SSO           bool      `json:"sso,omitempty"` // Anmeldung per Browser (SAML/SSO) statt Passwort
+	Routes        Routes    `json:"routes"`
+	Networks      string    `json:"networks,omitempty"`        // veraltet, wird beim Laden nach Routes übernommen
+	DNSDomains    []string  `json:"dns_domains,omitempty"`     // über die DNS-Server des VPN aufgelöst (Split-DNS)
+	ServerCert    string    `json:"server_cert,omitempty"`     // bestätigter Fingerprint (pin-sha256:...) für --servercert
+	PinServerCert bool      `json:"pin_server_cert,omitempty"` // Fingerprint auch bei gültigem Zertifikat nach der ersten Anmeldung festlegen
+	CertFile      string    `json:"certificate_file"`
+	CertFileName  string    `json:"certificate_filename"`
+	CertInfo      *CertInfo `json:"certificate_info,omitempty"` // beim Hochladen ausgelesen
+	KeyFile       string    `json:"key_file,omitempty"`         // privater Schlüssel zu einem PEM-Zertifikat
+	KeyFileName   string    `json:"key_filename,omitempty"`
+	CAFile        string    `json:"ca_file,omitempty"` // CA-Bundle für --cafile
+	CAFileName    string    `json:"ca_filename,omitempty"`
+
+	// offene Zertifikatsanforderung; der Schlüssel dazu wartet im
+	// Passwort-Speicher auf das signierte Zertifikat
+	CSR *CSRRequest `json:"csr,omitempty"`
+
 	UseKeychain  bool   `json:"use_keychain"`
 	CreatedAt    string `json:"created_at"`
 	LastModified string `json:"last_modified"`
+
+	// Passwörter, die per Kommando geholt statt gespeichert werden ("vpn", "cert")
+	SecretCommands map[string]SecretCommand `json:"secret_commands,omitempty"`
+
+	// wg-quick-Konfiguration ohne PrivateKey (der liegt im Passwort-Speicher)
+	WireGuardConfig string `json:"wireguard_config,omitempty"`
+
+	// .ovpn-Konfiguration ohne private Schlüssel (die liegen im Passwort-Speicher)
+	OpenVPNConfig string `json:"openvpn_config,omitempty"`
+}
+
+// SecretCommand beschreibt ein Geheimnis, das beim Verbinden über ein externes
+// Kommando (pass, op, bw, ...) abgerufen und nicht gespeichert wird.
+// Platzhalter im Kommando: {profile}, {username}, {server}.
+type SecretCommand struct {
+	Command string `json:"command"`           // z.B. "pass show vpn/{username}"
+	Timeout int    `json:"timeout,omitempty"` // Sekunden, 0 = Standard
+}
+
+// Settings ist der Inhalt von ~/.vpn_web_settings.json.
+type Settings struct {
+	DefaultProfile string            `json:"default_profile"`
+	Profiles       []Profile         `json:"profiles"`
+	SecretStore    string            `json:"secret_store"` // "keychain", "secret-service" oder "file"
+	SudoCommand    *SecretCommand    `json:"sudo_command,omitempty"`
+	Reconnect      *ReconnectPolicy  `json:"reconnect,omitempty"`      // nil = Standardwerte
+	CertWarnDays   int               `json:"cert_warn_days,omitempty"` // Warnung vor Ablauf des Client-Zertifikats, 0 = Standard
+	LegacySecrets  map[string]string `json:"legacy_secrets,omitempty"` // noch zu verschiebende Einträge des alten Formats (alter → neuer Account)
+}
+
+// Routes legt fest, welche Ziele durch den Tunnel gehen (vpnc-script).
+// Include enthält Netze (CIDR), einzelne Adressen und Hostnamen, Exclude
+// Netze und Adressen, die trotzdem am Tunnel vorbei gehen.
+type Routes struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// CSRRequest ist eine in vpn-web erzeugte Zertifikatsanforderung (PKCS#10).
+// Der private Schlüssel dazu liegt im Passwort-Speicher.
+type CSRRequest struct {
+	Subject string    `json:"subject"`
+	KeyType string    `json:"key_type"`
+	Created time.Time `json:"created"`
+	PEM     string    `json:"pem"`
+}
+
+// CertInfo sind die Angaben eines Client-Zertifikats aus der .pfx/.p12-Datei.
+type CertInfo struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	SANs      []string  `json:"sans,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// ReconnectPolicy steuert die automatische Wiederverbindung nach einem
+// unerwarteten Verbindungsabbruch. Die Wartezeit verdoppelt sich je Versuch
+// (mit Zufallsanteil) von InitialDelay bis höchstens MaxDelay.
+type ReconnectPolicy struct {
+	MaxAttempts  int `json:"max_attempts"`            // 0 = keine Wiederverbindung
+	InitialDelay int `json:"initial_delay,omitempty"` // Sekunden, 0 = Standard
+	MaxDelay     int `json:"max_delay,omitempty"`     // Sekunden, 0 = Standard
+}
+
+// Profile liefert das Profil mit der angegebenen ID oder nil.
+func (s *Settings) Profile(id string) *Profile {
+	for i := range s.Profiles {
+		if s.Profiles[i].ID == id {
+			return &s.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// Default liefert das Standardprofil; fehlt es, das erste Profil.
+func (s *Settings) Default() *Profile {
+	if p := s.Profile(s.DefaultProfile); p != nil {
+		return p
+	}
+	if len(s.Profiles) > 0 {
+		return &s.Profiles[0]
+	}
+	return nil
 }
@@ -0,0 +1,189 @@
+package vpn
+
+import (
+	"errors"
+	"fmt"
+	"vpn-web/internal/keychain"
+	"vpn-web/internal/models"
+)
+
+// Tunnelarten (Profile.Type)
+const (
+	BackendOpenConnect = "openconnect"
+	BackendWireGuard   = "wireguard"
+	BackendOpenVPN     = "openvpn"
+)
+
+// Backend ist eine Tunnelart. Zustand, Ereignisse, Protokoll und
+// Wiederverbindung verwaltet der Manager; ein Backend baut nur den Tunnel
+// auf und ab und meldet seinen Fortschritt über die Session.
+type Backend interface {
+	// Name ist der Wert von Profile.Type.
+	Name() string
+
+	// Validate prüft das Profil und lädt über s.Secret alles, was Connect
+	// braucht. Fehler erscheinen so sofort in der Oberfläche.
+	Validate(s *Session) error
+
+	// Connect baut den Tunnel auf. Backends mit eigenem Prozess blockieren
+	// bis zu dessen Ende und liefern es zurück (siehe processEnded); läuft der
+	// Tunnel ohne überwachten Prozess weiter, ist das Ergebnis nil.
+	Connect(s *Session) *ExitInfo
+
+	// Disconnect baut den Tunnel ab.
+	Disconnect(s *Session) error
+
+	// Status meldet, ob der Tunnel des Profils besteht.
+	Status(profile models.Profile) bool
+}
+
+// Session ist ein Verbindungs- oder Trennversuch aus Sicht eines Backends.
+type Session struct {
+	Profile      models.Profile
+	SudoPassword string
+
+	vm           *Manager
+	backend      Backend
+	secrets      map[string]string
+	wasConnected bool   // Tunnel stand zwischenzeitlich
+	fatal        string // Fehler, nach dem keine Wiederverbindung sinnvoll ist
+}
+
+// secretLabels benennt die Profil-Geheimnisse in Fehlermeldungen (Genitiv).
+var secretLabels = map[string]string{
+	secretVPN:          "VPN-Passworts",
+	secretCert:         "Zertifikat-Passworts",
+	secretWireGuard:    "WireGuard-Schlüssels",
+	secretWireGuardPSK: "WireGuard-PresharedKeys",
+	secretOpenVPN:      "OpenVPN-Schlüssels",
+	secretTOTP:         "TOTP-Schlüssels",
+}
+
+// backendFor liefert das Backend eines Profils (leerer Typ = openconnect).
+func (vm *Manager) backendFor(profile models.Profile) (Backend, error) {
+	switch profile.Type {
+	case "", BackendOpenConnect:
+		return vm.openconnect, nil
+	case BackendWireGuard:
+		return vm.wireguard, nil
+	case BackendOpenVPN:
+		return vm.openvpn, nil
+	}
+	return nil, fmt.Errorf("Unbekannter Verbindungstyp \"%s\"", profile.Type)
+}
+
+func (vm *Manager) newSession(profile models.Profile, sudoPassword string) (*Session, error) {
+	backend, err := vm.backendFor(profile)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		Profile:      profile,
+		SudoPassword: sudoPassword,
+		vm:           vm,
+		backend:      backend,
+		secrets:      map[string]string{},
+	}, nil
+}
+
+// prepareSession baut eine Session für einen Verbindungsversuch und lässt
+// das Backend sie prüfen. Passwörter werden bei jedem Versuch neu geholt,
+// damit Kommandos wie "op read" auch bei einer Wiederverbindung aktuelle
+// Werte liefern.
+func (vm *Manager) prepareSession(profile models.Profile) (*Session, error) {
+	sudoPassword, err := vm.sudoPasswordFor(profile)
+	if err != nil {
+		return nil, err
+	}
+	s, err := vm.newSession(profile, sudoPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.Validate(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// runSession verbindet und übergibt das Ende eines überwachten Prozesses
+// an processEnded.
+func (vm *Manager) runSession(s *Session) {
+	vm.mu.Lock()
+	vm.supervised = true
+	vm.mu.Unlock()
+
+	exit := s.backend.Connect(s)
+	if exit == nil {
+		vm.mu.Lock()
+		vm.supervised = false
+		vm.mu.Unlock()
+		return
+	}
+	vm.processEnded(s.Profile.ID, *exit, s.wasConnected, s.fatal)
+}
+
+// Secret lädt ein Profil-Geheimnis (secretVPN, secretCert, ...) aus dem
+// Passwort-Speicher bzw. per Kommando und merkt es sich für Connect.
+// Ein fehlendes Geheimnis ist kein Fehler, sondern "".
+func (s *Session) Secret(kind string) (string, error) {
+	if v, ok := s.secrets[kind]; ok {
+		return v, nil
+	}
+	v, err := s.vm.profileSecret(s.Profile.ID, kind)
+	if errors.Is(err, keychain.ErrLocked) {
+		return "", errors.New("Passwort-Speicher ist gesperrt. Bitte zuerst entsperren.")
+	}
+	if err != nil && err != keychain.ErrNotFound {
+		return "", fmt.Errorf("Fehler beim Laden des %s: %v", secretLabels[kind], err)
+	}
+	s.secrets[kind] = v
+	return v, nil
+}
+
+// Logf protokolliert eine Meldung von vpn-web.
+func (s *Session) Logf(level, format string, args ...interface{}) {
+	s.vm.logf(level, format, args...)
+}
+
+// Progress meldet einen Fortschrittsschritt an die Oberfläche.
+func (s *Session) Progress(message string) {
+	s.vm.progress(message)
+}
+
+// Output protokolliert eine Ausgabezeile des Tunnel-Prozesses.
+func (s *Session) Output(source, line string) {
+	s.vm.logOutput(source, line)
+}
+
+// State liefert den aktuellen Zustand des Managers.
+func (s *Session) State() State {
+	return s.vm.State()
+}
+
+// SetState führt einen Zustandswechsel aus (siehe Manager.setState).
+func (s *Session) SetState(to State, errMsg string) bool {
+	return s.vm.setState(to, errMsg)
+}
+
+// Connected meldet den aufgebauten Tunnel; eine laufende Wiederverbindung
+// ist damit abgeschlossen.
+func (s *Session) Connected() {
+	s.wasConnected = true
+
+	vm := s.vm
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.setStateLocked(StateConnected, "")
+	if vm.reconnectAttempt > 0 {
+		vm.resetReconnectLocked()
+		vm.lastError = ""
+		vm.events.publish(EventState, vm.statusEventLocked())
+	}
+}
+
+// Fail meldet einen Fehler, nach dem eine Wiederverbindung sinnlos ist
+// (z.B. falsches Passwort).
+func (s *Session) Fail(message string) {
+	s.fatal = message
+	s.vm.setState(StateFailed, message)
+}
@@ -0,0 +1,253 @@
+package vpn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+
+	"vpn-web/internal/models"
+)
+
+// defaultCertWarnDays ist der Vorlauf der Ablaufwarnung in Tagen.
+const defaultCertWarnDays = 30
+
+// maxCertFileSize begrenzt hochgeladene .pfx/.p12-Dateien.
+const maxCertFileSize = 1 << 20
+
+var errCertPassword = errors.New("Zertifikat lässt sich mit dem Zertifikat-Passwort nicht entschlüsseln")
+
+// CertWarning meldet ein Client-Zertifikat, das bald abläuft oder bereits
+// abgelaufen ist.
+type CertWarning struct {
+	Profile     string    `json:"profile"`
+	ProfileName string    `json:"profile_name"`
+	Subject     string    `json:"subject"`
+	NotAfter    time.Time `json:"not_after"`
+	DaysLeft    int       `json:"days_left"` // negativ = abgelaufen
+	Message     string    `json:"message"`
+}
+
+// parsePKCS12 entschlüsselt eine .pfx/.p12-Datei und liefert das
+// Client-Zertifikat. Dateien mit AES/PBES2 (Standard ab OpenSSL 3) kann
+// x/crypto/pkcs12 nicht lesen; dafür wird openssl verwendet.
+func parsePKCS12(data []byte, password string) (*x509.Certificate, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return nil, errCertPassword
+	}
+	if err != nil {
+		blocks, err = pkcs12OpenSSL(data, password, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return clientCertificate(blocks)
+}
+
+// pkcs12OpenSSL liest die Zertifikate mit openssl aus. Das Passwort geht per
+// Umgebung, nicht über die Kommandozeile. decodeErr ist der Fehler von
+// x/crypto/pkcs12; er wird gemeldet, wenn openssl fehlt.
+func pkcs12OpenSSL(data []byte, password string, decodeErr error) ([]*pem.Block, error) {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		return nil, fmt.Errorf("Keine lesbare PKCS#12-Datei: %v", decodeErr)
+	}
+
+	cmd := exec.Command("openssl", "pkcs12", "-nokeys", "-clcerts", "-passin", "env:VPN_WEB_P12_PASSWORD")
+	cmd.Env = append(os.Environ(), "VPN_WEB_P12_PASSWORD="+password)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "Mac verify error") {
+			return nil, errCertPassword
+		}
+		return nil, fmt.Errorf("Keine lesbare PKCS#12-Datei (falsches Format oder nicht unterstützte Verschlüsselung)")
+	}
+
+	var blocks []*pem.Block
+	for rest := out; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return blocks, nil
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// clientCertificate wählt das Zertifikat zum privaten Schlüssel (gleiche
+// localKeyId); ohne Zuordnung das erste.
+func clientCertificate(blocks []*pem.Block) (*x509.Certificate, error) {
+	var keyID string
+	for _, block := range blocks {
+		if block.Type == "PRIVATE KEY" {
+			keyID = block.Headers["localKeyId"]
+		}
+	}
+
+	var first *pem.Block
+	for _, block := range blocks {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if keyID != "" && block.Headers["localKeyId"] == keyID {
+			return x509.ParseCertificate(block.Bytes)
+		}
+		if first == nil {
+			first = block
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("PKCS#12-Datei enthält kein Zertifikat")
+	}
+	return x509.ParseCertificate(first.Bytes)
+}
+
+// certInfo übernimmt die angezeigten Angaben eines Zertifikats.
+func certInfo(cert *x509.Certificate) *models.CertInfo {
+	info := &models.CertInfo{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		Serial:    formatSerial(cert.SerialNumber.Bytes()),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+	info.SANs = append(info.SANs, cert.DNSNames...)
+	info.SANs = append(info.SANs, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.SANs = append(info.SANs, uri.String())
+	}
+	return info
+}
+
+// formatSerial schreibt die Seriennummer wie openssl (AB:CD:...).
+func formatSerial(b []byte) string {
+	if len(b) == 0 {
+		return "00"
+	}
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(parts, ":")
+}
+
+// InspectCertificate entschlüsselt eine hochgeladene .pfx/.p12-Datei mit
+// password oder, wenn leer, mit dem gespeicherten Zertifikat-Passwort des
+// Profils. Lässt sie sich nicht lesen, wird sie abgelehnt.
+func (vm *Manager) InspectCertificate(profileID string, data []byte, password string) (*models.CertInfo, error) {
+	if len(data) > maxCertFileSize {
+		return nil, fmt.Errorf("Zertifikat-Datei ist zu groß")
+	}
+	if password == "" {
+		password, _ = vm.GetCertPassword(profileID)
+	}
+	cert, err := parsePKCS12(data, password)
+	if err != nil {
+		return nil, err
+	}
+	return certInfo(cert), nil
+}
+
+// CertWarnDays liefert den Vorlauf der Ablaufwarnung in Tagen.
+func (vm *Manager) CertWarnDays() int {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.certWarnDaysLocked()
+}
+
+func (vm *Manager) certWarnDaysLocked() int {
+	if vm.settings.CertWarnDays > 0 {
+		return vm.settings.CertWarnDays
+	}
+	return defaultCertWarnDays
+}
+
+// SetCertWarnDays legt den Vorlauf der Ablaufwarnung fest (0 = Standard).
+func (vm *Manager) SetCertWarnDays(days int) error {
+	if days < 0 {
+		return fmt.Errorf("Anzahl der Tage darf nicht negativ sein")
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	vm.settings.CertWarnDays = days
+	return vm.saveSettingsLocked()
+}
+
+// CertWarning liefert die Ablaufwarnung für das Client-Zertifikat eines
+// Profils oder nil.
+func (vm *Manager) CertWarning(profileID string) *CertWarning {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	p := vm.settings.Profile(profileID)
+	if p == nil || p.CertFile == "" || p.CertInfo == nil {
+		return nil
+	}
+	if w, ok := certWarning(*p, vm.certWarnDaysLocked(), time.Now()); ok {
+		return &w
+	}
+	return nil
+}
+
+// certWarningsLocked listet alle Profile, deren Client-Zertifikat innerhalb
+// des Vorlaufs abläuft, das am frühesten ablaufende zuerst.
+func (vm *Manager) certWarningsLocked(now time.Time) []CertWarning {
+	warnDays := vm.certWarnDaysLocked()
+
+	var warnings []CertWarning
+	for _, p := range vm.settings.Profiles {
+		if p.CertFile == "" || p.CertInfo == nil {
+			continue
+		}
+		if w, ok := certWarning(p, warnDays, now); ok {
+			warnings = append(warnings, w)
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].NotAfter.Before(warnings[j].NotAfter)
+	})
+	return warnings
+}
+
+func certWarning(p models.Profile, warnDays int, now time.Time) (CertWarning, bool) {
+	remaining := p.CertInfo.NotAfter.Sub(now)
+	if remaining > time.Duration(warnDays)*24*time.Hour {
+		return CertWarning{}, false
+	}
+
+	w := CertWarning{
+		Profile:     p.ID,
+		ProfileName: p.Name,
+		Subject:     p.CertInfo.Subject,
+		NotAfter:    p.CertInfo.NotAfter,
+		DaysLeft:    int(math.Floor(remaining.Hours() / 24)),
+	}
+	date := p.CertInfo.NotAfter.Local().Format("02.01.2006")
+	switch {
+	case remaining <= 0:
+		w.Message = fmt.Sprintf("Client-Zertifikat von \"%s\" ist am %s abgelaufen", p.Name, date)
+	case w.DaysLeft == 0:
+		w.Message = fmt.Sprintf("Client-Zertifikat von \"%s\" läuft in weniger als einem Tag ab (%s)", p.Name, date)
+	case w.DaysLeft == 1:
+		w.Message = fmt.Sprintf("Client-Zertifikat von \"%s\" läuft in einem Tag ab (%s)", p.Name, date)
+	default:
+		w.Message = fmt.Sprintf("Client-Zertifikat von \"%s\" läuft in %d Tagen ab (%s)", p.Name, w.DaysLeft, date)
+	}
+	return w, true
+}
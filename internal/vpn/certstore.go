@@ -0,0 +1,696 @@
+package vpn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"vpn-web/internal/models"
+)
+
+// Arten gespeicherter Dateien.
+const (
+	certKindPKCS12 = "pkcs12" // Zertifikat mit Schlüssel (.pfx/.p12)
+	certKindPEM    = "pem"    // Zertifikat im PEM-Format
+	certKindKey    = "key"    // privater Schlüssel (PEM) zu einem PEM-Zertifikat
+	certKindCA     = "ca"     // CA-Bundle für --cafile
+)
+
+// certKindExt ist die Dateiendung im Speicher je Art.
+var certKindExt = map[string]string{
+	certKindPKCS12: ".p12",
+	certKindPEM:    ".crt",
+	certKindKey:    ".key",
+	certKindCA:     ".ca.pem",
+}
+
+// Certificate ist ein gespeichertes Client-Zertifikat, ein privater
+// Schlüssel oder ein CA-Bundle.
+type Certificate struct {
+	ID       string           `json:"id"`        // certID aus Art und Inhalt
+	Kind     string           `json:"kind"`      // certKindPKCS12, certKindPEM, ...
+	FileName string           `json:"file_name"` // bereinigter Name beim Hochladen
+	Size     int64            `json:"size"`
+	Uploaded time.Time        `json:"uploaded"`
+	Info     *models.CertInfo `json:"info,omitempty"`    // bei CA-Bundles das erste Zertifikat
+	Count    int              `json:"count,omitempty"`   // Zertifikate im CA-Bundle
+	UsedBy   []string         `json:"used_by,omitempty"` // Profilnamen, nur beim Auflisten
+	Path     string           `json:"-"`
+}
+
+// KindLabel beschreibt die Art für die Oberfläche.
+func (c Certificate) KindLabel() string {
+	switch c.Kind {
+	case certKindPEM:
+		return "PEM-Zertifikat"
+	case certKindKey:
+		return "Privater Schlüssel"
+	case certKindCA:
+		return "CA-Bundle"
+	default:
+		return "PKCS#12"
+	}
+}
+
+var certIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// certStore verwaltet die Client-Zertifikate in ~/.vpn_certificates. Die
+// Dateien heißen nach Art und Inhalt (<certID>.p12, .crt, .key, .ca.pem), der
+// Name beim Hochladen kommt so nie in einen Pfad. Originalname und Angaben stehen in index.json.
+type certStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newCertStore(dir string) *certStore {
+	return &certStore{dir: dir}
+}
+
+// certID ist die SHA-256 über Art und Inhalt. Dieselbe Datei kann so als
+// verschiedene Arten gespeichert sein, etwa eine PEM-Datei mit Zertifikat
+// und Schlüssel als beides.
+func certID(kind string, data []byte) string {
+	h := sha256.New()
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *certStore) path(id, kind string) string {
+	return filepath.Join(c.dir, id+certKindExt[kind])
+}
+
+func (c *certStore) indexPath() string {
+	return filepath.Join(c.dir, "index.json")
+}
+
+// idForPath liefert die ID, wenn path eine Datei des Speichers ist.
+func (c *certStore) idForPath(path string) (string, bool) {
+	if filepath.Dir(path) != c.dir {
+		return "", false
+	}
+	id, _, _ := strings.Cut(filepath.Base(path), ".")
+	return id, certIDPattern.MatchString(id)
+}
+
+func (c *certStore) loadIndexLocked() (map[string]Certificate, error) {
+	index := map[string]Certificate{}
+	data, err := os.ReadFile(c.indexPath())
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("Zertifikat-Verzeichnis beschädigt: %v", err)
+	}
+	for id, cert := range index {
+		if cert.Kind == "" {
+			cert.Kind = certKindPKCS12
+			index[id] = cert
+		}
+	}
+	return index, nil
+}
+
+func (c *certStore) saveIndexLocked(index map[string]Certificate) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.indexPath(), data)
+}
+
+// put legt eine Datei der Art entry.Kind ab. Ist derselbe Inhalt schon
+// gespeichert, werden nur Name und Angaben aktualisiert.
+func (c *certStore) put(entry Certificate, data []byte) (Certificate, error) {
+	if len(data) > maxCertFileSize {
+		return Certificate{}, fmt.Errorf("Zertifikat-Datei ist zu groß (höchstens %d KB)", maxCertFileSize>>10)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, err := c.loadIndexLocked()
+	if err != nil {
+		return Certificate{}, err
+	}
+
+	id := certID(entry.Kind, data)
+	// ältere Einträge sind nur nach dem Inhalt benannt
+	sum := sha256.Sum256(data)
+	if legacy := hex.EncodeToString(sum[:]); index[legacy].Kind == entry.Kind {
+		id = legacy
+	}
+	cert, exists := index[id]
+	if !exists {
+		if err := writeFileAtomic(c.path(id, entry.Kind), data); err != nil {
+			return Certificate{}, fmt.Errorf("Datei konnte nicht gespeichert werden: %v", err)
+		}
+		cert = Certificate{ID: id, Kind: entry.Kind, Size: int64(len(data)), Uploaded: time.Now()}
+	}
+	cert.FileName = sanitizeCertFileName(entry.FileName)
+	cert.Info = entry.Info
+	cert.Count = entry.Count
+	index[id] = cert
+	if err := c.saveIndexLocked(index); err != nil {
+		return Certificate{}, err
+	}
+
+	cert.Path = c.path(id, cert.Kind)
+	return cert, nil
+}
+
+func (c *certStore) get(id string) (Certificate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, err := c.loadIndexLocked()
+	if err != nil {
+		return Certificate{}, false
+	}
+	cert, ok := index[id]
+	cert.Path = c.path(id, cert.Kind)
+	return cert, ok
+}
+
+// list liefert alle Zertifikate, das zuletzt hochgeladene zuerst.
+func (c *certStore) list() ([]Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, err := c.loadIndexLocked()
+	if err != nil {
+		return nil, err
+	}
+	certs := make([]Certificate, 0, len(index))
+	for id, cert := range index {
+		cert.Path = c.path(id, cert.Kind)
+		certs = append(certs, cert)
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].Uploaded.After(certs[j].Uploaded)
+	})
+	return certs, nil
+}
+
+func (c *certStore) setInfo(id string, info *models.CertInfo) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, err := c.loadIndexLocked()
+	if err != nil {
+		return err
+	}
+	cert, ok := index[id]
+	if !ok {
+		return fmt.Errorf("Zertifikat nicht gefunden")
+	}
+	cert.Info = info
+	index[id] = cert
+	return c.saveIndexLocked(index)
+}
+
+func (c *certStore) remove(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, err := c.loadIndexLocked()
+	if err != nil {
+		return err
+	}
+	cert, ok := index[id]
+	if !ok {
+		return fmt.Errorf("Zertifikat nicht gefunden")
+	}
+	if err := os.Remove(c.path(id, cert.Kind)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(index, id)
+	return c.saveIndexLocked(index)
+}
+
+// sanitizeCertFileName behält vom Namen beim Hochladen nur den letzten
+// Pfadteil ohne Steuerzeichen, höchstens 100 Zeichen.
+func sanitizeCertFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "zertifikat.p12"
+	}
+	return name
+}
+
+// writeFileAtomic schreibt über eine temporäre Datei im selben Verzeichnis,
+// damit nie eine halb geschriebene Datei liegen bleibt.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// CertificateFile ist eine beim Speichern hochgeladene Datei.
+type CertificateFile struct {
+	Name string
+	Data io.Reader
+}
+
+func (f *CertificateFile) read() ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(f.Data, maxCertFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCertFileSize {
+		return nil, fmt.Errorf("%s ist zu groß (höchstens %d KB)", sanitizeCertFileName(f.Name), maxCertFileSize>>10)
+	}
+	return data, nil
+}
+
+func hasExt(name string, exts ...string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range exts {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportCertificates prüft die hochgeladenen Dateien eines Profils und legt
+// sie im Zertifikat-Speicher ab: das Client-Zertifikat (.pfx/.p12 oder PEM),
+// den privaten Schlüssel zu einem PEM-Zertifikat und ein CA-Bundle. Nicht
+// hochgeladene Teile (nil) nimmt die Prüfung vom Profil. Verschlüsselte
+// Dateien werden mit password oder, wenn leer, mit dem gespeicherten
+// Zertifikat-Passwort geöffnet. Dem Profil werden die Dateien erst mit
+// SelectCertificate zugeordnet.
+func (vm *Manager) ImportCertificates(profileID string, cert, key, ca *CertificateFile, password string) ([]Certificate, error) {
+	profile, ok := vm.Profile(profileID)
+	if !ok {
+		return nil, fmt.Errorf("Profil nicht gefunden")
+	}
+	if password == "" {
+		password, _ = vm.GetCertPassword(profileID)
+	}
+
+	var entries []Certificate
+	var files [][]byte
+
+	// Zertifikat und Schlüssel, die nach dem Speichern gelten
+	var certData, keyData []byte
+	certIsPEM := profile.CertFile != "" && strings.HasSuffix(profile.CertFile, certKindExt[certKindPEM])
+
+	if cert != nil {
+		if !hasExt(cert.Name, ".pfx", ".p12", ".pem", ".crt", ".cer") {
+			return nil, fmt.Errorf("Zertifikat: nur .pfx, .p12, .pem, .crt und .cer Dateien erlaubt")
+		}
+		data, err := cert.read()
+		if err != nil {
+			return nil, err
+		}
+		entry := Certificate{FileName: cert.Name}
+		if isPEM(data) {
+			certs, err := pemCertificates(data)
+			if err != nil {
+				return nil, fmt.Errorf("Zertifikat: %v", err)
+			}
+			entry.Kind, entry.Info = certKindPEM, certInfo(certs[0])
+			certData, certIsPEM = data, true
+		} else {
+			c, err := parsePKCS12(data, password)
+			if err != nil {
+				return nil, fmt.Errorf("Zertifikat: %v", err)
+			}
+			entry.Kind, entry.Info = certKindPKCS12, certInfo(c)
+			certIsPEM = false
+		}
+		entries, files = append(entries, entry), append(files, data)
+	} else if certIsPEM {
+		data, err := os.ReadFile(profile.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("Zertifikat-Datei nicht lesbar: %v", err)
+		}
+		certData = data
+	}
+
+	if key != nil {
+		if !hasExt(key.Name, ".key", ".pem") {
+			return nil, fmt.Errorf("Privater Schlüssel: nur .key und .pem Dateien erlaubt")
+		}
+		if !certIsPEM {
+			return nil, fmt.Errorf("Ein privater Schlüssel wird nur zu einem PEM-Zertifikat benötigt")
+		}
+		data, err := key.read()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pemPublicKey(data, password); err != nil {
+			return nil, err
+		}
+		keyData = data
+		entries, files = append(entries, Certificate{Kind: certKindKey, FileName: key.Name}), append(files, data)
+	} else if certIsPEM {
+		data, err := vm.profileKey(profile)
+		if err != nil {
+			return nil, err
+		}
+		keyData = data
+	}
+
+	if certData != nil {
+		if keyData == nil {
+			return nil, fmt.Errorf("Zum PEM-Zertifikat fehlt der private Schlüssel")
+		}
+		if err := checkKeyPair(certData, keyData, password); err != nil {
+			return nil, err
+		}
+	}
+
+	if ca != nil {
+		if !hasExt(ca.Name, ".pem", ".crt", ".cer") {
+			return nil, fmt.Errorf("CA-Bundle: nur .pem, .crt und .cer Dateien erlaubt")
+		}
+		data, err := ca.read()
+		if err != nil {
+			return nil, err
+		}
+		certs, err := pemCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("CA-Bundle: %v", err)
+		}
+		entry := Certificate{Kind: certKindCA, FileName: ca.Name, Info: certInfo(certs[0]), Count: len(certs)}
+		entries, files = append(entries, entry), append(files, data)
+	}
+
+	// erst speichern, wenn alles geprüft ist
+	stored := make([]Certificate, 0, len(entries))
+	for i, entry := range entries {
+		c, err := vm.certs.put(entry, files[i])
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, c)
+	}
+	return stored, nil
+}
+
+// Certificates listet alle gespeicherten Zertifikate mit den Profilen, die
+// sie verwenden.
+func (vm *Manager) Certificates() ([]Certificate, error) {
+	certs, err := vm.certs.list()
+	if err != nil {
+		return nil, err
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	for i := range certs {
+		certs[i].UsedBy = vm.certUsersLocked(certs[i].Path)
+	}
+	return certs, nil
+}
+
+// certUsersLocked liefert die Namen der Profile, die path verwenden.
+func (vm *Manager) certUsersLocked(path string) []string {
+	var names []string
+	for _, p := range vm.settings.Profiles {
+		if p.CertFile == path || p.KeyFile == path || p.CAFile == path {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// SelectCertificate ordnet einem Profil gespeicherte Dateien zu, je nach
+// Art als Zertifikat, privater Schlüssel oder CA-Bundle. Ein
+// PKCS#12-Zertifikat ersetzt auch den Schlüssel. Ergibt sich ein
+// PEM-Zertifikat mit Schlüssel, müssen beide zusammenpassen.
+func (vm *Manager) SelectCertificate(profileID string, certIDs ...string) error {
+	profile, ok := vm.Profile(profileID)
+	if !ok {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+
+	for _, id := range certIDs {
+		cert, ok := vm.certs.get(id)
+		if !ok {
+			return fmt.Errorf("Zertifikat nicht gefunden")
+		}
+		switch cert.Kind {
+		case certKindPKCS12:
+			profile.CertFile, profile.CertFileName, profile.CertInfo = cert.Path, cert.FileName, cert.Info
+			profile.KeyFile, profile.KeyFileName = "", ""
+		case certKindPEM:
+			profile.CertFile, profile.CertFileName, profile.CertInfo = cert.Path, cert.FileName, cert.Info
+		case certKindKey:
+			profile.KeyFile, profile.KeyFileName = cert.Path, cert.FileName
+		case certKindCA:
+			profile.CAFile, profile.CAFileName = cert.Path, cert.FileName
+		}
+	}
+
+	certIsPEM := strings.HasSuffix(profile.CertFile, certKindExt[certKindPEM])
+	if profile.KeyFile != "" && !certIsPEM {
+		return fmt.Errorf("Ein privater Schlüssel wird nur zu einem PEM-Zertifikat benötigt")
+	}
+	if certIsPEM {
+		certData, err := os.ReadFile(profile.CertFile)
+		if err != nil {
+			return err
+		}
+		keyData, err := vm.profileKey(profile)
+		if err != nil {
+			return err
+		}
+		if keyData != nil {
+			password, _ := vm.GetCertPassword(profileID)
+			if err := checkKeyPair(certData, keyData, password); err != nil {
+				return err
+			}
+		}
+	}
+
+	return vm.setProfileCertificates(profile)
+}
+
+// UnselectCertificate entfernt die Zuordnung von Zertifikat (samt
+// Schlüssel), Schlüssel oder CA-Bundle eines Profils.
+func (vm *Manager) UnselectCertificate(profileID, kind string) error {
+	profile, ok := vm.Profile(profileID)
+	if !ok {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	switch kind {
+	case certKindPKCS12, certKindPEM:
+		profile.CertFile, profile.CertFileName, profile.CertInfo = "", "", nil
+		profile.KeyFile, profile.KeyFileName = "", ""
+	case certKindKey:
+		profile.KeyFile, profile.KeyFileName = "", ""
+	case certKindCA:
+		profile.CAFile, profile.CAFileName = "", ""
+	default:
+		return fmt.Errorf("Unbekannte Art \"%s\"", kind)
+	}
+	return vm.setProfileCertificates(profile)
+}
+
+// setProfileCertificates übernimmt Zertifikat, Schlüssel und CA-Bundle
+// aus profile in das gespeicherte Profil.
+func (vm *Manager) setProfileCertificates(profile models.Profile) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	existing := vm.settings.Profile(profile.ID)
+	if existing == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	existing.CertFile, existing.CertFileName, existing.CertInfo = profile.CertFile, profile.CertFileName, profile.CertInfo
+	existing.KeyFile, existing.KeyFileName = profile.KeyFile, profile.KeyFileName
+	existing.CAFile, existing.CAFileName = profile.CAFile, profile.CAFileName
+	existing.LastModified = time.Now().Format(time.RFC3339)
+	return vm.saveSettingsLocked()
+}
+
+// RefreshCertificate liest das Zertifikat eines Profils mit einem neuen
+// Zertifikat-Passwort erneut und aktualisiert dessen Angaben. Bei einem
+// PEM-Zertifikat wird der private Schlüssel damit geprüft.
+func (vm *Manager) RefreshCertificate(profileID, password string) error {
+	profile, ok := vm.Profile(profileID)
+	if !ok {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	id, ok := vm.certs.idForPath(profile.CertFile)
+	if !ok {
+		return nil
+	}
+
+	data, err := os.ReadFile(profile.CertFile)
+	if err != nil {
+		return fmt.Errorf("Zertifikat-Datei nicht lesbar: %v", err)
+	}
+
+	// beim PEM-Zertifikat gilt das Passwort dem privaten Schlüssel
+	if strings.HasSuffix(profile.CertFile, certKindExt[certKindPEM]) {
+		keyData, err := vm.profileKey(profile)
+		if err != nil || keyData == nil {
+			return err
+		}
+		return checkKeyPair(data, keyData, password)
+	}
+
+	info, err := vm.InspectCertificate(profileID, data, password)
+	if err != nil {
+		return err
+	}
+	if err := vm.certs.setInfo(id, info); err != nil {
+		return err
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	for i := range vm.settings.Profiles {
+		if vm.settings.Profiles[i].CertFile == profile.CertFile {
+			vm.settings.Profiles[i].CertInfo = info
+		}
+	}
+	return vm.saveSettingsLocked()
+}
+
+// DeleteCertificate löscht ein Zertifikat, das kein Profil mehr verwendet.
+func (vm *Manager) DeleteCertificate(certID string) error {
+	cert, ok := vm.certs.get(certID)
+	if !ok {
+		return fmt.Errorf("Zertifikat nicht gefunden")
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if users := vm.certUsersLocked(cert.Path); len(users) > 0 {
+		return fmt.Errorf("Zertifikat wird noch von \"%s\" verwendet", strings.Join(users, "\", \""))
+	}
+	return vm.certs.remove(certID)
+}
+
+// PruneCertificates löscht alle Zertifikate, die kein Profil verwendet.
+func (vm *Manager) PruneCertificates() (int, error) {
+	certs, err := vm.Certificates()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, cert := range certs {
+		if len(cert.UsedBy) > 0 {
+			continue
+		}
+		if err := vm.DeleteCertificate(cert.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// migrateCertificatesLocked übernimmt Zertifikate, die noch unter ihrem
+// hochgeladenen Namen liegen, in den Zertifikat-Speicher.
+func (vm *Manager) migrateCertificatesLocked() {
+	moved := map[string]string{}
+	for i := range vm.settings.Profiles {
+		p := &vm.settings.Profiles[i]
+		if p.CertFile == "" {
+			continue
+		}
+		if _, ok := vm.certs.idForPath(p.CertFile); ok {
+			continue
+		}
+
+		newPath, ok := moved[p.CertFile]
+		if !ok {
+			data, err := os.ReadFile(p.CertFile)
+			if err != nil {
+				vm.logf(LogWarn, "Certificate migration %s failed: %v", p.CertFile, err)
+				continue
+			}
+			cert, err := vm.certs.put(Certificate{Kind: certKindPKCS12, FileName: p.CertFileName, Info: p.CertInfo}, data)
+			if err != nil {
+				vm.logf(LogWarn, "Certificate migration %s failed: %v", p.CertFile, err)
+				continue
+			}
+			newPath = cert.Path
+			moved[p.CertFile] = newPath
+		}
+		p.CertFile = newPath
+	}
+
+	// übrige alte Dateien ebenfalls übernehmen, damit sie in der Liste
+	// erscheinen und gelöscht werden können
+	entries, _ := os.ReadDir(vm.certDir)
+	for _, entry := range entries {
+		path := filepath.Join(vm.certDir, entry.Name())
+		lower := strings.ToLower(entry.Name())
+		if !entry.Type().IsRegular() || (!strings.HasSuffix(lower, ".pfx") && !strings.HasSuffix(lower, ".p12")) {
+			continue
+		}
+		if _, ok := vm.certs.idForPath(path); ok {
+			continue
+		}
+		if _, ok := moved[path]; ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if cert, err := vm.certs.put(Certificate{Kind: certKindPKCS12, FileName: entry.Name()}, data); err == nil {
+			moved[path] = cert.Path
+		}
+	}
+
+	// alte Dateien nur im eigenen Verzeichnis entfernen
+	for oldPath := range moved {
+		if filepath.Dir(oldPath) == vm.certDir {
+			os.Remove(oldPath)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package vpn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+)
+
+func TestCertStorePutSameContentAsOtherKind(t *testing.T) {
+	store := newCertStore(t.TempDir())
+	data := []byte("-----BEGIN CERTIFICATE-----\nX\n-----END CERTIFICATE-----\n")
+
+	pem, err := store.put(Certificate{Kind: certKindPEM, FileName: "client.pem"}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := store.put(Certificate{Kind: certKindCA, FileName: "ca.pem"}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca.ID == pem.ID || ca.Path == pem.Path {
+		t.Fatalf("gleiche ID für zwei Arten: %s", ca.ID)
+	}
+	// das zuerst gespeicherte Zertifikat bleibt erhalten
+	if _, err := os.Stat(pem.Path); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := store.get(pem.ID); !ok || got.Kind != certKindPEM {
+		t.Errorf("get = %+v, %v", got, ok)
+	}
+
+	again, err := store.put(Certificate{Kind: certKindPEM, FileName: "renamed.pem"}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != pem.ID || again.FileName != "renamed.pem" {
+		t.Errorf("erneut hochgeladen = %+v", again)
+	}
+}
+
+func TestCertStorePutKeepsLegacyID(t *testing.T) {
+	store := newCertStore(t.TempDir())
+	data := []byte("pkcs12")
+	sum := sha256.Sum256(data)
+	legacy := hex.EncodeToString(sum[:])
+	index := map[string]Certificate{legacy: {ID: legacy, Kind: certKindPKCS12, FileName: "alt.p12"}}
+	if err := store.saveIndexLocked(index); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := store.put(Certificate{Kind: certKindPKCS12, FileName: "neu.p12"}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.ID != legacy {
+		t.Errorf("ID = %s, erwartet %s", cert.ID, legacy)
+	}
+	certs, _ := store.list()
+	if len(certs) != 1 {
+		t.Errorf("%d Einträge", len(certs))
+	}
+}
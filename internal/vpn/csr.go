@@ -0,0 +1,271 @@
+package vpn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"vpn-web/internal/models"
+)
+
+// Schlüsseltypen für GenerateCSR.
+const (
+	KeyTypeRSA2048   = "rsa-2048"
+	KeyTypeRSA4096   = "rsa-4096"
+	KeyTypeECDSAP256 = "ecdsa-p256"
+)
+
+// CSRSubject sind die Angaben für den Inhaber des angeforderten Zertifikats.
+type CSRSubject struct {
+	CommonName         string
+	Organization       string
+	OrganizationalUnit string
+	Locality           string
+	Province           string
+	Country            string // zweistelliger Ländercode
+	Email              string // als alternativer Name (SAN)
+}
+
+func (s CSRSubject) validate() error {
+	if strings.TrimSpace(s.CommonName) == "" {
+		return fmt.Errorf("Name (CN) erforderlich")
+	}
+	if s.Country != "" && len(s.Country) != 2 {
+		return fmt.Errorf("Land muss ein zweistelliger Code sein (z.B. DE)")
+	}
+	if s.Email != "" && !strings.Contains(s.Email, "@") {
+		return fmt.Errorf("Ungültige E-Mail-Adresse")
+	}
+	for _, v := range []string{s.CommonName, s.Organization, s.OrganizationalUnit, s.Locality, s.Province, s.Email} {
+		if strings.ContainsAny(v, "\r\n\x00") || len(v) > 128 {
+			return fmt.Errorf("Ungültige Angabe \"%s\"", v)
+		}
+	}
+	return nil
+}
+
+func (s CSRSubject) name() pkix.Name {
+	name := pkix.Name{CommonName: strings.TrimSpace(s.CommonName)}
+	add := func(values *[]string, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			*values = append(*values, v)
+		}
+	}
+	add(&name.Organization, s.Organization)
+	add(&name.OrganizationalUnit, s.OrganizationalUnit)
+	add(&name.Locality, s.Locality)
+	add(&name.Province, s.Province)
+	add(&name.Country, strings.ToUpper(s.Country))
+	return name
+}
+
+func generateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case "", KeyTypeRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	return nil, fmt.Errorf("Unbekannter Schlüsseltyp \"%s\"", keyType)
+}
+
+// GenerateCSR erzeugt für ein Profil einen privaten Schlüssel und eine
+// Zertifikatsanforderung. Der Schlüssel kommt nur in den Passwort-Speicher;
+// eine offene Anforderung desselben Profils wird ersetzt.
+func (vm *Manager) GenerateCSR(profileID string, subject CSRSubject, keyType string) (models.CSRRequest, error) {
+	if _, ok := vm.Profile(profileID); !ok {
+		return models.CSRRequest{}, fmt.Errorf("Profil nicht gefunden")
+	}
+	if err := subject.validate(); err != nil {
+		return models.CSRRequest{}, err
+	}
+
+	key, err := generateKey(keyType)
+	if err != nil {
+		return models.CSRRequest{}, err
+	}
+	if keyType == "" {
+		keyType = KeyTypeRSA2048
+	}
+
+	template := &x509.CertificateRequest{Subject: subject.name()}
+	if subject.Email != "" {
+		template.EmailAddresses = []string{strings.TrimSpace(subject.Email)}
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return models.CSRRequest{}, fmt.Errorf("CSR konnte nicht erzeugt werden: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return models.CSRRequest{}, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := vm.secrets.Store(profileAccount(profileID, secretCSRKey), string(keyPEM)); err != nil {
+		return models.CSRRequest{}, err
+	}
+
+	req := models.CSRRequest{
+		Subject: template.Subject.String(),
+		KeyType: keyType,
+		Created: time.Now(),
+		PEM:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})),
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	profile := vm.settings.Profile(profileID)
+	if profile == nil {
+		return models.CSRRequest{}, fmt.Errorf("Profil nicht gefunden")
+	}
+	profile.CSR = &req
+	profile.LastModified = time.Now().Format(time.RFC3339)
+	return req, vm.saveSettingsLocked()
+}
+
+// CancelCSR verwirft die offene Anforderung samt Schlüssel.
+func (vm *Manager) CancelCSR(profileID string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	profile := vm.settings.Profile(profileID)
+	if profile == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	vm.secrets.Delete(profileAccount(profileID, secretCSRKey))
+	profile.CSR = nil
+	profile.LastModified = time.Now().Format(time.RFC3339)
+	return vm.saveSettingsLocked()
+}
+
+// ImportSignedCertificate übernimmt das signierte Zertifikat zur offenen
+// Anforderung (PEM oder DER, gern mit Zwischenzertifikaten). Es muss zum
+// wartenden Schlüssel passen; danach verwendet das Profil Zertifikat und
+// Schlüssel aus dem Passwort-Speicher.
+func (vm *Manager) ImportSignedCertificate(profileID, fileName string, r io.Reader) (Certificate, error) {
+	profile, ok := vm.Profile(profileID)
+	if !ok {
+		return Certificate{}, fmt.Errorf("Profil nicht gefunden")
+	}
+	if profile.CSR == nil {
+		return Certificate{}, fmt.Errorf("Keine offene Zertifikatsanforderung")
+	}
+	keyPEM, err := vm.secrets.Get(profileAccount(profileID, secretCSRKey))
+	if err != nil {
+		return Certificate{}, err
+	}
+	if keyPEM == "" {
+		return Certificate{}, fmt.Errorf("Schlüssel zur Anforderung nicht im Passwort-Speicher gefunden")
+	}
+
+	data, err := (&CertificateFile{Name: fileName, Data: r}).read()
+	if err != nil {
+		return Certificate{}, err
+	}
+	var certs []*x509.Certificate
+	if isPEM(data) {
+		certs, err = pemCertificates(data)
+	} else {
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(data)
+		certs = []*x509.Certificate{cert}
+	}
+	if err != nil {
+		return Certificate{}, fmt.Errorf("Kein lesbares Zertifikat: %v", err)
+	}
+
+	// das Zertifikat zum Schlüssel nach vorn, Zwischenzertifikate dahinter
+	pub, err := pemPublicKey([]byte(keyPEM), "")
+	if err != nil {
+		return Certificate{}, err
+	}
+	leaf := -1
+	for i, cert := range certs {
+		if k, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && k.Equal(pub) {
+			leaf = i
+			break
+		}
+	}
+	if leaf < 0 {
+		return Certificate{}, fmt.Errorf("Zertifikat passt nicht zum Schlüssel der Anforderung")
+	}
+	certs[0], certs[leaf] = certs[leaf], certs[0]
+
+	var chain []byte
+	for _, cert := range certs {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	if fileName == "" {
+		fileName = "zertifikat.crt"
+	}
+	stored, err := vm.certs.put(Certificate{Kind: certKindPEM, FileName: fileName, Info: certInfo(certs[0])}, chain)
+	if err != nil {
+		return Certificate{}, err
+	}
+
+	if err := vm.secrets.Store(profileAccount(profileID, secretClientKey), keyPEM); err != nil {
+		return Certificate{}, err
+	}
+	vm.secrets.Delete(profileAccount(profileID, secretCSRKey))
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	p := vm.settings.Profile(profileID)
+	if p == nil {
+		return Certificate{}, fmt.Errorf("Profil nicht gefunden")
+	}
+	p.CertFile, p.CertFileName, p.CertInfo = stored.Path, stored.FileName, stored.Info
+	p.KeyFile, p.KeyFileName = "", ""
+	p.CSR = nil
+	p.LastModified = time.Now().Format(time.RFC3339)
+	return stored, vm.saveSettingsLocked()
+}
+
+// profileKey liefert den privaten Schlüssel zu einem PEM-Zertifikat: die
+// hochgeladene Schlüsseldatei oder den in vpn-web erzeugten Schlüssel aus
+// dem Passwort-Speicher. nil, wenn es keinen gibt.
+func (vm *Manager) profileKey(profile models.Profile) ([]byte, error) {
+	if profile.KeyFile != "" {
+		data, err := os.ReadFile(profile.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Schlüssel-Datei nicht lesbar: %v", err)
+		}
+		return data, nil
+	}
+	key, err := vm.secrets.Get(profileAccount(profile.ID, secretClientKey))
+	if err != nil || key == "" {
+		return nil, err
+	}
+	return []byte(key), nil
+}
+
+// writeTempKey legt einen Schlüssel aus dem Passwort-Speicher für die Dauer
+// der Anmeldung in einer nur für den Benutzer lesbaren Datei ab.
+func writeTempKey(key string) (path string, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "vpn-web-key-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	path = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(path, []byte(key), 0600); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return path, cleanup, nil
+}
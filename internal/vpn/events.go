@@ -0,0 +1,132 @@
+package vpn
+
+import (
+	"sync"
+	"time"
+)
+
+// Ereignistypen für /events
+const (
+	EventState    = "state"    // Zustandswechsel, Data: Status
+	EventProgress = "progress" // Fortschritt beim Verbinden, Data: string
+	EventLog      = "log"      // Protokollzeile, Data: LogEntry
+)
+
+// Event ist eine Nachricht an die Web-Oberfläche. IDs steigen streng
+// monoton und erlauben das Fortsetzen nach einem Verbindungsabbruch.
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+const (
+	eventHistorySize = 500
+	subscriberBuffer = 256
+)
+
+// eventHub verteilt Ereignisse an alle Abonnenten und hält die letzten
+// Ereignisse für die Wiederaufnahme (Last-Event-ID) vor.
+type eventHub struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	subscribers map[chan Event]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{nextID: 1, subscribers: map[chan Event]struct{}{}}
+}
+
+func (h *eventHub) publish(typ string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ev := Event{ID: h.nextID, Type: typ, Time: time.Now(), Data: data}
+	h.nextID++
+
+	h.history = append(h.history, ev)
+	if len(h.history) > eventHistorySize {
+		h.history = h.history[len(h.history)-eventHistorySize:]
+	}
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Zu langsamer Client: Verbindung schließen, der Browser
+			// verbindet sich neu und holt den Rest über Last-Event-ID.
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe liefert die seit lastID verpassten Ereignisse und einen Kanal
+// für neue. complete ist false, wenn die Lücke nicht mehr vollständig
+// aus dem Verlauf geschlossen werden kann (oder lastID unbekannt ist).
+func (h *eventHub) subscribe(lastID uint64) (missed []Event, complete bool, ch chan Event, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	complete = lastID > 0 && lastID < h.nextID
+	if complete && len(h.history) > 0 && h.history[0].ID > lastID+1 {
+		complete = false
+	}
+	if complete {
+		for _, ev := range h.history {
+			if ev.ID > lastID {
+				missed = append(missed, ev)
+			}
+		}
+	}
+
+	ch = make(chan Event, subscriberBuffer)
+	h.subscribers[ch] = struct{}{}
+
+	cancel = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+	return missed, complete, ch, cancel
+}
+
+// Subscribe abonniert Ereignisse ab lastID (0 = nur neue Ereignisse).
+// Ist resumed false, sollte der Aufrufer zuerst den aktuellen Status senden.
+func (vm *Manager) Subscribe(lastID uint64) (missed []Event, resumed bool, events <-chan Event, cancel func()) {
+	return vm.events.subscribe(lastID)
+}
+
+// progress meldet einen Fortschrittsschritt beim Verbindungsaufbau.
+func (vm *Manager) progress(message string) {
+	vm.logf(LogInfo, "%s", message)
+	vm.events.publish(EventProgress, message)
+}
+
+// statusEventLocked baut die Daten für ein EventState aus dem internen Zustand.
+func (vm *Manager) statusEventLocked() Status {
+	status := Status{
+		State:      vm.state,
+		Connected:  vm.state == StateConnected || vm.state == StateReconnecting,
+		Since:      vm.stateSince,
+		LastError:  vm.lastError,
+		LastExit:   vm.lastExit,
+		Reconnect:  vm.reconnectInfoLocked(),
+		ServerCert: vm.certPrompt,
+		SSO:        vm.ssoLoginLocked(),
+
+		CertWarnings: vm.certWarningsLocked(time.Now()),
+	}
+	if vm.state != StateDisconnected {
+		if p := vm.settings.Profile(vm.activeProfile); p != nil {
+			status.Profile = p.ID
+			status.ProfileName = p.Name
+		}
+	}
+	return status
+}
@@ -0,0 +1,195 @@
+package vpn
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quellen und Stufen der Protokolleinträge
+const (
+	LogSourceStdout  = "stdout"  // Ausgabe des VPN-Prozesses
+	LogSourceStderr  = "stderr"  // Fehlerausgabe des VPN-Prozesses
+	LogSourceManager = "manager" // Meldungen von vpn-web selbst
+
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+const (
+	logSessionCapacity = 2000 // Zeilen je Sitzung
+	logMaxSessions     = 10
+)
+
+var logLevelRank = map[string]int{LogInfo: 0, LogWarn: 1, LogError: 2}
+
+// LogEntry ist eine protokollierte Zeile.
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Session int       `json:"session"`
+	Source  string    `json:"source"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+func (e LogEntry) String() string {
+	return fmt.Sprintf("%s [%s] %-7s %s", e.Time.Format("2006-01-02 15:04:05.000"), strings.ToUpper(e.Level), e.Source, e.Message)
+}
+
+// LogSession beschreibt einen Verbindungsversuch.
+type LogSession struct {
+	ID      int       `json:"id"`
+	Profile string    `json:"profile"`
+	Started time.Time `json:"started"`
+	Lines   int       `json:"lines"`
+	Dropped int       `json:"dropped"`
+}
+
+// LogFilter schränkt die Abfrage ein; Nullwerte bedeuten "alles".
+type LogFilter struct {
+	Session int    // 0 = aktuelle Sitzung, -1 = alle Sitzungen
+	Level   string // Mindeststufe
+	Source  string
+	Since   time.Time
+}
+
+type logSession struct {
+	LogSession
+	entries []LogEntry // Ringpuffer
+	next    int
+}
+
+func (s *logSession) add(e LogEntry) {
+	if len(s.entries) < logSessionCapacity {
+		s.entries = append(s.entries, e)
+	} else {
+		s.entries[s.next] = e
+		s.next = (s.next + 1) % logSessionCapacity
+		s.Dropped++
+	}
+	s.Lines = len(s.entries)
+}
+
+// ordered liefert die Einträge in zeitlicher Reihenfolge.
+func (s *logSession) ordered() []LogEntry {
+	return append(append([]LogEntry(nil), s.entries[s.next:]...), s.entries[:s.next]...)
+}
+
+// logBuffer hält die Protokolle der letzten Sitzungen im Speicher.
+type logBuffer struct {
+	mu       sync.Mutex
+	sessions []*logSession
+	nextID   int
+}
+
+func newLogBuffer() *logBuffer {
+	b := &logBuffer{nextID: 1}
+	b.startSession("") // Meldungen vor dem ersten Verbindungsversuch
+	return b
+}
+
+func (b *logBuffer) startSession(profile string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &logSession{LogSession: LogSession{ID: b.nextID, Profile: profile, Started: time.Now()}}
+	b.nextID++
+	b.sessions = append(b.sessions, s)
+	if len(b.sessions) > logMaxSessions {
+		b.sessions = b.sessions[len(b.sessions)-logMaxSessions:]
+	}
+	return s.ID
+}
+
+func (b *logBuffer) add(source, level, message string) LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.sessions[len(b.sessions)-1]
+	e := LogEntry{Time: time.Now(), Session: current.ID, Source: source, Level: level, Message: message}
+	current.add(e)
+	return e
+}
+
+func (b *logBuffer) sessionList() []LogSession {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	list := make([]LogSession, len(b.sessions))
+	for i, s := range b.sessions {
+		list[i] = s.LogSession
+	}
+	return list
+}
+
+func (b *logBuffer) query(f LogFilter) []LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sessionID := f.Session
+	if sessionID == 0 {
+		sessionID = b.sessions[len(b.sessions)-1].ID
+	}
+	minLevel := logLevelRank[f.Level]
+
+	entries := []LogEntry{}
+	for _, s := range b.sessions {
+		if sessionID > 0 && s.ID != sessionID {
+			continue
+		}
+		for _, e := range s.ordered() {
+			if logLevelRank[e.Level] < minLevel ||
+				(f.Source != "" && e.Source != f.Source) ||
+				(!f.Since.IsZero() && e.Time.Before(f.Since)) {
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// outputLevel stuft eine Zeile des VPN-Prozesses ein.
+func outputLevel(source, line string) string {
+	lower := strings.ToLower(line)
+	switch {
+	case isFailureLine(line) || isSudoAuthFailure(line) ||
+		strings.Contains(lower, "error") || strings.Contains(lower, "failed"):
+		return LogError
+	case strings.Contains(lower, "warning") || strings.Contains(lower, "reconnect") ||
+		strings.Contains(lower, "dead peer"):
+		return LogWarn
+	case source == LogSourceStderr && strings.Contains(lower, "cannot"):
+		return LogWarn
+	}
+	return LogInfo
+}
+
+// logf protokolliert eine Meldung von vpn-web selbst.
+func (vm *Manager) logf(level, format string, args ...interface{}) {
+	vm.logLine(LogSourceManager, level, fmt.Sprintf(format, args...))
+}
+
+// logOutput protokolliert eine Zeile von stdout/stderr des VPN-Prozesses.
+func (vm *Manager) logOutput(source, line string) {
+	vm.logLine(source, outputLevel(source, line), line)
+}
+
+func (vm *Manager) logLine(source, level, message string) {
+	entry := vm.logs.add(source, level, message)
+	// Weiterhin auf stdout, damit das Protokoll von launchd erhalten bleibt
+	fmt.Println(entry.String())
+	vm.events.publish(EventLog, entry)
+}
+
+// LogSessions listet die im Speicher gehaltenen Sitzungen (älteste zuerst).
+func (vm *Manager) LogSessions() []LogSession {
+	return vm.logs.sessionList()
+}
+
+// Logs liefert die gefilterten Protokolleinträge.
+func (vm *Manager) Logs(f LogFilter) []LogEntry {
+	return vm.logs.query(f)
+}
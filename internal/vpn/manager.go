@@ -1,600 +1,480 @@
 package vpn
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 	"vpn-web/internal/keychain"
 	"vpn-web/internal/models"
 )
 
 type Manager struct {
-	Settings     models.Settings
-	settingsFile string
-	certDir      string
-	keychain     *keychain.KeychainManager
+	settings      models.Settings
+	settingsFile  string
+	certDir       string
+	certs         *certStore
+	secrets       keychain.SecretStore
+	mu            sync.Mutex
+	activeProfile string // Profil-ID der zuletzt gestarteten Verbindung
+
+	// Zustandsautomat (siehe state.go), geschützt durch mu
+	state      State
+	stateSince time.Time
+	lastError  string
+
+	// Überwachung und automatische Wiederverbindung (siehe supervisor.go)
+	supervised       bool          // ein eigener Tunnel-Prozess läuft
+	userStopped      bool          // Trennung durch den Benutzer, keine Wiederverbindung
+	lastExit         *ExitInfo     // Ende des zuletzt überwachten Prozesses
+	reconnectAttempt int           // 0 = keine Wiederverbindung aktiv
+	nextReconnect    time.Time     // Zeitpunkt des nächsten Versuchs
+	reconnectCancel  chan struct{} // bricht die Wartezeit ab
+
+	// offene Rückfrage zu einem unbekannten Server-Zertifikat (siehe servercert.go)
+	certPrompt *ServerCertPrompt
+
+	// laufende Anmeldung per Browser (siehe sso.go) und lokale Adresse der
+	// Oberfläche für den Rückruf aus openconnect
+	sso     *ssoAttempt
+	baseURL string
+
+	events *eventHub
+	logs   *logBuffer
+
+	// Tunnelarten (siehe backend.go)
+	openconnect *openconnectBackend
+	wireguard   *wireguardBackend
+	openvpn     *openvpnBackend
 }
 
+// Accounts im Passwort-Speicher je Profil: <Profil-ID>_vpn, <Profil-ID>_cert,
+// <Profil-ID>_wireguard, <Profil-ID>_wireguardpsk (PresharedKeys der Peers),
+// <Profil-ID>_openvpn, <Profil-ID>_totp sowie die in
+// vpn-web erzeugten Schlüssel <Profil-ID>_clientkey (in Gebrauch) und
+// <Profil-ID>_csrkey (wartet auf das signierte Zertifikat). Das
+// Sudo-Passwort gehört zum lokalen Benutzer und gilt für alle Profile.
+const (
+	secretVPN          = "vpn"
+	secretCert         = "cert"
+	secretWireGuard    = "wireguard"
+	secretWireGuardPSK = "wireguardpsk"
+	secretOpenVPN      = "openvpn"
+	secretTOTP         = "totp"
+	secretClientKey    = "clientkey"
+	secretCSRKey       = "csrkey"
+	sudoAccount        = "local_sudo"
+	defaultServer      = "vpn.server.de"
+)
+
+// profileSecretKinds sind alle Geheimnisse, die zu einem Profil gehören.
+var profileSecretKinds = []string{secretVPN, secretCert, secretWireGuard, secretWireGuardPSK, secretOpenVPN, secretTOTP, secretClientKey, secretCSRKey}
+
 func NewVPNManager() *Manager {
 	homeDir, _ := os.UserHomeDir()
 	vm := &Manager{
 		settingsFile: filepath.Join(homeDir, ".vpn_web_settings.json"),
 		certDir:      filepath.Join(homeDir, ".vpn_certificates"),
-		keychain:     keychain.NewKeychainManager(),
-		Settings: models.Settings{
-			VPNServer:   "vpn.server.de",
-			AuthGroup:   "",
-			Networks:    "172.16.1.0/24 192.168.13.0/24 10.33.38.0/24",
-			UseKeychain: true, // Standard: Keychain verwenden
-		},
-	}
+		state:        StateDisconnected,
+		stateSince:   time.Now(),
+		events:       newEventHub(),
+		logs:         newLogBuffer(),
+		openconnect:  newOpenConnectBackend(),
+		baseURL:      "http://localhost:8080",
+	}
+	vm.wireguard = newWireGuardBackend(filepath.Join(homeDir, ".vpn_wireguard"))
+	vm.openvpn = newOpenVPNBackend(filepath.Join(homeDir, ".vpn_openvpn"))
+	vm.certs = newCertStore(vm.certDir)
 	os.MkdirAll(vm.certDir, 0700) // Restriktivere Berechtigung
 	vm.loadSettings()
 	return vm
 }
 
 func (vm *Manager) loadSettings() {
-	if data, err := os.ReadFile(vm.settingsFile); err == nil {
-		json.Unmarshal(data, &vm.Settings)
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	data, err := os.ReadFile(vm.settingsFile)
+	if err == nil {
+		json.Unmarshal(data, &vm.settings)
 	}
 
-	// Sicherstellen dass UseKeychain aktiviert ist
-	if !vm.Settings.UseKeychain {
-		vm.Settings.UseKeychain = true
-		vm.SaveSettings()
+	if vm.settings.SecretStore == "" {
+		vm.settings.SecretStore = defaultSecretStore()
 	}
-}
+	vm.secrets = vm.newSecretStore(vm.settings.SecretStore)
 
-func (vm *Manager) SaveSettings() error {
-	// Timestamps setzen
-	vm.Settings.LastModified = time.Now().Format(time.RFC3339)
-	if vm.Settings.CreatedAt == "" {
-		vm.Settings.CreatedAt = vm.Settings.LastModified
+	if len(vm.settings.Profiles) == 0 {
+		if err == nil {
+			vm.migrateLegacySettings(data)
+		} else {
+			vm.settings.Profiles = []models.Profile{newDefaultProfile("Standard")}
+		}
 	}
 
-	data, err := json.MarshalIndent(vm.Settings, "", "  ")
-	if err != nil {
-		return err
+	// Sicherstellen dass UseKeychain aktiviert ist und ein Standardprofil existiert
+	for i := range vm.settings.Profiles {
+		vm.settings.Profiles[i].UseKeychain = true
+	}
+	if vm.settings.Profile(vm.settings.DefaultProfile) == nil {
+		vm.settings.DefaultProfile = vm.settings.Profiles[0].ID
 	}
+	vm.migrateCertificatesLocked()
+	vm.migrateRoutesLocked()
+	vm.migrateLegacySecretsLocked()
+	vm.migrateWireGuardKeysLocked()
 
-	// Datei mit restriktiven Berechtigungen speichern
-	return os.WriteFile(vm.settingsFile, data, 0600)
+	vm.saveSettingsLocked()
 }
 
-// Passwort-Management Methoden
-func (vm *Manager) SaveVPNPassword(password string) error {
-	if vm.Settings.Username == "" {
-		return fmt.Errorf("Benutzername muss gesetzt sein")
+// migrateLegacySettings übernimmt das alte Einzelprofil-Format
+// (ein Settings-Objekt pro Datei) als erstes Profil "Standard" und merkt
+// die Keychain-Einträge <Username>_vpn/_cert/_sudo zum Verschieben vor
+// (siehe migrateLegacySecretsLocked).
+func (vm *Manager) migrateLegacySettings(data []byte) {
+	profile := newDefaultProfile("Standard")
+	json.Unmarshal(data, &profile)
+	profile.Name = "Standard"
+	if profile.ID == "" {
+		profile.ID = newProfileID()
+	}
+	vm.settings.Profiles = []models.Profile{profile}
+	vm.settings.DefaultProfile = profile.ID
+
+	if profile.Username == "" {
+		return
 	}
-	return vm.keychain.StorePassword(vm.Settings.Username+"_vpn", password)
-}
 
-func (vm *Manager) SaveCertPassword(password string) error {
-	if vm.Settings.Username == "" {
-		return fmt.Errorf("Benutzername muss gesetzt sein")
+	vm.settings.LegacySecrets = map[string]string{
+		profile.Username + "_vpn":  profileAccount(profile.ID, secretVPN),
+		profile.Username + "_cert": profileAccount(profile.ID, secretCert),
+		profile.Username + "_sudo": sudoAccount,
 	}
-	return vm.keychain.StorePassword(vm.Settings.Username+"_cert", password)
 }
 
-func (vm *Manager) SaveSudoPassword(password string) error {
-	if vm.Settings.Username == "" {
-		return fmt.Errorf("Benutzername muss gesetzt sein")
+// migrateLegacySecretsLocked verschiebt die vorgemerkten Einträge des alten
+// Formats. Ist der Passwort-Speicher gesperrt oder schlägt das Verschieben
+// fehl, bleiben alter Eintrag und Vormerkung erhalten; der nächste Start
+// bzw. das Entsperren versucht es erneut.
+func (vm *Manager) migrateLegacySecretsLocked() {
+	for oldAccount, newAccount := range vm.settings.LegacySecrets {
+		password, err := vm.secrets.Get(oldAccount)
+		if err != nil && !errors.Is(err, keychain.ErrNotFound) {
+			vm.logf(LogWarn, "Secret migration %s postponed: %v", oldAccount, err)
+			continue
+		}
+		if password != "" {
+			// ein inzwischen neu gespeichertes Passwort hat Vorrang
+			if current, err := vm.secrets.Get(newAccount); err != nil || current == "" {
+				if err := vm.secrets.Store(newAccount, password); err != nil {
+					vm.logf(LogWarn, "Secret migration %s postponed: %v", oldAccount, err)
+					continue
+				}
+			}
+			vm.secrets.Delete(oldAccount)
+		}
+		delete(vm.settings.LegacySecrets, oldAccount)
+	}
+	if len(vm.settings.LegacySecrets) == 0 {
+		vm.settings.LegacySecrets = nil
 	}
-	return vm.keychain.StorePassword(vm.Settings.Username+"_sudo", password)
 }
 
-func (vm *Manager) GetVPNPassword() (string, error) {
-	if vm.Settings.Username == "" {
-		return "", fmt.Errorf("Benutzername nicht gesetzt")
-	}
-	return vm.keychain.GetPassword(vm.Settings.Username + "_vpn")
+func (vm *Manager) SaveSettings() error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.saveSettingsLocked()
 }
 
-func (vm *Manager) GetCertPassword() (string, error) {
-	if vm.Settings.Username == "" {
-		return "", fmt.Errorf("Benutzername nicht gesetzt")
+func (vm *Manager) saveSettingsLocked() error {
+	data, err := json.MarshalIndent(vm.settings, "", "  ")
+	if err != nil {
+		return err
 	}
-	return vm.keychain.GetPassword(vm.Settings.Username + "_cert")
+
+	// Datei mit restriktiven Berechtigungen speichern
+	return os.WriteFile(vm.settingsFile, data, 0600)
 }
 
-func (vm *Manager) GetSudoPassword() (string, error) {
-	if vm.Settings.Username == "" {
-		return "", fmt.Errorf("Benutzername nicht gesetzt")
-	}
-	return vm.keychain.GetPassword(vm.Settings.Username + "_sudo")
+func profileAccount(profileID, kind string) string {
+	return profileID + "_" + kind
 }
 
-// Passwort-Status prüfen
-func (vm *Manager) HasStoredPasswords() map[string]bool {
-	if vm.Settings.Username == "" {
-		return map[string]bool{
-			"vpn_password":  false,
-			"cert_password": false,
-			"sudo_password": false,
-		}
+// Passwort-Management Methoden
+func (vm *Manager) SaveVPNPassword(profileID, password string) error {
+	if _, ok := vm.Profile(profileID); !ok {
+		return fmt.Errorf("Profil nicht gefunden")
 	}
+	return vm.secrets.Store(profileAccount(profileID, secretVPN), password)
+}
 
-	vpnPassword, _ := vm.keychain.GetPassword(vm.Settings.Username + "_vpn")
-	certPassword, _ := vm.keychain.GetPassword(vm.Settings.Username + "_cert")
-	sudoPassword, _ := vm.keychain.GetPassword(vm.Settings.Username + "_sudo")
-
-	return map[string]bool{
-		"vpn_password":  vpnPassword != "",
-		"cert_password": certPassword != "",
-		"sudo_password": sudoPassword != "",
+func (vm *Manager) SaveCertPassword(profileID, password string) error {
+	if _, ok := vm.Profile(profileID); !ok {
+		return fmt.Errorf("Profil nicht gefunden")
 	}
+	return vm.secrets.Store(profileAccount(profileID, secretCert), password)
 }
 
-// Passwörter löschen (z.B. bei Benutzername-Änderung)
-func (vm *Manager) ClearStoredPasswords(oldUsername string) error {
-	if oldUsername == "" {
-		return nil
-	}
+func (vm *Manager) SaveSudoPassword(password string) error {
+	return vm.secrets.Store(sudoAccount, password)
+}
 
-	vm.keychain.DeletePassword(oldUsername + "_vpn")
-	vm.keychain.DeletePassword(oldUsername + "_cert")
-	vm.keychain.DeletePassword(oldUsername + "_sudo")
+func (vm *Manager) GetVPNPassword(profileID string) (string, error) {
+	return vm.profileSecret(profileID, secretVPN)
+}
 
-	return nil
+func (vm *Manager) GetCertPassword(profileID string) (string, error) {
+	return vm.profileSecret(profileID, secretCert)
 }
 
-func (vm *Manager) Connect() (bool, string) {
-	if vm.IsConnected() {
-		return false, "VPN ist bereits verbunden"
-	}
+func (vm *Manager) GetSudoPassword() (string, error) {
+	vm.mu.Lock()
+	sc := vm.settings.SudoCommand
+	vm.mu.Unlock()
 
-	if vm.Settings.CertFile == "" || !fileExists(vm.Settings.CertFile) {
-		return false, "Kein gültiges Zertifikat ausgewählt"
+	if sc != nil && sc.Command != "" {
+		return runSecretCommand(*sc, models.Profile{})
 	}
+	return vm.secrets.Get(sudoAccount)
+}
 
-	if vm.Settings.Username == "" {
-		return false, "Benutzername erforderlich"
+// profileSecret holt ein Profil-Geheimnis entweder über das konfigurierte
+// Kommando oder aus dem Passwort-Speicher.
+func (vm *Manager) profileSecret(profileID, kind string) (string, error) {
+	profile, ok := vm.Profile(profileID)
+	if !ok {
+		return "", fmt.Errorf("Profil nicht gefunden")
 	}
-
-	// Passwörter aus Keychain laden
-	vpnPassword, err := vm.GetVPNPassword()
-	if err != nil || vpnPassword == "" {
-		return false, "VPN-Passwort nicht in Keychain gefunden. Bitte in den Einstellungen speichern."
+	if sc, ok := profile.SecretCommands[kind]; ok && sc.Command != "" {
+		return runSecretCommand(sc, profile)
 	}
+	return vm.secrets.Get(profileAccount(profileID, kind))
+}
 
-	certPassword, err := vm.GetCertPassword()
-	if err != nil {
-		return false, "Fehler beim Laden des Zertifikat-Passworts aus Keychain"
+// SetSecretCommand konfiguriert (bzw. entfernt bei leerem Kommando) das
+// Abrufen eines Profil-Geheimnisses per Kommando.
+func (vm *Manager) SetSecretCommand(profileID, kind string, sc models.SecretCommand) error {
+	if sc.Command != "" {
+		if err := validateSecretCommand(sc.Command); err != nil {
+			return err
+		}
 	}
 
-	openconnectPath := vm.findOpenConnectPath()
-	vpnSlicePath := vm.findVpnSlicePath()
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
 
-	if openconnectPath == "" {
-		return false, "openconnect nicht gefunden"
+	profile := vm.settings.Profile(profileID)
+	if profile == nil {
+		return fmt.Errorf("Profil nicht gefunden")
 	}
-	if vpnSlicePath == "" {
-		return false, "vpn-slice nicht gefunden"
+	if sc.Command == "" {
+		delete(profile.SecretCommands, kind)
+	} else {
+		if profile.SecretCommands == nil {
+			profile.SecretCommands = map[string]models.SecretCommand{}
+		}
+		profile.SecretCommands[kind] = sc
 	}
-
-	// Verbindung asynchron starten
-	go vm.connectAsync(openconnectPath, vpnSlicePath, vpnPassword, certPassword)
-
-	return true, "VPN-Verbindung wird gestartet... (Status wird automatisch aktualisiert)"
+	return vm.saveSettingsLocked()
 }
 
-func (vm *Manager) connectAsync(openconnectPath, vpnSlicePath, vpnPassword, certPassword string) {
-	fmt.Printf("Starting async VPN connection...\n")
-
-	args := []string{
-		openconnectPath,
-		vm.Settings.VPNServer,
-		"--authgroup=" + vm.Settings.AuthGroup,
-		"--user=" + vm.Settings.Username,
-		"-c", vm.Settings.CertFile,
-		"--pid-file=/tmp/openconnect.pid",
-		"-s", vpnSlicePath + " " + vm.Settings.Networks,
-	}
-
-	cmd := exec.Command("sudo", args...)
-
-	// Pipes für Output
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		fmt.Printf("Stdout pipe error: %v\n", err)
-		return
+// SetSudoCommand konfiguriert das Abrufen des Sudo-Passworts per Kommando.
+func (vm *Manager) SetSudoCommand(sc models.SecretCommand) error {
+	if sc.Command != "" {
+		if err := validateSecretCommand(sc.Command); err != nil {
+			return err
+		}
 	}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		fmt.Printf("Stderr pipe error: %v\n", err)
-		return
-	}
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		fmt.Printf("Stdin pipe error: %v\n", err)
-		return
+	if sc.Command == "" {
+		vm.settings.SudoCommand = nil
+	} else {
+		vm.settings.SudoCommand = &sc
 	}
+	return vm.saveSettingsLocked()
+}
 
-	// Starten
-	err = cmd.Start()
-	if err != nil {
-		fmt.Printf("Start error: %v\n", err)
-		return
+// SudoCommand liefert das Kommando für das Sudo-Passwort (falls gesetzt).
+func (vm *Manager) SudoCommand() models.SecretCommand {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.settings.SudoCommand != nil {
+		return *vm.settings.SudoCommand
 	}
+	return models.SecretCommand{}
+}
 
-	fmt.Printf("OpenConnect process started with PID: %d\n", cmd.Process.Pid)
-
-	// Passwörter senden
-	go func() {
-		defer stdin.Close()
-		time.Sleep(2 * time.Second)
-
-		// Zertifikat-Passwort (falls vorhanden)
-		if certPassword != "" {
-			fmt.Printf("Sending certificate password...\n")
-			fmt.Fprintf(stdin, "%s\n", certPassword)
-			time.Sleep(2 * time.Second)
-		}
-
-		// VPN-Passwort
-		fmt.Printf("Sending VPN password...\n")
-		fmt.Fprintf(stdin, "%s\n", vpnPassword)
-	}()
-
-	// Output überwachen
-	connected := make(chan bool, 1)
-	failed := make(chan string, 1)
-
-	// Stdout überwachen
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-			fmt.Printf("VPN Output: %s\n", line)
-
-			if strings.Contains(line, "CSTP connected") ||
-				strings.Contains(line, "Configured as") ||
-				strings.Contains(line, "VPN tunnel running") ||
-				strings.Contains(line, "Connected tun") {
-				fmt.Printf("Connection established detected!\n")
-				connected <- true
-				return
-			}
-		}
-	}()
-
-	// Stderr überwachen
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			fmt.Printf("VPN Error: %s\n", line)
-
-			if strings.Contains(line, "Login failed") ||
-				strings.Contains(line, "Failed to decrypt") ||
-				strings.Contains(line, "Authentication failed") ||
-				strings.Contains(line, "Certificate verification failed") {
-				fmt.Printf("Connection failed detected!\n")
-				failed <- line
-				return
-			}
-		}
-	}()
-
-	// Auf Verbindungsstatus warten (aber nicht zu lange)
-	select {
-	case <-connected:
-		fmt.Printf("VPN successfully connected - process continues in background\n")
-		// Prozess läuft weiter, wir kehren zurück
-		return
-
-	case errMsg := <-failed:
-		fmt.Printf("VPN connection failed: %s\n", errMsg)
-		cmd.Process.Kill()
-		return
-
-	case <-time.After(45 * time.Second):
-		fmt.Printf("Connection timeout reached, checking if connected...\n")
-		// Nach Timeout prüfen ob Verbindung trotzdem da ist
-		time.Sleep(3 * time.Second)
-		if vm.IsConnected() {
-			fmt.Printf("VPN connected despite timeout\n")
-			return
-		}
+// Passwort-Status prüfen (nur Passwort-Speicher, Kommandos werden nicht ausgeführt)
+func (vm *Manager) HasStoredPasswords(profileID string) map[string]bool {
+	vpnPassword, _ := vm.secrets.Get(profileAccount(profileID, secretVPN))
+	certPassword, _ := vm.secrets.Get(profileAccount(profileID, secretCert))
+	wireguardKey, _ := vm.secrets.Get(profileAccount(profileID, secretWireGuard))
+	openvpnKey, _ := vm.secrets.Get(profileAccount(profileID, secretOpenVPN))
+	totpSeed, _ := vm.secrets.Get(profileAccount(profileID, secretTOTP))
+	clientKey, _ := vm.secrets.Get(profileAccount(profileID, secretClientKey))
+	sudoPassword, _ := vm.secrets.Get(sudoAccount)
 
-		fmt.Printf("Connection timeout - killing process\n")
-		cmd.Process.Kill()
-		return
+	return map[string]bool{
+		"vpn_password":  vpnPassword != "",
+		"cert_password": certPassword != "",
+		"wireguard_key": wireguardKey != "",
+		"openvpn_key":   openvpnKey != "",
+		"totp_seed":     totpSeed != "",
+		"client_key":    clientKey != "",
+		"sudo_password": sudoPassword != "",
 	}
-
-	// Dieser Code wird nie erreicht, aber zur Sicherheit
-	// cmd.Wait() NICHT aufrufen - das würde ewig warten!
 }
 
-// Verbesserte IsConnected Methode
-func (vm *Manager) IsConnected() bool {
-	// PID-Datei prüfen
-	if fileExists("/tmp/openconnect.pid") {
-		if pidData, err := os.ReadFile("/tmp/openconnect.pid"); err == nil {
-			pid := strings.TrimSpace(string(pidData))
-			if pid != "" && exec.Command("kill", "-0", pid).Run() == nil {
-				return true
-			}
-		}
-		os.Remove("/tmp/openconnect.pid")
-	}
-
-	// Prozess-Suche
-	if output, err := exec.Command("pgrep", "-f", "openconnect").Output(); err == nil {
-		return len(strings.TrimSpace(string(output))) > 0
+// Passwörter eines Profils löschen (z.B. beim Löschen des Profils)
+func (vm *Manager) ClearStoredPasswords(profileID string) error {
+	if profileID == "" {
+		return nil
 	}
 
-	// Netzwerk-Interface prüfen
-	if output, err := exec.Command("ifconfig").Output(); err == nil {
-		return strings.Contains(string(output), "192.168.255.")
+	for _, kind := range profileSecretKinds {
+		vm.secrets.Delete(profileAccount(profileID, kind))
 	}
 
-	return false
+	return nil
 }
 
-func (vm *Manager) connectDirect(openconnectPath, vpnSlicePath, vpnPassword, certPassword string) (bool, string) {
-	args := []string{
-		openconnectPath,
-		vm.Settings.VPNServer,
-		"--authgroup=" + vm.Settings.AuthGroup,
-		"--user=" + vm.Settings.Username,
-		"-c", vm.Settings.CertFile,
-		"--pid-file=/tmp/openconnect.pid",
-		"-s", vpnSlicePath + " " + vm.Settings.Networks,
+// Connect startet die Verbindung für das angegebene Profil
+// (leere ID = Standardprofil).
+func (vm *Manager) Connect(profileID string) (bool, string) {
+	switch vm.State() {
+	case StateStarting, StateAuthenticating, StateReconnecting:
+		return false, "VPN-Verbindung wird bereits aufgebaut"
+	case StateDisconnecting:
+		return false, "VPN-Verbindung wird gerade getrennt"
 	}
 
-	cmd := exec.Command("sudo", args...)
-
-	// Pipes für Output
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return false, fmt.Sprintf("Stdout pipe error: %v", err)
+	if vm.IsConnected() {
+		return false, "VPN ist bereits verbunden"
 	}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return false, fmt.Sprintf("Stderr pipe error: %v", err)
+	var profile models.Profile
+	var ok bool
+	if profileID == "" {
+		profile, ok = vm.DefaultProfile()
+	} else {
+		profile, ok = vm.Profile(profileID)
 	}
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return false, fmt.Sprintf("Stdin pipe error: %v", err)
+	if !ok {
+		return false, "Profil nicht gefunden"
 	}
 
-	// Starten
-	err = cmd.Start()
+	session, err := vm.prepareSession(profile)
 	if err != nil {
-		return false, fmt.Sprintf("Start error: %v", err)
+		return false, err.Error()
 	}
 
-	// Passwörter senden
-	go func() {
-		defer stdin.Close()
-		time.Sleep(2 * time.Second)
-
-		// Zertifikat-Passwort (falls vorhanden)
-		if certPassword != "" {
-			fmt.Fprintf(stdin, "%s\n", certPassword)
-			time.Sleep(2 * time.Second)
-		}
-
-		// VPN-Passwort
-		fmt.Fprintf(stdin, "%s\n", vpnPassword)
-	}()
-
-	// Output überwachen bis Verbindung hergestellt
-	connected := make(chan bool, 1)
-	failed := make(chan string, 1)
-
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-			fmt.Printf("VPN Output: %s\n", line)
+	vm.mu.Lock()
+	if !canTransition(vm.state, StateStarting) {
+		vm.mu.Unlock()
+		return false, "VPN-Verbindung wird bereits aufgebaut"
+	}
+	vm.logs.startSession(profile.Name)
+	vm.activeProfile = profile.ID
+	vm.userStopped = false
+	vm.resetReconnectLocked()
+	vm.certPrompt = nil
+	vm.setStateLocked(StateStarting, "")
+	vm.mu.Unlock()
 
-			if strings.Contains(line, "CSTP connected") ||
-				strings.Contains(line, "Configured as") {
-				connected <- true
-				return
-			}
-		}
-	}()
-
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			fmt.Printf("VPN Error: %s\n", line)
-
-			if strings.Contains(line, "Login failed") ||
-				strings.Contains(line, "Failed to decrypt") ||
-				strings.Contains(line, "Authentication failed") {
-				failed <- line
-				return
-			}
-		}
-	}()
+	// Verbindung asynchron starten
+	go vm.runSession(session)
 
-	// Warten auf Erfolg oder Fehler
-	select {
-	case <-connected:
-		fmt.Printf("VPN erfolgreich verbunden - Prozess läuft weiter\n")
-		// NICHT cmd.Wait() aufrufen - Prozess soll weiterlaufen!
-		return true, "VPN erfolgreich verbunden"
+	return true, "VPN-Verbindung \"" + profile.Name + "\" wird gestartet... (Status wird automatisch aktualisiert)"
+}
 
-	case errMsg := <-failed:
-		cmd.Process.Kill()
-		return false, fmt.Sprintf("Verbindung fehlgeschlagen: %s", errMsg)
+// ActiveProfile liefert die Profil-ID der zuletzt gestarteten Verbindung.
+func (vm *Manager) ActiveProfile() string {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.activeProfile
+}
 
-	case <-time.After(60 * time.Second):
-		cmd.Process.Kill()
-		return false, "Timeout beim Verbinden"
+// IsConnected prüft über das Backend des aktiven (sonst des Standard-)
+// Profils, ob ein Tunnel besteht.
+func (vm *Manager) IsConnected() bool {
+	profile, ok := vm.Profile(vm.ActiveProfile())
+	if !ok {
+		profile, ok = vm.DefaultProfile()
+	}
+	backend, err := vm.backendFor(profile)
+	if !ok || err != nil {
+		return false
 	}
+	return backend.Status(profile)
 }
 
 func (vm *Manager) Disconnect() (bool, string) {
+	// Ab hier ist jedes Prozessende gewollt
+	vm.mu.Lock()
+	vm.userStopped = true
+	vm.resetReconnectLocked()
+	vm.cancelSSOLocked()
+	vm.mu.Unlock()
+	vm.openconnect.cancelAuth()
+
 	if !vm.IsConnected() {
+		vm.setState(StateDisconnected, "")
 		return true, "VPN ist bereits getrennt"
 	}
 
-	// Prüfe ob passwordless sudo funktioniert
-	testCmd := exec.Command("sudo", "-n", "killall", "--help")
-	if testCmd.Run() != nil {
-		// Sudo-Passwort aus Keychain versuchen
-		sudoPassword, err := vm.GetSudoPassword()
-		if err == nil && sudoPassword != "" {
-			return vm.disconnectWithExpect(sudoPassword)
-		}
-		return false, "Sudo-Berechtigung erforderlich. Bitte Sudo-Passwort in Einstellungen speichern oder passwordless sudo konfigurieren."
+	profile, ok := vm.Profile(vm.ActiveProfile())
+	if !ok {
+		profile, _ = vm.DefaultProfile()
 	}
 
-	// Verwende direkte Methode (passwordless sudo)
-	return vm.disconnectDirect()
-}
-
-func (vm *Manager) disconnectWithExpect(sudoPassword string) (bool, string) {
-	// Temporäres expect-Script erstellen
-	expectScript, err := os.CreateTemp("", "vpn-disconnect-*.exp")
+	sudoPassword, err := vm.sudoPasswordFor(profile)
 	if err != nil {
-		return false, "Konnte disconnect expect-script nicht erstellen"
-	}
-	defer os.Remove(expectScript.Name())
-	defer expectScript.Close()
-
-	scriptContent := fmt.Sprintf(`#!/usr/bin/expect -f
-set timeout 15
-
-# Killall versuchen
-spawn sudo killall -KILL openconnect
-expect {
-    "Password:" {
-        send "%s\r"
-        expect eof
-    }
-    eof {}
-    timeout {}
-}
-
-# PID-Datei löschen
-spawn sudo rm -f /tmp/openconnect.pid
-expect {
-    "Password:" {
-        send "%s\r"
-        expect eof
-    }
-    eof {}
-    timeout {}
-}
-
-# Pkill als Fallback
-spawn sudo pkill -KILL -f openconnect
-expect {
-    "Password:" {
-        send "%s\r"
-        expect eof
-    }
-    eof {}
-    timeout {}
-}
-
-puts "Disconnect-Befehle ausgeführt"
-exit 0
-`, sudoPassword, sudoPassword, sudoPassword)
-
-	expectScript.WriteString(scriptContent)
-	expectScript.Close()
-	os.Chmod(expectScript.Name(), 0700)
-
-	// Expect-Script ausführen
-	cmd := exec.Command("expect", expectScript.Name())
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err = cmd.Run()
-
-	fmt.Printf("Disconnect Expect Output: %s\n", stdout.String())
-	if stderr.Len() > 0 {
-		fmt.Printf("Disconnect Expect Error: %s\n", stderr.String())
+		return false, err.Error()
 	}
-
-	// Warten und prüfen
-	time.Sleep(3 * time.Second)
-
-	if !vm.IsConnected() {
-		return true, "VPN mit expect getrennt"
+	s, err := vm.newSession(profile, sudoPassword)
+	if err != nil {
+		return false, err.Error()
 	}
 
-	return false, "Disconnect mit expect unsicher"
-}
-
-func (vm *Manager) disconnectDirect() (bool, string) {
-	commands := [][]string{
-		{"sudo", "killall", "-KILL", "openconnect"},
-		{"sudo", "pkill", "-KILL", "-f", "openconnect"},
-		{"sudo", "rm", "-f", "/tmp/openconnect.pid"},
-	}
+	vm.setState(StateDisconnecting, "")
 
-	var messages []string
-	for _, cmdArgs := range commands {
-		cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
-		if err := cmd.Run(); err == nil {
-			messages = append(messages, strings.Join(cmdArgs, " ")+" OK")
+	if err := s.backend.Disconnect(s); err != nil {
+		if vm.IsConnected() {
+			// Tunnel läuft weiter
+			vm.setState(StateConnected, err.Error())
 		} else {
-			messages = append(messages, strings.Join(cmdArgs, " ")+" FAILED: "+err.Error())
+			vm.setState(StateFailed, err.Error())
 		}
-		time.Sleep(time.Second)
+		return false, err.Error()
 	}
 
-	time.Sleep(2 * time.Second)
-
-	if !vm.IsConnected() {
-		return true, "VPN getrennt: " + strings.Join(messages, "; ")
-	}
-
-	return false, "Disconnect unsicher: " + strings.Join(messages, "; ")
+	vm.setState(StateDisconnected, "")
+	return true, "VPN getrennt"
 }
 
-func (vm *Manager) findOpenConnectPath() string {
-	paths := []string{"/usr/local/bin/openconnect", "/opt/homebrew/bin/openconnect"}
-	for _, path := range paths {
-		if fileExists(path) {
-			return path
-		}
-	}
-	if path, err := exec.LookPath("openconnect"); err == nil {
-		return path
-	}
-	return ""
+// stopRequested meldet, ob der Benutzer seit dem letzten Verbinden getrennt hat.
+func (vm *Manager) stopRequested() bool {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.userStopped
 }
 
-func (vm *Manager) findVpnSlicePath() string {
-	homeDir, _ := os.UserHomeDir()
-	paths := []string{
-		filepath.Join(homeDir, ".bin", "vpnslice"),
-		filepath.Join(homeDir, ".local", "bin", "vpn-slice"),
-		"/usr/local/bin/vpn-slice",
-		"/opt/homebrew/bin/vpn-slice",
-	}
-
-	for _, path := range paths {
-		if fileExists(path) && exec.Command(path, "--version").Run() == nil {
-			return path
+// waitDisconnected wartet, bis das Backend keinen Tunnel mehr meldet.
+func waitDisconnected(b Backend, profile models.Profile, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !b.Status(profile) {
+			return true
 		}
+		time.Sleep(500 * time.Millisecond)
 	}
-	return ""
-}
-
-func (vm *Manager) GetCertDir() string {
-	return vm.certDir
+	return !b.Status(profile)
 }
 
 func fileExists(filename string) bool {
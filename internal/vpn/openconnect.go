@@ -0,0 +1,555 @@
+package vpn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"vpn-web/internal/helper"
+	"vpn-web/internal/models"
+)
+
+// openconnectBackend startet openconnect mit vpn-web als vpnc-script per
+// sudo und überwacht den Prozess.
+type openconnectBackend struct {
+	pidFile string
+	helper  *helper.Client // privilegierter Helper; nicht installiert = sudo
+
+	// Programmpfade; leer = an den üblichen Orten suchen
+	openconnectPath string
+
+	authMu  sync.Mutex
+	authCmd *exec.Cmd // laufendes openconnect --authenticate
+
+	tunnelMu  sync.Mutex
+	tunnelCmd *exec.Cmd // per sudo gestarteter Tunnel, bis er beendet ist
+}
+
+// tunnelPattern findet in der Prozessliste nur den Tunnel (und sudo davor),
+// nicht das openconnect --authenticate der Anmeldung.
+const tunnelPattern = "openconnect .*--cookie-on-stdin"
+
+func newOpenConnectBackend() *openconnectBackend {
+	return &openconnectBackend{
+		pidFile: "/tmp/openconnect.pid",
+		helper:  helper.NewClient(helper.DefaultSocket),
+	}
+}
+
+func (b *openconnectBackend) Name() string {
+	return BackendOpenConnect
+}
+
+func (b *openconnectBackend) Validate(s *Session) error {
+	profile := s.Profile
+	proto, err := openconnectProtocolFor(profile.Protocol)
+	if err != nil {
+		return err
+	}
+
+	if profile.VPNServer == "" {
+		return errors.New("VPN-Server erforderlich")
+	}
+	if proto.AuthGroupRequired && profile.AuthGroup == "" {
+		return fmt.Errorf("%s erforderlich (%s)", proto.AuthGroup, proto.Label)
+	}
+	if (proto.CertRequired || profile.CertFile != "") && !fileExists(profile.CertFile) {
+		return errors.New("Kein gültiges Zertifikat ausgewählt")
+	}
+	if strings.HasSuffix(profile.CertFile, certKindExt[certKindPEM]) {
+		if profile.KeyFile != "" && !fileExists(profile.KeyFile) {
+			return errors.New("Privater Schlüssel nicht gefunden")
+		}
+		if profile.KeyFile == "" {
+			if key, err := s.Secret(secretClientKey); err != nil {
+				return err
+			} else if key == "" {
+				return errors.New("Zum PEM-Zertifikat fehlt der private Schlüssel")
+			}
+		}
+	}
+	if profile.CAFile != "" && !fileExists(profile.CAFile) {
+		return errors.New("CA-Bundle nicht gefunden")
+	}
+	if profile.CertFile != "" && profile.CertInfo != nil {
+		if w, ok := certWarning(profile, s.vm.CertWarnDays(), time.Now()); ok {
+			s.Logf(LogWarn, "%s", w.Message)
+		}
+	}
+
+	// bei SSO übernimmt der Identity Provider Benutzername und Passwort
+	if !profile.SSO {
+		if profile.Username == "" {
+			return errors.New("Benutzername erforderlich")
+		}
+
+		// Passwörter aus dem Passwort-Speicher bzw. per Kommando laden
+		vpnPassword, err := s.Secret(secretVPN)
+		if err != nil {
+			return err
+		}
+		if vpnPassword == "" {
+			return errors.New("VPN-Passwort nicht im Passwort-Speicher gefunden. Bitte in den Einstellungen speichern.")
+		}
+	}
+
+	if _, err := s.Secret(secretCert); err != nil {
+		return err
+	}
+	if _, err := s.Secret(secretTOTP); err != nil {
+		return err
+	}
+
+	if b.findOpenConnectPath() == "" {
+		return errors.New("openconnect nicht gefunden")
+	}
+	if _, err := vpncScript(profile.Routes, profile.DNSDomains); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Connect meldet sich zuerst ohne root-Rechte am Gateway an (siehe
+// authenticate) und startet dann mit dem erhaltenen Cookie den Tunnel per
+// sudo. Passwörter erreichen den root-Prozess nie.
+func (b *openconnectBackend) Connect(s *Session) *ExitInfo {
+	s.Logf(LogInfo, "Starting async VPN connection...")
+	proto, _ := openconnectProtocolFor(s.Profile.Protocol)
+
+	auth, exit := b.authenticate(s, proto)
+	if exit != nil {
+		return exit
+	}
+	if s.Profile.PinServerCert && s.Profile.ServerCert == "" && auth.fingerprint != "" {
+		s.vm.pinServerCert(s.Profile.ID, s.Profile.VPNServer, auth.fingerprint)
+	}
+	// während der Anmeldung getrennt: keinen Tunnel mehr starten
+	if s.vm.stopRequested() {
+		return &ExitInfo{Code: -1, Reason: "Verbindungsaufbau abgebrochen", Time: time.Now()}
+	}
+	return b.runTunnel(s, proto, auth)
+}
+
+// runTunnel startet openconnect mit --cookie-on-stdin über den Helper bzw.
+// per sudo und überwacht den Prozess bis zu seinem Ende.
+func (b *openconnectBackend) runTunnel(s *Session, proto OpenConnectProtocol, auth *authCookie) *ExitInfo {
+	profile := s.Profile
+
+	// Output überwachen und daraus den Zustand ableiten. Nachfragen kann
+	// der Tunnel-Prozess nicht beantwortet bekommen.
+	connected := make(chan bool, 1)
+	failed := make(chan string, 1)
+	certs := newServerCertWatcher()
+	line := func(source, line string) {
+		s.Output(source, line)
+		handleOutputLine(s, proto, certs, line, connected, failed)
+	}
+
+	var exited <-chan ExitInfo
+	var stop func()
+	if b.helper.Available() {
+		var notStarted *ExitInfo
+		if exited, notStarted = b.startHelperTunnel(s, proto, auth, line); notStarted != nil {
+			return notStarted
+		}
+		stop = func() {
+			if err := b.helper.Stop(); err != nil {
+				s.Logf(LogWarn, "Stopping openconnect failed: %v", err)
+			}
+		}
+	} else {
+		cmd, sudoExited, notStarted := b.startSudoTunnel(s, proto, auth, line, failed)
+		if notStarted != nil {
+			return notStarted
+		}
+		exited = sudoExited
+		stop = func() { b.stopProcess(s, cmd) }
+	}
+	// Trennen kam zwischen Prüfung und Start und hat den Tunnel womöglich
+	// noch nicht gesehen
+	if s.vm.stopRequested() {
+		stop()
+		exit := <-exited
+		return &exit
+	}
+	s.Progress("Anmeldung erfolgreich, starte Tunnel zu " + profile.VPNServer + "...")
+
+	// Auf Verbindungsstatus warten (aber nicht zu lange)
+	select {
+	case <-connected:
+		s.Logf(LogInfo, "VPN successfully connected - supervising process")
+		s.Connected()
+
+	case errMsg := <-failed:
+		s.Logf(LogError, "VPN connection failed: %s", errMsg)
+		s.Fail(errMsg)
+		stop()
+
+	case exit := <-exited:
+		return &exit
+
+	case <-time.After(45 * time.Second):
+		s.Logf(LogWarn, "Connection timeout reached, checking if connected...")
+		// Nach Timeout prüfen ob Verbindung trotzdem da ist
+		time.Sleep(3 * time.Second)
+		if b.Status(profile) {
+			s.Logf(LogInfo, "VPN connected despite timeout")
+			s.Connected()
+			break
+		}
+
+		s.Logf(LogError, "Connection timeout - stopping process")
+		stop()
+		exit := <-exited
+		exit.Reason = "Zeitüberschreitung beim Verbinden (" + exit.Reason + ")"
+		return &exit
+	}
+
+	exit := <-exited
+	return &exit
+}
+
+// startHelperTunnel lässt den privilegierten Helper openconnect starten
+// (siehe internal/helper); weder sudo noch das Sudo-Passwort sind nötig.
+func (b *openconnectBackend) startHelperTunnel(s *Session, proto OpenConnectProtocol, auth *authCookie, line func(source, line string)) (<-chan ExitInfo, *ExitInfo) {
+	spec := helper.TunnelSpec{
+		Protocol:   proto.Name,
+		Server:     auth.target(),
+		ServerCert: auth.fingerprint,
+		Resolve:    auth.resolve,
+		Networks:   s.Profile.Routes.Include,
+		Exclude:    s.Profile.Routes.Exclude,
+		Domains:    s.Profile.DNSDomains,
+		Cookie:     auth.cookie,
+	}
+	pid, done, err := b.helper.StartTunnel(spec, line)
+	if err != nil {
+		return nil, &ExitInfo{Code: -1, Reason: "openconnect nicht gestartet: " + err.Error(), Time: time.Now()}
+	}
+	s.Logf(LogInfo, "OpenConnect process started by helper with PID: %d (protocol %s)", pid, proto.Name)
+
+	exited := make(chan ExitInfo, 1)
+	go func() {
+		msg := <-done
+		exited <- ExitInfo{Code: msg.Code, Reason: msg.Reason, Time: time.Now()}
+	}()
+	return exited, nil
+}
+
+// startSudoTunnel startet openconnect per sudo, wenn kein Helper
+// installiert ist.
+func (b *openconnectBackend) startSudoTunnel(s *Session, proto OpenConnectProtocol, auth *authCookie, line func(source, line string), failed chan<- string) (*exec.Cmd, <-chan ExitInfo, *ExitInfo) {
+	notStarted := func(reason string) *ExitInfo {
+		return &ExitInfo{Code: -1, Reason: "openconnect nicht gestartet: " + reason, Time: time.Now()}
+	}
+
+	script, err := vpncScript(s.Profile.Routes, s.Profile.DNSDomains)
+	if err != nil {
+		return nil, nil, notStarted(err.Error())
+	}
+	args := []string{
+		b.findOpenConnectPath(),
+		"--cookie-on-stdin",
+		"--protocol=" + proto.Name,
+		"--pid-file=" + b.pidFile,
+		"-s", script,
+	}
+	if auth.fingerprint != "" {
+		args = append(args, "--servercert="+auth.fingerprint)
+	}
+	if auth.resolve != "" {
+		args = append(args, "--resolve="+auth.resolve)
+	}
+	args = append(args, auth.target())
+
+	cmd := exec.Command("sudo", sudoArgs(s.SudoPassword, args...)...)
+
+	// Pipes für Output
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, notStarted(fmt.Sprintf("Stdout pipe error: %v", err))
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, notStarted(fmt.Sprintf("Stderr pipe error: %v", err))
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, notStarted(fmt.Sprintf("Stdin pipe error: %v", err))
+	}
+
+	// Starten
+	err = cmd.Start()
+	if err != nil {
+		return nil, nil, notStarted(fmt.Sprintf("Start error: %v", err))
+	}
+
+	s.Logf(LogInfo, "OpenConnect process started with PID: %d (protocol %s)", cmd.Process.Pid, proto.Name)
+	b.setTunnelCmd(cmd)
+
+	// Sudo-Passwort (sudo -S liest genau die erste Zeile), danach das Cookie
+	if s.SudoPassword != "" {
+		fmt.Fprintf(stdin, "%s\n", s.SudoPassword)
+	}
+	fmt.Fprintf(stdin, "%s\n", auth.cookie)
+	stdin.Close()
+
+	watched := watchOutput(cmd, stdout, stderr, "openconnect", line, func(source, text string) {
+		s.Output(source, strings.TrimSpace(text))
+		msg := fmt.Sprintf("Tunnelaufbau fehlgeschlagen: openconnect fragt nach %q (Cookie abgelehnt?)", strings.TrimSpace(text))
+		s.SetState(StateFailed, msg)
+		notify(failed, msg)
+	})
+	exited := make(chan ExitInfo, 1)
+	go func() {
+		exit := <-watched
+		b.clearTunnelCmd(cmd)
+		exited <- exit
+	}()
+	return cmd, exited, nil
+}
+
+func (b *openconnectBackend) setTunnelCmd(cmd *exec.Cmd) {
+	b.tunnelMu.Lock()
+	defer b.tunnelMu.Unlock()
+	b.tunnelCmd = cmd
+}
+
+// clearTunnelCmd vergisst cmd, sofern inzwischen kein neuer Tunnel läuft.
+func (b *openconnectBackend) clearTunnelCmd(cmd *exec.Cmd) {
+	b.tunnelMu.Lock()
+	defer b.tunnelMu.Unlock()
+	if b.tunnelCmd == cmd {
+		b.tunnelCmd = nil
+	}
+}
+
+func (b *openconnectBackend) tunnelRunning() bool {
+	b.tunnelMu.Lock()
+	defer b.tunnelMu.Unlock()
+	return b.tunnelCmd != nil
+}
+
+// watchOutput liest stdout und stderr bis EOF, damit openconnect nie an
+// einer vollen Pipe blockiert, und sammelt danach den Prozess ein (kein
+// Zombie). Eingabeaufforderungen ohne Zeilenumbruch gehen an prompt.
+func watchOutput(cmd *exec.Cmd, stdout, stderr io.Reader, name string, line func(source, line string), prompt func(source, text string)) <-chan ExitInfo {
+	var wg sync.WaitGroup
+	watch := func(r io.Reader, source string) {
+		defer wg.Done()
+		scanOutput(r, func(l string) {
+			line(source, l)
+		}, func(text string) {
+			prompt(source, text)
+		})
+	}
+	wg.Add(2)
+	go watch(stdout, LogSourceStdout)
+	go watch(stderr, LogSourceStderr)
+
+	exited := make(chan ExitInfo, 1)
+	go func() {
+		wg.Wait()
+		exited <- reap(cmd, name)
+	}()
+	return exited
+}
+
+// handleOutputLine wertet eine Ausgabezeile von sudo/openconnect aus und
+// führt den passenden Zustandswechsel durch.
+func handleOutputLine(s *Session, proto OpenConnectProtocol, certs *serverCertWatcher, line string, connected chan<- bool, failed chan<- string) {
+	if isSudoAuthFailure(line) {
+		msg := "Sudo-Authentifizierung fehlgeschlagen: " + line
+		s.SetState(StateFailed, msg)
+		notify(failed, msg)
+		return
+	}
+
+	if pin, changed, ok := certs.check(line); ok {
+		msg := s.serverCertFailure(certs, pin, changed)
+		s.SetState(StateFailed, msg)
+		notify(failed, msg)
+		return
+	}
+
+	next, ok := stateFromOutput(proto, s.State(), line)
+	if !ok {
+		return
+	}
+
+	switch next {
+	case StateConnected:
+		if s.SetState(StateConnected, "") {
+			notify(connected, true)
+		}
+	case StateFailed:
+		s.SetState(StateFailed, line)
+		notify(failed, line)
+	default:
+		s.SetState(next, "")
+	}
+}
+
+// notify sendet ohne zu blockieren (Kanäle sind gepuffert, nur das erste Ereignis zählt).
+func notify[T any](ch chan<- T, v T) {
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
+// stopProcess beendet einen gescheiterten Verbindungsversuch. sudo leitet
+// SIGTERM an openconnect weiter; lässt sich sudo nicht signalisieren, wird
+// openconnect direkt per sudo beendet.
+func (b *openconnectBackend) stopProcess(s *Session, cmd *exec.Cmd) {
+	if err := cmd.Process.Signal(syscall.SIGTERM); err == nil {
+		return
+	}
+	if err := runSudo(s.SudoPassword, b.killArgs("TERM")...); err != nil {
+		s.Logf(LogWarn, "Stopping openconnect failed: %v", err)
+	}
+}
+
+// Status fragt den Helper, sonst prüft es den selbst gestarteten Tunnel,
+// PID-Datei, Prozessliste und als Notlösung die Interfaces. Eine laufende
+// Anmeldung (openconnect --authenticate) zählt nicht als Tunnel.
+func (b *openconnectBackend) Status(profile models.Profile) bool {
+	// vom Helper gestarteter Tunnel
+	if b.helper.Available() {
+		if running, err := b.helper.Status(); err == nil {
+			return running
+		}
+	}
+
+	// per sudo gestarteter Tunnel, dessen Prozess noch nicht beendet ist
+	if b.tunnelRunning() {
+		return true
+	}
+
+	// PID-Datei prüfen
+	if fileExists(b.pidFile) {
+		if pid := b.readPidFile(); pid != "" && exec.Command("kill", "-0", pid).Run() == nil {
+			return true
+		}
+		os.Remove(b.pidFile)
+	}
+
+	// Prozess-Suche, z. B. nach einem Neustart von vpn-web
+	if output, err := exec.Command("pgrep", "-f", tunnelPattern).Output(); err == nil {
+		return len(b.withoutAuthPID(strings.Fields(string(output)))) > 0
+	}
+
+	// Netzwerk-Interface prüfen
+	if output, err := exec.Command("ifconfig").Output(); err == nil {
+		return strings.Contains(string(output), "192.168.255.")
+	}
+
+	return false
+}
+
+// Disconnect beendet openconnect per SIGTERM (damit das vpnc-script Routen
+// aufräumen kann), nach Timeout per SIGKILL, und entfernt die PID-Datei.
+func (b *openconnectBackend) Disconnect(s *Session) error {
+	// Abbruch während der Anmeldung: es läuft noch kein Tunnel
+	b.cancelAuth()
+	if !b.Status(s.Profile) {
+		// Tunnel schon beendet: Reste eines Absturzes, den der Helper nicht
+		// mitbekommen hat, entfernen
+		if b.helper.Available() {
+			if err := b.helper.Cleanup(); err != nil {
+				s.Logf(LogWarn, "Helper cleanup failed: %v", err)
+			}
+		}
+		return nil
+	}
+
+	// Der Helper wartet selbst auf das Ende und räumt nach SIGKILL die
+	// Routen auf
+	if b.helper.Available() {
+		if err := b.helper.Stop(); err != nil {
+			return fmt.Errorf("Beenden von openconnect fehlgeschlagen: %v", err)
+		}
+		return nil
+	}
+
+	if err := runSudo(s.SudoPassword, b.killArgs("TERM")...); err != nil {
+		return disconnectError(err)
+	}
+
+	if !waitDisconnected(b, s.Profile, 10*time.Second) {
+		s.Logf(LogWarn, "openconnect did not exit after SIGTERM, sending SIGKILL")
+		if err := runSudo(s.SudoPassword, b.killArgs("KILL")...); err != nil {
+			return disconnectError(err)
+		}
+		waitDisconnected(b, s.Profile, 3*time.Second)
+	}
+
+	if err := runSudo(s.SudoPassword, "rm", "-f", b.pidFile); err != nil {
+		s.Logf(LogWarn, "Removing pid file failed: %v", err)
+	}
+
+	if b.Status(s.Profile) {
+		return errors.New("Disconnect unsicher: openconnect läuft weiterhin")
+	}
+	return nil
+}
+
+// killArgs signalisiert openconnect über die PID-Datei, fehlt sie, über
+// den Prozessnamen.
+func (b *openconnectBackend) killArgs(signal string) []string {
+	if pid := b.readPidFile(); pid != "" {
+		return []string{"kill", "-" + signal, pid}
+	}
+	return []string{"pkill", "-" + signal, "-x", "openconnect"}
+}
+
+// withoutAuthPID entfernt die PID der laufenden Anmeldung aus pids.
+func (b *openconnectBackend) withoutAuthPID(pids []string) []string {
+	b.authMu.Lock()
+	defer b.authMu.Unlock()
+	if b.authCmd == nil {
+		return pids
+	}
+	auth := strconv.Itoa(b.authCmd.Process.Pid)
+	return slices.DeleteFunc(pids, func(pid string) bool { return pid == auth })
+}
+
+func disconnectError(err error) error {
+	if sudoErr, ok := err.(*SudoError); ok && !sudoErr.Auth {
+		return errors.New("Beenden von openconnect fehlgeschlagen: " + sudoErr.Error())
+	}
+	return err
+}
+
+func (b *openconnectBackend) readPidFile() string {
+	pidData, err := os.ReadFile(b.pidFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(pidData))
+}
+
+func (b *openconnectBackend) findOpenConnectPath() string {
+	if b.openconnectPath != "" {
+		return b.openconnectPath
+	}
+	paths := []string{"/usr/local/bin/openconnect", "/opt/homebrew/bin/openconnect"}
+	for _, path := range paths {
+		if fileExists(path) {
+			return path
+		}
+	}
+	if path, err := exec.LookPath("openconnect"); err == nil {
+		return path
+	}
+	return ""
+}
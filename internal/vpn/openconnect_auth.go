@@ -0,0 +1,270 @@
+package vpn
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authCookie ist das Ergebnis von openconnect --authenticate.
+type authCookie struct {
+	cookie      string
+	host        string
+	connectURL  string
+	fingerprint string // pin-sha256:... des Servers, für --servercert
+	resolve     string // host:ip, für --resolve
+}
+
+// target ist das Ziel für den Tunnel-Prozess.
+func (a *authCookie) target() string {
+	if a.connectURL != "" {
+		return a.connectURL
+	}
+	return a.host
+}
+
+// set übernimmt eine Zeile der Form KEY='value' (Shell-Quoting von
+// openconnect --authenticate). ok ist false bei anderen Zeilen.
+func (a *authCookie) set(line string) (key string, ok bool) {
+	key, value, found := strings.Cut(line, "=")
+	if !found {
+		return "", false
+	}
+	value = strings.ReplaceAll(value, `'\''`, `'`)
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "'"), "'")
+
+	switch key {
+	case "COOKIE":
+		a.cookie = value
+	case "HOST":
+		a.host = value
+	case "CONNECT_URL":
+		a.connectURL = value
+	case "FINGERPRINT":
+		a.fingerprint = value
+	case "RESOLVE":
+		a.resolve = value
+	default:
+		return "", false
+	}
+	return key, true
+}
+
+// authenticate meldet sich als normaler Benutzer mit openconnect
+// --authenticate am Gateway an. Passwörter, Zertifikat-Passwort und
+// TOTP-Code gehen nur an diesen Prozess; der Tunnel bekommt das Cookie.
+// Liefert das Cookie oder das Ende des Anmeldeprozesses.
+func (b *openconnectBackend) authenticate(s *Session, proto OpenConnectProtocol) (*authCookie, *ExitInfo) {
+	profile := s.Profile
+	vpnPassword, _ := s.Secret(secretVPN)
+	certPassword, _ := s.Secret(secretCert)
+	totpSeed, _ := s.Secret(secretTOTP)
+
+	responder := newPromptResponder(promptSecrets{
+		Username:     profile.Username,
+		Password:     vpnPassword,
+		CertPassword: certPassword,
+		AuthGroup:    profile.AuthGroup,
+		TOTPSeed:     totpSeed,
+	})
+
+	args := []string{
+		"--authenticate",
+		"--protocol=" + proto.Name,
+	}
+	if profile.Username != "" {
+		args = append(args, "--user="+profile.Username)
+	}
+	if proto.AuthGroup != "" && profile.AuthGroup != "" {
+		args = append(args, "--authgroup="+profile.AuthGroup)
+	}
+	if profile.CertFile != "" {
+		args = append(args, "-c", profile.CertFile)
+	}
+	if profile.KeyFile != "" {
+		args = append(args, "-k", profile.KeyFile)
+	} else if key, _ := s.Secret(secretClientKey); key != "" && strings.HasSuffix(profile.CertFile, certKindExt[certKindPEM]) {
+		// in vpn-web erzeugter Schlüssel: nur für die Dauer der Anmeldung als Datei
+		path, cleanup, err := writeTempKey(key)
+		if err != nil {
+			return nil, &ExitInfo{Code: -1, Reason: "Anmeldung nicht gestartet: " + err.Error(), Time: time.Now()}
+		}
+		defer cleanup()
+		args = append(args, "-k", path)
+	}
+	if profile.CAFile != "" {
+		args = append(args, "--cafile="+profile.CAFile)
+	}
+	if profile.ServerCert != "" {
+		args = append(args, "--servercert="+profile.ServerCert)
+	}
+
+	notStarted := func(reason string) *ExitInfo {
+		return &ExitInfo{Code: -1, Reason: "Anmeldung nicht gestartet: " + reason, Time: time.Now()}
+	}
+
+	// SSO: openconnect ruft vpn-web als Browser auf (siehe RunExternalBrowser),
+	// die Oberfläche zeigt dann die Login-URL des Identity Providers an.
+	var sso *ssoAttempt
+	timeout := 60 * time.Second
+	if profile.SSO {
+		self, err := os.Executable()
+		if err != nil {
+			return nil, notStarted(err.Error())
+		}
+		args = append(args, "--external-browser="+self)
+		timeout = ssoTimeout
+	}
+	args = append(args, profile.VPNServer)
+
+	cmd := exec.Command(b.findOpenConnectPath(), args...)
+	if profile.SSO {
+		var env string
+		sso, env = s.vm.beginSSO(profile.ID)
+		defer s.vm.endSSO(sso)
+		cmd.Env = append(os.Environ(), env)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, notStarted(fmt.Sprintf("Stdout pipe error: %v", err))
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, notStarted(fmt.Sprintf("Stderr pipe error: %v", err))
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, notStarted(fmt.Sprintf("Stdin pipe error: %v", err))
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, notStarted(fmt.Sprintf("Start error: %v", err))
+	}
+
+	b.setAuthCmd(cmd)
+	defer b.setAuthCmd(nil)
+
+	s.Logf(LogInfo, "OpenConnect authentication started with PID: %d (protocol %s)", cmd.Process.Pid, proto.Name)
+	s.SetState(StateAuthenticating, "")
+	if sso != nil {
+		s.Progress("Anmeldung per Browser bei " + profile.VPNServer + "...")
+	} else {
+		s.Progress("Anmeldung bei " + profile.VPNServer + "...")
+	}
+
+	failed := make(chan string, 1)
+	certs := newServerCertWatcher()
+
+	var mu sync.Mutex // schützt result und stdin
+	result := &authCookie{}
+
+	line := func(source, line string) {
+		if source == LogSourceStdout {
+			mu.Lock()
+			key, ok := result.set(line)
+			mu.Unlock()
+			if ok {
+				if key == "COOKIE" {
+					line = "COOKIE=<verborgen>"
+				}
+				s.Output(source, line)
+				return
+			}
+		}
+		s.Output(source, line)
+		handleOutputLine(s, proto, certs, line, nil, failed)
+	}
+
+	respond := func(source, text string) {
+		s.Output(source, strings.TrimSpace(text))
+		answer, skip, err := responder.answer(text)
+		switch {
+		case err != nil:
+			s.Logf(LogError, "Prompt %q: %v", strings.TrimSpace(text), err)
+			s.SetState(StateFailed, err.Error())
+			notify(failed, err.Error())
+		case !skip:
+			if msg := promptProgress[parsePrompt(text).kind]; msg != "" {
+				s.Progress(msg)
+			}
+			mu.Lock()
+			fmt.Fprintf(stdin, "%s\n", answer)
+			mu.Unlock()
+		}
+	}
+
+	exited := watchOutput(cmd, stdout, stderr, "openconnect --authenticate", line, respond)
+
+	// bei SSO kommen Cookie und Abbruch über die Oberfläche (nil sonst)
+	var ssoCookie <-chan string
+	var ssoCancel <-chan struct{}
+	if sso != nil {
+		ssoCookie, ssoCancel = sso.cookie, sso.cancel
+	}
+
+	select {
+	case cookie := <-ssoCookie:
+		s.Logf(LogInfo, "SSO cookie submitted via web interface")
+		cmd.Process.Kill()
+		<-exited
+		return &authCookie{cookie: cookie, host: profile.VPNServer, fingerprint: profile.ServerCert}, nil
+
+	case <-ssoCancel:
+		s.Logf(LogInfo, "SSO login cancelled")
+		cmd.Process.Kill()
+		exit := <-exited
+		exit.Reason = "Anmeldung abgebrochen"
+		return nil, &exit
+
+	case errMsg := <-failed:
+		s.Logf(LogError, "VPN authentication failed: %s", errMsg)
+		s.Fail(errMsg)
+		cmd.Process.Kill()
+		exit := <-exited
+		exit.Reason = "Anmeldung fehlgeschlagen: " + errMsg
+		return nil, &exit
+
+	case exit := <-exited:
+		mu.Lock()
+		defer mu.Unlock()
+		if exit.Code == 0 && result.cookie != "" && result.target() != "" {
+			s.Logf(LogInfo, "Authentication successful, host %s", result.host)
+			return result, nil
+		}
+		switch {
+		case s.vm.stopRequested():
+			exit.Reason = "Anmeldung abgebrochen"
+		case exit.Code == 0:
+			exit.Reason = "Anmeldung ohne Cookie beendet"
+		default:
+			exit.Reason = "Anmeldung fehlgeschlagen (" + exit.Reason + ")"
+		}
+		return nil, &exit
+
+	case <-time.After(timeout):
+		s.Logf(LogError, "Authentication timeout - stopping process")
+		cmd.Process.Kill()
+		exit := <-exited
+		exit.Reason = "Zeitüberschreitung bei der Anmeldung (" + exit.Reason + ")"
+		return nil, &exit
+	}
+}
+
+func (b *openconnectBackend) setAuthCmd(cmd *exec.Cmd) {
+	b.authMu.Lock()
+	defer b.authMu.Unlock()
+	b.authCmd = cmd
+}
+
+// cancelAuth beendet eine laufende Anmeldung (Trennen). Bei SSO bricht
+// zusätzlich cancelSSOLocked das Warten auf das Cookie ab.
+func (b *openconnectBackend) cancelAuth() {
+	b.authMu.Lock()
+	defer b.authMu.Unlock()
+	if b.authCmd != nil {
+		b.authCmd.Process.Kill()
+	}
+}
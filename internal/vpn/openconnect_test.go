@@ -0,0 +1,85 @@
+package vpn
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"testing"
+	"vpn-web/internal/models"
+)
+
+func TestTunnelPatternSkipsAuthentication(t *testing.T) {
+	pattern := regexp.MustCompile(tunnelPattern)
+	tests := []struct {
+		cmdline string
+		want    bool
+	}{
+		{"/usr/local/bin/openconnect --cookie-on-stdin --protocol=gp --pid-file=/tmp/openconnect.pid -s /tmp/vpnc vpn.example.com", true},
+		{"sudo -S -p Password: -- /usr/local/bin/openconnect --cookie-on-stdin --protocol=anyconnect vpn.example.com", true},
+		{"/usr/local/bin/openconnect --authenticate --protocol=anyconnect --user=jdoe vpn.example.com", false},
+		{"/usr/local/bin/openconnect --authenticate --external-browser=/usr/local/bin/vpn-web vpn.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := pattern.MatchString(tt.cmdline); got != tt.want {
+			t.Errorf("%q: match = %v, want %v", tt.cmdline, got, tt.want)
+		}
+	}
+}
+
+// fakePgrep gibt die PIDs aus $FAKE_PIDS aus, wie pgrep mit Exit-Code 1,
+// wenn es keine gibt.
+const fakePgrep = `#!/bin/sh
+[ -n "$FAKE_PIDS" ] || exit 1
+for pid in $FAKE_PIDS; do echo "$pid"; done
+`
+
+func TestStatusIgnoresAuthProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake pgrep benötigt /bin/sh")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pgrep"), []byte(fakePgrep), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep nicht gefunden")
+	}
+	t.Setenv("PATH", dir)
+
+	auth := exec.Command(sleep, "30")
+	if err := auth.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		auth.Process.Kill()
+		auth.Wait()
+	}()
+
+	b := &openconnectBackend{pidFile: filepath.Join(dir, "openconnect.pid")}
+	b.setAuthCmd(auth)
+	authPID := strconv.Itoa(auth.Process.Pid)
+
+	t.Setenv("FAKE_PIDS", authPID)
+	if b.Status(models.Profile{}) {
+		t.Error("Anmeldung als laufender Tunnel erkannt")
+	}
+	t.Setenv("FAKE_PIDS", authPID+" 4242")
+	if !b.Status(models.Profile{}) {
+		t.Error("Tunnel in der Prozessliste nicht erkannt")
+	}
+
+	t.Setenv("FAKE_PIDS", "")
+	tunnel := exec.Command(sleep, "30")
+	b.setTunnelCmd(tunnel)
+	if !b.Status(models.Profile{}) {
+		t.Error("selbst gestarteter Tunnel nicht erkannt")
+	}
+	b.clearTunnelCmd(tunnel)
+	if b.Status(models.Profile{}) {
+		t.Error("beendeter Tunnel weiterhin erkannt")
+	}
+}
@@ -0,0 +1,429 @@
+package vpn
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"vpn-web/internal/models"
+)
+
+// openvpnBackend startet OpenVPN per sudo und steuert es über das
+// Management-Interface (Unix-Socket): Zugangsdaten werden dort auf Anfrage
+// übergeben statt auf die Platte geschrieben, Zustände kommen als >STATE.
+type openvpnBackend struct {
+	runDir string // Laufzeitverzeichnisse je Verbindung (0700)
+
+	// Programmpfad; leer = an den üblichen Orten suchen
+	openvpnPath string
+
+	mu     sync.Mutex
+	active *openvpnProcess // laufender Prozess, nil = keiner
+}
+
+// openvpnProcess ist ein laufender, von vpn-web gestarteter OpenVPN-Prozess.
+type openvpnProcess struct {
+	profileID string
+	cmd       *exec.Cmd
+	mgmt      *managementConn // nil bis zur Verbindung mit dem Socket
+	pid       string          // PID von openvpn (nicht von sudo)
+	done      chan struct{}   // geschlossen, sobald der Prozess beendet ist
+}
+
+func newOpenVPNBackend(runDir string) *openvpnBackend {
+	return &openvpnBackend{runDir: runDir}
+}
+
+func (b *openvpnBackend) Name() string {
+	return BackendOpenVPN
+}
+
+func (b *openvpnBackend) Validate(s *Session) error {
+	parsed, err := parseOpenVPNConfig(s.Profile.OpenVPNConfig)
+	if err != nil {
+		return err
+	}
+
+	if parsed.needAuth {
+		if s.Profile.Username == "" {
+			return errors.New("Benutzername erforderlich")
+		}
+		password, err := s.Secret(secretVPN)
+		if err != nil {
+			return err
+		}
+		if password == "" {
+			return errors.New("VPN-Passwort nicht im Passwort-Speicher gefunden. Bitte in den Einstellungen speichern.")
+		}
+	}
+
+	if _, err := s.Secret(secretCert); err != nil {
+		return err
+	}
+	if _, err := s.Secret(secretOpenVPN); err != nil {
+		return err
+	}
+
+	if b.findOpenVPNPath() == "" {
+		return errors.New("openvpn nicht gefunden")
+	}
+	return nil
+}
+
+// Connect startet OpenVPN angehalten (--management-hold), verbindet sich
+// mit dem Management-Socket und überwacht den Prozess bis zu seinem Ende.
+func (b *openvpnBackend) Connect(s *Session) *ExitInfo {
+	notStarted := func(reason string) *ExitInfo {
+		return &ExitInfo{Code: -1, Reason: "openvpn nicht gestartet: " + reason, Time: time.Now()}
+	}
+
+	current, err := user.Current()
+	if err != nil {
+		return notStarted(err.Error())
+	}
+
+	if err := os.MkdirAll(b.runDir, 0700); err != nil {
+		return notStarted(err.Error())
+	}
+	dir, err := os.MkdirTemp(b.runDir, "run-")
+	if err != nil {
+		return notStarted(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// Konfiguration samt privaten Blöcken nur so lange auf der Platte, bis
+	// OpenVPN sie gelesen hat (siehe >HOLD)
+	configPath := filepath.Join(dir, "client.ovpn")
+	config := s.Profile.OpenVPNConfig
+	if encoded, _ := s.Secret(secretOpenVPN); encoded != "" {
+		blocks, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return notStarted("Schlüssel im Passwort-Speicher beschädigt")
+		}
+		config += string(blocks)
+	}
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		return notStarted(err.Error())
+	}
+
+	socketPath := filepath.Join(dir, "management.sock")
+	args := []string{
+		b.findOpenVPNPath(),
+		"--config", configPath,
+		"--management", socketPath, "unix",
+		"--management-client-user", current.Username,
+		"--management-query-passwords",
+		"--management-hold",
+		"--auth-retry", "none",
+		"--auth-nocache",
+	}
+
+	cmd := exec.Command("sudo", sudoArgs(s.SudoPassword, args...)...)
+	if s.SudoPassword != "" {
+		cmd.Stdin = strings.NewReader(s.SudoPassword + "\n")
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return notStarted(fmt.Sprintf("Stdout pipe error: %v", err))
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return notStarted(fmt.Sprintf("Stderr pipe error: %v", err))
+	}
+
+	if err := cmd.Start(); err != nil {
+		return notStarted(fmt.Sprintf("Start error: %v", err))
+	}
+
+	proc := &openvpnProcess{profileID: s.Profile.ID, cmd: cmd, done: make(chan struct{})}
+	b.mu.Lock()
+	b.active = proc
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.active = nil
+		b.mu.Unlock()
+	}()
+
+	s.Logf(LogInfo, "OpenVPN process started with PID: %d", cmd.Process.Pid)
+	s.Progress("openvpn gestartet...")
+
+	connected := make(chan bool, 1)
+	failed := make(chan string, 1)
+
+	var wg sync.WaitGroup
+	watch := func(r io.Reader, source string) {
+		defer wg.Done()
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			s.Output(source, line)
+			if isSudoAuthFailure(line) {
+				msg := "Sudo-Authentifizierung fehlgeschlagen: " + line
+				s.SetState(StateFailed, msg)
+				notify(failed, msg)
+			}
+		}
+	}
+	wg.Add(2)
+	go watch(stdout, LogSourceStdout)
+	go watch(stderr, LogSourceStderr)
+
+	exited := make(chan ExitInfo, 1)
+	go func() {
+		wg.Wait()
+		exit := reap(cmd, "openvpn")
+		close(proc.done)
+		exited <- exit
+	}()
+
+	go b.manage(s, proc, socketPath, configPath, connected, failed)
+
+	select {
+	case <-connected:
+		s.Logf(LogInfo, "VPN successfully connected - supervising process")
+		s.Connected()
+
+	case errMsg := <-failed:
+		s.Logf(LogError, "VPN connection failed: %s", errMsg)
+		s.Fail(errMsg)
+		b.stop(s, proc)
+
+	case exit := <-exited:
+		return &exit
+
+	case <-time.After(60 * time.Second):
+		s.Logf(LogError, "Connection timeout - stopping process")
+		b.stop(s, proc)
+		exit := <-exited
+		exit.Reason = "Zeitüberschreitung beim Verbinden (" + exit.Reason + ")"
+		return &exit
+	}
+
+	exit := <-exited
+	return &exit
+}
+
+// manage verbindet sich mit dem Management-Socket und beantwortet dessen
+// Meldungen, bis OpenVPN den Socket schließt.
+func (b *openvpnBackend) manage(s *Session, proc *openvpnProcess, socketPath, configPath string, connected chan<- bool, failed chan<- string) {
+	deadline := time.Now().Add(15 * time.Second)
+	conn, err := net.Dial("unix", socketPath)
+	for err != nil {
+		if time.Now().After(deadline) {
+			notify(failed, "Management-Interface nicht erreichbar: "+err.Error())
+			return
+		}
+		select {
+		case <-proc.done:
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+		conn, err = net.Dial("unix", socketPath)
+	}
+	defer conn.Close()
+
+	mgmt := &managementConn{conn: conn}
+	b.mu.Lock()
+	proc.mgmt = mgmt
+	b.mu.Unlock()
+
+	mgmt.send("state on")
+	mgmt.send("pid")
+
+	password, _ := s.Secret(secretVPN)
+	certPassword, _ := s.Secret(secretCert)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		switch {
+		case strings.HasPrefix(line, "SUCCESS: pid="):
+			b.mu.Lock()
+			proc.pid = strings.TrimPrefix(line, "SUCCESS: pid=")
+			b.mu.Unlock()
+
+		case strings.HasPrefix(line, ">HOLD:"):
+			// OpenVPN hat die Konfiguration gelesen
+			os.Remove(configPath)
+			mgmt.send("hold release")
+
+		case strings.HasPrefix(line, ">PASSWORD:Verification Failed"):
+			msg := "Anmeldung fehlgeschlagen (" + strings.TrimPrefix(line, ">PASSWORD:") + ")"
+			s.SetState(StateFailed, msg)
+			notify(failed, msg)
+
+		case strings.HasPrefix(line, ">PASSWORD:Need 'Auth'"):
+			s.Progress("Sende Zugangsdaten...")
+			mgmt.send("username \"Auth\" " + quoteManagement(s.Profile.Username))
+			mgmt.send("password \"Auth\" " + quoteManagement(password))
+
+		case strings.HasPrefix(line, ">PASSWORD:Need 'Private Key'"):
+			if certPassword == "" {
+				msg := "Passwort für den privaten Schlüssel fehlt"
+				s.SetState(StateFailed, msg)
+				notify(failed, msg)
+				continue
+			}
+			s.Progress("Sende Zertifikat-Passwort...")
+			mgmt.send("password \"Private Key\" " + quoteManagement(certPassword))
+
+		case strings.HasPrefix(line, ">FATAL:"):
+			msg := strings.TrimPrefix(line, ">FATAL:")
+			s.SetState(StateFailed, msg)
+			notify(failed, msg)
+
+		case strings.HasPrefix(line, ">STATE:"):
+			b.handleState(s, line, connected)
+
+		case strings.HasPrefix(line, "ERROR:"):
+			s.Logf(LogWarn, "OpenVPN management: %s", line)
+		}
+	}
+}
+
+// handleState wertet eine >STATE-Meldung aus, z.B.
+// ">STATE:1700000000,CONNECTED,SUCCESS,10.8.0.2,203.0.113.1,1194,,".
+func (b *openvpnBackend) handleState(s *Session, line string, connected chan<- bool) {
+	fields := strings.Split(strings.TrimPrefix(line, ">STATE:"), ",")
+	if len(fields) < 2 {
+		return
+	}
+	name := fields[1]
+	detail := ""
+	if len(fields) > 2 {
+		detail = fields[2]
+	}
+
+	next, ok := stateFromOpenVPN(s.State(), name)
+	switch {
+	case !ok:
+		s.Progress("OpenVPN: " + strings.ToLower(name))
+	case next == StateConnected:
+		if detail == "ERROR" {
+			s.Logf(LogWarn, "OpenVPN connected with errors")
+		}
+		if s.SetState(StateConnected, "") {
+			notify(connected, true)
+		}
+	default:
+		s.SetState(next, "")
+	}
+}
+
+// stateFromOpenVPN bildet einen OpenVPN-Zustand auf den Manager ab.
+// ok ist false, wenn der Zustand nur als Fortschritt gemeldet wird.
+func stateFromOpenVPN(current State, name string) (next State, ok bool) {
+	switch name {
+	case "CONNECTED":
+		return StateConnected, true
+	case "RECONNECTING":
+		if current == StateConnected {
+			return StateReconnecting, true
+		}
+	case "WAIT", "AUTH", "AUTH_PENDING", "GET_CONFIG":
+		if current == StateStarting || current == StateReconnecting {
+			return StateAuthenticating, true
+		}
+	}
+	return current, false
+}
+
+// stop beendet den Prozess über das Management-Interface, notfalls per sudo.
+func (b *openvpnBackend) stop(s *Session, proc *openvpnProcess) {
+	b.mu.Lock()
+	mgmt, pid := proc.mgmt, proc.pid
+	b.mu.Unlock()
+
+	if mgmt != nil && mgmt.send("signal SIGTERM") == nil {
+		return
+	}
+	if err := proc.cmd.Process.Signal(syscall.SIGTERM); err == nil {
+		return
+	}
+	if pid != "" {
+		if err := runSudo(s.SudoPassword, "kill", "-TERM", pid); err != nil {
+			s.Logf(LogWarn, "Stopping openvpn failed: %v", err)
+		}
+	}
+}
+
+// Status meldet, ob der von vpn-web gestartete Prozess für das Profil läuft.
+func (b *openvpnBackend) Status(profile models.Profile) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.active != nil && b.active.profileID == profile.ID
+}
+
+func (b *openvpnBackend) Disconnect(s *Session) error {
+	b.mu.Lock()
+	proc := b.active
+	b.mu.Unlock()
+	if proc == nil {
+		return nil
+	}
+
+	b.stop(s, proc)
+	if !waitDisconnected(b, s.Profile, 10*time.Second) {
+		b.mu.Lock()
+		pid := proc.pid
+		b.mu.Unlock()
+		if pid != "" {
+			s.Logf(LogWarn, "openvpn did not exit after SIGTERM, sending SIGKILL")
+			if err := runSudo(s.SudoPassword, "kill", "-KILL", pid); err != nil {
+				return err
+			}
+		}
+		if !waitDisconnected(b, s.Profile, 3*time.Second) {
+			return errors.New("Disconnect unsicher: openvpn läuft weiterhin")
+		}
+	}
+	return nil
+}
+
+func (b *openvpnBackend) findOpenVPNPath() string {
+	if b.openvpnPath != "" {
+		return b.openvpnPath
+	}
+	paths := []string{"/usr/local/sbin/openvpn", "/opt/homebrew/sbin/openvpn", "/usr/sbin/openvpn"}
+	for _, path := range paths {
+		if fileExists(path) {
+			return path
+		}
+	}
+	if path, err := exec.LookPath("openvpn"); err == nil {
+		return path
+	}
+	return ""
+}
+
+// managementConn schreibt Kommandos an das Management-Interface.
+type managementConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (m *managementConn) send(command string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := io.WriteString(m.conn, command+"\n")
+	return err
+}
+
+// quoteManagement setzt einen Wert für das Management-Protokoll in
+// Anführungszeichen (Backslash und Anführungszeichen werden maskiert).
+func quoteManagement(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
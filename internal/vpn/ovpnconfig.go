@@ -0,0 +1,241 @@
+package vpn
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Inline-Blöcke mit privatem Schlüsselmaterial. Sie werden beim Import aus
+// der .ovpn-Datei entfernt und im Passwort-Speicher abgelegt.
+var openvpnSecretBlocks = map[string]bool{
+	"key":                  true,
+	"pkcs12":               true,
+	"secret":               true,
+	"tls-auth":             true,
+	"tls-crypt":            true,
+	"tls-crypt-v2":         true,
+	"http-proxy-user-pass": true,
+}
+
+// openvpnInlineBlocks sind die erlaubten Inline-Blöcke; <connection> enthält
+// Optionen und wird wie der Rest der Datei geprüft.
+var openvpnInlineBlocks = map[string]bool{
+	"ca": true, "cert": true, "key": true, "pkcs12": true, "secret": true,
+	"tls-auth": true, "tls-crypt": true, "tls-crypt-v2": true, "dh": true,
+	"extra-certs": true, "crl-verify": true, "http-proxy-user-pass": true,
+	"peer-fingerprint": true, "connection": true,
+}
+
+// openvpnFileOptions verweisen auf Dateien; erlaubt sind sie nur inline.
+var openvpnFileOptions = map[string]bool{
+	"ca": true, "cert": true, "key": true, "pkcs12": true, "secret": true,
+	"tls-auth": true, "tls-crypt": true, "tls-crypt-v2": true, "dh": true,
+	"extra-certs": true, "crl-verify": true, "http-proxy-user-pass": true,
+}
+
+// openvpnClientOptions sind die erlaubten Optionen. OpenVPN läuft als root;
+// alles andere könnte Skripte, Plugins oder Bibliotheken laden (up, plugin,
+// iproute, engine, providers ...), Dateien schreiben (log, tmp-dir,
+// replay-persist ...) oder die Steuerung durch vpn-web stören (management*)
+// und wird abgelehnt.
+var openvpnClientOptions = map[string]bool{
+	// Verbindung
+	"client": true, "pull": true, "tls-client": true, "dev": true,
+	"dev-type": true, "proto": true, "remote": true, "port": true,
+	"rport": true, "lport": true, "bind": true, "nobind": true, "float": true,
+	"remote-random": true, "remote-random-hostname": true,
+	"resolv-retry": true, "connect-retry": true, "connect-retry-max": true,
+	"connect-timeout": true, "server-poll-timeout": true,
+	"http-proxy": true, "http-proxy-option": true, "socks-proxy": true,
+	"persist-key": true, "persist-tun": true, "persist-remote-ip": true,
+	"persist-local-ip": true, "explicit-exit-notify": true,
+	"user": true, "group": true, "mark": true,
+	// Zertifikate und Schlüssel (nur inline, siehe openvpnFileOptions)
+	"ca": true, "cert": true, "key": true, "pkcs12": true, "secret": true,
+	"tls-auth": true, "tls-crypt": true, "tls-crypt-v2": true, "dh": true,
+	"extra-certs": true, "crl-verify": true, "key-direction": true,
+	"peer-fingerprint": true, "remote-cert-tls": true, "remote-cert-ku": true,
+	"remote-cert-eku": true, "ns-cert-type": true, "verify-x509-name": true,
+	"verify-hash": true,
+	// Verschlüsselung
+	"cipher": true, "data-ciphers": true, "data-ciphers-fallback": true,
+	"ncp-ciphers": true, "ncp-disable": true, "auth": true,
+	"tls-version-min": true, "tls-version-max": true, "tls-cipher": true,
+	"tls-ciphersuites": true, "tls-groups": true, "tls-cert-profile": true,
+	"ecdh-curve": true, "tls-timeout": true, "hand-window": true,
+	"tran-window": true, "reneg-sec": true, "reneg-bytes": true,
+	"reneg-pkts": true, "replay-window": true, "mute-replay-warnings": true,
+	// Anmeldung
+	"auth-user-pass": true, "auth-nocache": true, "auth-retry": true,
+	"auth-token-user": true, "static-challenge": true, "push-peer-info": true,
+	// Routen und DNS
+	"route": true, "route-ipv6": true, "route-nopull": true,
+	"route-delay": true, "route-metric": true, "route-gateway": true,
+	"redirect-gateway": true, "redirect-private": true,
+	"allow-recursive-routing": true, "pull-filter": true,
+	"dhcp-option": true, "dns": true, "block-outside-dns": true,
+	"topology": true, "tun-ipv6": true,
+	// Transport
+	"tun-mtu": true, "tun-mtu-extra": true, "link-mtu": true,
+	"fragment": true, "mssfix": true, "mtu-disc": true, "sndbuf": true,
+	"rcvbuf": true, "txqueuelen": true, "fast-io": true,
+	"comp-lzo": true, "compress": true, "allow-compression": true,
+	"keepalive": true, "ping": true, "ping-restart": true, "ping-exit": true,
+	"ping-timer-rem": true, "inactive": true,
+	// Ausgabe und Sonstiges
+	"verb": true, "mute": true, "machine-readable-output": true,
+	"setenv": true, "setenv-safe": true, "ignore-unknown-option": true,
+	"disable-occ": true, "single-session": true, "tls-exit": true,
+}
+
+// openvpnConfig ist eine zerlegte .ovpn-Datei.
+type openvpnConfig struct {
+	config   string // ohne private Blöcke
+	secrets  string // private Blöcke im Originalformat
+	needAuth bool   // auth-user-pass: Benutzername/Passwort erforderlich
+}
+
+// parseOpenVPNConfig prüft eine .ovpn-Datei und trennt private Schlüssel ab.
+// Erlaubt sind nur bekannte Client-Optionen ohne Verweise auf externe
+// Dateien, da OpenVPN als root läuft.
+func parseOpenVPNConfig(text string) (openvpnConfig, error) {
+	var result openvpnConfig
+	if strings.TrimSpace(text) == "" {
+		return result, fmt.Errorf("Keine OpenVPN-Konfiguration hinterlegt")
+	}
+
+	var config, secrets strings.Builder
+	block := "" // Name des offenen Inline-Blocks
+	secretBlock := false
+	hasRemote := false
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		if block != "" && block != "connection" {
+			if strings.EqualFold(line, "</"+block+">") {
+				block = ""
+			}
+			if secretBlock {
+				secrets.WriteString(line + "\n")
+			} else {
+				config.WriteString(line + "\n")
+			}
+			continue
+		}
+
+		if block == "connection" && strings.EqualFold(line, "</connection>") {
+			block = ""
+			config.WriteString(line + "\n")
+			continue
+		}
+
+		if strings.HasPrefix(line, "<") && strings.HasSuffix(line, ">") && !strings.HasPrefix(line, "</") {
+			name := strings.ToLower(strings.Trim(line, "<>"))
+			switch {
+			case name == "auth-user-pass":
+				return result, fmt.Errorf("Block <auth-user-pass> ist nicht erlaubt, Benutzername und Passwort bitte im Profil hinterlegen")
+			case !openvpnInlineBlocks[name]:
+				return result, fmt.Errorf("Block <%s> ist nicht erlaubt", name)
+			case block == "connection":
+				return result, fmt.Errorf("Block <%s> in <connection> ist nicht erlaubt", name)
+			}
+			block = name
+			secretBlock = openvpnSecretBlocks[block]
+			if secretBlock {
+				secrets.WriteString(line + "\n")
+			} else {
+				config.WriteString(line + "\n")
+			}
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			config.WriteString(line + "\n")
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if err := checkOpenVPNOption(fields); err != nil {
+			return result, err
+		}
+		switch strings.ToLower(strings.TrimPrefix(fields[0], "--")) {
+		case "auth-user-pass":
+			result.needAuth = true
+		case "remote":
+			hasRemote = true
+		}
+		config.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	if block != "" {
+		return result, fmt.Errorf("Block <%s> nicht geschlossen", block)
+	}
+	if !hasRemote {
+		return result, fmt.Errorf("OpenVPN-Konfiguration enthält keine remote-Zeile")
+	}
+
+	result.config = config.String()
+	result.secrets = secrets.String()
+	return result, nil
+}
+
+// checkOpenVPNOption prüft eine Optionszeile gegen openvpnClientOptions.
+// Argumente, die auf Dateien verweisen, sind nicht erlaubt.
+func checkOpenVPNOption(fields []string) error {
+	option := strings.ToLower(strings.TrimPrefix(fields[0], "--"))
+	args := fields[1:]
+	switch {
+	case !openvpnClientOptions[option]:
+		return fmt.Errorf("Option \"%s\" ist nicht erlaubt", option)
+	case openvpnFileOptions[option] && len(args) > 0 && args[0] != "[inline]":
+		return fmt.Errorf("Option \"%s\" verweist auf eine Datei, bitte inline einbetten (<%s>...</%s>)", option, option, option)
+	case option == "auth-user-pass" && len(args) > 0:
+		return fmt.Errorf("auth-user-pass mit Datei ist nicht erlaubt, Zugangsdaten kommen aus dem Profil")
+	case option == "http-proxy" && len(args) > 2 && args[2] != "auto" && args[2] != "auto-nct" && args[2] != "[inline]":
+		return fmt.Errorf("http-proxy mit Datei ist nicht erlaubt, Zugangsdaten bitte inline einbetten (<http-proxy-user-pass>...</http-proxy-user-pass>)")
+	case option == "socks-proxy" && len(args) > 2:
+		return fmt.Errorf("socks-proxy mit Datei ist nicht erlaubt")
+	case option == "setenv" && len(args) > 1 && strings.EqualFold(args[0], "opt"):
+		// "setenv opt <Option>" führt bekannte Optionen trotzdem aus
+		return checkOpenVPNOption(args[1:])
+	}
+	return nil
+}
+
+// ImportOpenVPNConfig übernimmt eine .ovpn-Datei in das Profil. Private
+// Schlüssel (<key>, <pkcs12>, <tls-crypt> ...) werden in den
+// Passwort-Speicher verschoben.
+func (vm *Manager) ImportOpenVPNConfig(profileID, text string) error {
+	parsed, err := parseOpenVPNConfig(text)
+	if err != nil {
+		return err
+	}
+
+	if parsed.secrets != "" {
+		// base64, da nicht jeder Speicher mehrzeilige Werte verträgt
+		encoded := base64.StdEncoding.EncodeToString([]byte(parsed.secrets))
+		if err := vm.secrets.Store(profileAccount(profileID, secretOpenVPN), encoded); err != nil {
+			return err
+		}
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	profile := vm.settings.Profile(profileID)
+	if profile == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	profile.OpenVPNConfig = parsed.config
+	profile.LastModified = time.Now().Format(time.RFC3339)
+	return vm.saveSettingsLocked()
+}
@@ -0,0 +1,122 @@
+package vpn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var errKeyPassword = errors.New("Privater Schlüssel lässt sich mit dem Zertifikat-Passwort nicht entschlüsseln")
+
+// isPEM erkennt PEM-Dateien am Inhalt, nicht an der Endung.
+func isPEM(data []byte) bool {
+	return bytes.Contains(data, []byte("-----BEGIN "))
+}
+
+// pemCertificates liest alle Zertifikate einer PEM-Datei.
+func pemCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Ungültiges Zertifikat in PEM-Datei: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("PEM-Datei enthält kein Zertifikat")
+	}
+	return certs, nil
+}
+
+// pemPublicKey liest den privaten Schlüssel einer PEM-Datei und liefert den
+// zugehörigen öffentlichen Schlüssel. Verschlüsselte Schlüssel entschlüsselt
+// openssl; das Passwort geht per Umgebung.
+func pemPublicKey(data []byte, password string) (crypto.PublicKey, error) {
+	var block *pem.Block
+	for rest := data; ; {
+		if block, rest = pem.Decode(rest); block == nil {
+			return nil, fmt.Errorf("PEM-Datei enthält keinen privaten Schlüssel")
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			break
+		}
+	}
+
+	if block.Type == "ENCRYPTED PRIVATE KEY" || block.Headers["Proc-Type"] == "4,ENCRYPTED" {
+		return encryptedPEMPublicKey(data, password)
+	}
+
+	var key crypto.PrivateKey
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Ungültiger privater Schlüssel: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Nicht unterstützter Schlüsseltyp")
+	}
+	return signer.Public(), nil
+}
+
+func encryptedPEMPublicKey(data []byte, password string) (crypto.PublicKey, error) {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		return nil, fmt.Errorf("Verschlüsselte Schlüssel werden nur mit openssl unterstützt")
+	}
+
+	cmd := exec.Command("openssl", "pkey", "-pubout", "-passin", "env:VPN_WEB_KEY_PASSWORD")
+	cmd.Env = append(os.Environ(), "VPN_WEB_KEY_PASSWORD="+password)
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errKeyPassword
+	}
+	block, _ := pem.Decode(out)
+	if block == nil {
+		return nil, errKeyPassword
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Ungültiger privater Schlüssel: %v", err)
+	}
+	return pub, nil
+}
+
+// checkKeyPair prüft, ob der private Schlüssel zum (ersten) Zertifikat der
+// PEM-Datei gehört.
+func checkKeyPair(certData, keyData []byte, password string) error {
+	certs, err := pemCertificates(certData)
+	if err != nil {
+		return err
+	}
+	pub, err := pemPublicKey(keyData, password)
+	if err != nil {
+		return err
+	}
+	certKey, ok := certs[0].PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !certKey.Equal(pub) {
+		return fmt.Errorf("Privater Schlüssel passt nicht zum Zertifikat")
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package vpn
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"vpn-web/internal/keychain"
+	"vpn-web/internal/models"
+)
+
+func newProfileID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newDefaultProfile(name string) models.Profile {
+	now := time.Now().Format(time.RFC3339)
+	return models.Profile{
+		ID:           newProfileID(),
+		Name:         name,
+		VPNServer:    defaultServer,
+		Routes:       models.Routes{Include: append([]string(nil), defaultRoutes...)},
+		UseKeychain:  true, // Standard: Keychain verwenden
+		CreatedAt:    now,
+		LastModified: now,
+	}
+}
+
+// copyProfile liefert eine tiefe Kopie (inkl. Maps) eines Profils.
+func copyProfile(p *models.Profile) models.Profile {
+	c := *p
+	c.Routes.Include = append([]string(nil), p.Routes.Include...)
+	c.Routes.Exclude = append([]string(nil), p.Routes.Exclude...)
+	c.DNSDomains = append([]string(nil), p.DNSDomains...)
+	if p.CertInfo != nil {
+		info := *p.CertInfo
+		info.SANs = append([]string(nil), p.CertInfo.SANs...)
+		c.CertInfo = &info
+	}
+	if p.CSR != nil {
+		csr := *p.CSR
+		c.CSR = &csr
+	}
+	if p.SecretCommands != nil {
+		c.SecretCommands = make(map[string]models.SecretCommand, len(p.SecretCommands))
+		for k, v := range p.SecretCommands {
+			c.SecretCommands[k] = v
+		}
+	}
+	return c
+}
+
+// Profiles liefert eine Kopie aller Profile.
+func (vm *Manager) Profiles() []models.Profile {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	profiles := make([]models.Profile, len(vm.settings.Profiles))
+	for i := range vm.settings.Profiles {
+		profiles[i] = copyProfile(&vm.settings.Profiles[i])
+	}
+	return profiles
+}
+
+// Profile liefert eine Kopie des Profils mit der angegebenen ID.
+func (vm *Manager) Profile(id string) (models.Profile, bool) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if p := vm.settings.Profile(id); p != nil {
+		return copyProfile(p), true
+	}
+	return models.Profile{}, false
+}
+
+// DefaultProfile liefert eine Kopie des Standardprofils.
+func (vm *Manager) DefaultProfile() (models.Profile, bool) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if p := vm.settings.Default(); p != nil {
+		return copyProfile(p), true
+	}
+	return models.Profile{}, false
+}
+
+// UpdateProfile übernimmt die im Einstellungsformular änderbaren Felder
+// (Typ, Protokoll, Server, Gruppe, Benutzer, SSO, Festlegen des
+// Server-Zertifikats, Routen, DNS-Domains) in das
+// gespeicherte Profil. Alles andere – Name, Kommandos, Zertifikate,
+// Fingerprint, Konfigurationen – ändern eigene Methoden; es bleibt so, wie
+// es gespeichert ist, auch wenn profile ein älterer Stand ist.
+func (vm *Manager) UpdateProfile(profile models.Profile) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	existing := vm.settings.Profile(profile.ID)
+	if existing == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	routes, dnsDomains, err := vm.checkProfileFields(profile)
+	if err != nil {
+		return err
+	}
+
+	updated := copyProfile(existing)
+	updated.Type = profile.Type
+	updated.Protocol = profile.Protocol
+	updated.VPNServer = profile.VPNServer
+	updated.AuthGroup = profile.AuthGroup
+	updated.Username = profile.Username
+	updated.SSO = profile.SSO
+	updated.PinServerCert = profile.PinServerCert
+	updated.Routes = routes
+	updated.DNSDomains = dnsDomains
+	updated.Networks = ""
+
+	// der Fingerprint gilt nur für das bisherige Gateway
+	if updated.VPNServer != existing.VPNServer && updated.ServerCert != "" {
+		vm.logf(LogInfo, "VPN server of profile %s changed, dropping pinned server certificate", existing.Name)
+		updated.ServerCert = ""
+		if vm.certPrompt != nil && vm.certPrompt.Profile == profile.ID {
+			vm.certPrompt = nil
+		}
+	}
+
+	updated.UseKeychain = true
+	updated.LastModified = time.Now().Format(time.RFC3339)
+	*existing = updated
+
+	return vm.saveSettingsLocked()
+}
+
+// ValidateProfile prüft die Formularfelder eines Profils wie UpdateProfile,
+// ohne etwas zu speichern. Der Handler ruft es vor allen anderen
+// Änderungen auf, damit ein abgelehntes Profil nichts halb gespeichert
+// zurücklässt.
+func (vm *Manager) ValidateProfile(profile models.Profile) error {
+	if _, ok := vm.Profile(profile.ID); !ok {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	_, _, err := vm.checkProfileFields(profile)
+	return err
+}
+
+// checkProfileFields prüft Typ, Protokoll, Routen und DNS-Domains und
+// liefert Routen und Domains in normalisierter Form.
+func (vm *Manager) checkProfileFields(profile models.Profile) (models.Routes, []string, error) {
+	if _, err := vm.backendFor(profile); err != nil {
+		return models.Routes{}, nil, err
+	}
+	if profile.Protocol != "" {
+		if _, err := openconnectProtocolFor(profile.Protocol); err != nil {
+			return models.Routes{}, nil, err
+		}
+	}
+	routes, err := normalizeRoutes(profile.Routes)
+	if err != nil {
+		return models.Routes{}, nil, err
+	}
+	dnsDomains, err := normalizeDNSDomains(profile.DNSDomains)
+	if err != nil {
+		return models.Routes{}, nil, err
+	}
+	return routes, dnsDomains, nil
+}
+
+// CreateProfile legt ein neues Profil mit Standardwerten an.
+func (vm *Manager) CreateProfile(name string) (models.Profile, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	name, err := vm.checkProfileNameLocked(name, "")
+	if err != nil {
+		return models.Profile{}, err
+	}
+
+	profile := newDefaultProfile(name)
+	vm.settings.Profiles = append(vm.settings.Profiles, profile)
+	return profile, vm.saveSettingsLocked()
+}
+
+// CloneProfile kopiert ein Profil samt gespeicherter Passwörter.
+func (vm *Manager) CloneProfile(id, name string) (models.Profile, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	source := vm.settings.Profile(id)
+	if source == nil {
+		return models.Profile{}, fmt.Errorf("Profil nicht gefunden")
+	}
+	if strings.TrimSpace(name) == "" {
+		name = source.Name + " (Kopie)"
+	}
+	name, err := vm.checkProfileNameLocked(name, "")
+	if err != nil {
+		return models.Profile{}, err
+	}
+
+	clone := copyProfile(source)
+	clone.ID = newProfileID()
+	clone.Name = name
+	clone.CreatedAt = time.Now().Format(time.RFC3339)
+	clone.LastModified = clone.CreatedAt
+
+	// ohne alle Passwörter keine Kopie, die nur vollständig aussieht
+	var copied []string
+	for _, kind := range profileSecretKinds {
+		password, err := vm.secrets.Get(profileAccount(source.ID, kind))
+		if err == nil && password != "" {
+			err = vm.secrets.Store(profileAccount(clone.ID, kind), password)
+		}
+		if err != nil && !errors.Is(err, keychain.ErrNotFound) {
+			for _, account := range copied {
+				vm.secrets.Delete(account)
+			}
+			label := secretLabels[kind]
+			if label == "" {
+				label = "Geheimnisses \"" + kind + "\""
+			}
+			return models.Profile{}, fmt.Errorf("Kopieren des %s fehlgeschlagen: %v", label, err)
+		}
+		if password != "" {
+			copied = append(copied, profileAccount(clone.ID, kind))
+		}
+	}
+
+	vm.settings.Profiles = append(vm.settings.Profiles, clone)
+	return clone, vm.saveSettingsLocked()
+}
+
+// RenameProfile ändert den Anzeigenamen eines Profils.
+func (vm *Manager) RenameProfile(id, name string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	profile := vm.settings.Profile(id)
+	if profile == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	name, err := vm.checkProfileNameLocked(name, id)
+	if err != nil {
+		return err
+	}
+
+	profile.Name = name
+	profile.LastModified = time.Now().Format(time.RFC3339)
+	return vm.saveSettingsLocked()
+}
+
+// DeleteProfile entfernt ein Profil und dessen gespeicherte Passwörter.
+// Das letzte Profil und das aktuell verbundene Profil bleiben erhalten.
+func (vm *Manager) DeleteProfile(id string) error {
+	connected := vm.IsConnected()
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if vm.settings.Profile(id) == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	if len(vm.settings.Profiles) == 1 {
+		return fmt.Errorf("Das letzte Profil kann nicht gelöscht werden")
+	}
+	if connected && vm.activeProfile == id {
+		return fmt.Errorf("Profil ist verbunden - bitte zuerst trennen")
+	}
+
+	profiles := vm.settings.Profiles[:0]
+	for _, p := range vm.settings.Profiles {
+		if p.ID != id {
+			profiles = append(profiles, p)
+		}
+	}
+	vm.settings.Profiles = profiles
+	if vm.settings.DefaultProfile == id {
+		vm.settings.DefaultProfile = profiles[0].ID
+	}
+
+	for _, kind := range profileSecretKinds {
+		vm.secrets.Delete(profileAccount(id, kind))
+	}
+
+	return vm.saveSettingsLocked()
+}
+
+// SetDefaultProfile markiert ein Profil als Standard.
+func (vm *Manager) SetDefaultProfile(id string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if vm.settings.Profile(id) == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	vm.settings.DefaultProfile = id
+	return vm.saveSettingsLocked()
+}
+
+// DefaultProfileID liefert die ID des Standardprofils.
+func (vm *Manager) DefaultProfileID() string {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if p := vm.settings.Default(); p != nil {
+		return p.ID
+	}
+	return ""
+}
+
+// checkProfileNameLocked prüft, dass der Name nicht leer und (außer für
+// das Profil exceptID selbst) eindeutig ist.
+func (vm *Manager) checkProfileNameLocked(name, exceptID string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("Profilname erforderlich")
+	}
+	for _, p := range vm.settings.Profiles {
+		if p.ID != exceptID && strings.EqualFold(p.Name, name) {
+			return "", fmt.Errorf("Profilname \"%s\" bereits vergeben", name)
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,140 @@
+package vpn
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vpn-web/internal/models"
+)
+
+// newTestManager liefert einen Manager mit eigenem Home-Verzeichnis und
+// entsperrtem Passwort-Speicher (Datei).
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	settings := []byte(`{"secret_store":"file"}`)
+	if err := os.WriteFile(filepath.Join(home, ".vpn_web_settings.json"), settings, 0600); err != nil {
+		t.Fatal(err)
+	}
+	vm := NewVPNManager()
+	if err := vm.CreateSecrets("test", "test"); err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+func TestUpdateProfileKeepsManagedFields(t *testing.T) {
+	vm := newTestManager(t)
+	snapshot, _ := vm.DefaultProfile()
+	snapshot.Type = BackendWireGuard
+
+	// ändert sich während der Anfrage, snapshot kennt es nicht
+	config := "[Interface]\nPrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\nAddress = 10.0.0.2/32\n\n[Peer]\nPublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\nEndpoint = vpn.example.com:51820\nAllowedIPs = 10.0.0.0/8\n"
+	if err := vm.ImportWireGuardConfig(snapshot.ID, config); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot.Username = "jdoe"
+	if err := vm.UpdateProfile(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := vm.Profile(snapshot.ID)
+	if got.Username != "jdoe" || got.Type != BackendWireGuard {
+		t.Errorf("Formularfelder nicht übernommen: %+v", got)
+	}
+	if got.WireGuardConfig == "" {
+		t.Error("WireGuard-Konfiguration durch älteren Stand überschrieben")
+	}
+}
+
+func TestUpdateProfileDropsPinOnServerChange(t *testing.T) {
+	vm := newTestManager(t)
+	profile, _ := vm.DefaultProfile()
+	vm.mu.Lock()
+	vm.settings.Profile(profile.ID).ServerCert = "pin-sha256:AAAA"
+	vm.mu.Unlock()
+
+	profile.ServerCert = ""
+	profile.Username = "jdoe"
+	if err := vm.UpdateProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := vm.Profile(profile.ID); got.ServerCert != "pin-sha256:AAAA" {
+		t.Errorf("Fingerprint bei gleichem Server verloren: %q", got.ServerCert)
+	}
+
+	profile.VPNServer = "other.example.com"
+	if err := vm.UpdateProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := vm.Profile(profile.ID); got.ServerCert != "" {
+		t.Errorf("Fingerprint nach Serverwechsel: %q", got.ServerCert)
+	}
+}
+
+func TestCopyProfileDeepCopiesPointers(t *testing.T) {
+	vm := newTestManager(t)
+	profile, _ := vm.DefaultProfile()
+	vm.mu.Lock()
+	stored := vm.settings.Profile(profile.ID)
+	stored.CertInfo = &models.CertInfo{Subject: "CN=jdoe", SANs: []string{"jdoe@example.com"}}
+	vm.mu.Unlock()
+
+	copied, _ := vm.Profile(profile.ID)
+	copied.CertInfo.Subject = "geändert"
+	copied.CertInfo.SANs[0] = "geändert"
+	if got, _ := vm.Profile(profile.ID); got.CertInfo.Subject != "CN=jdoe" || got.CertInfo.SANs[0] != "jdoe@example.com" {
+		t.Errorf("Kopie teilt CertInfo mit dem gespeicherten Profil: %+v", got.CertInfo)
+	}
+}
+
+func TestCloneProfileCopiesSecrets(t *testing.T) {
+	vm := newTestManager(t)
+	source, _ := vm.DefaultProfile()
+	if err := vm.SaveVPNPassword(source.ID, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := vm.CloneProfile(source.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := vm.GetVPNPassword(clone.ID); got != "hunter2" {
+		t.Errorf("VPN-Passwort der Kopie = %q", got)
+	}
+
+	// gesperrter Speicher: Fehler statt einer Kopie ohne Passwörter
+	if err := vm.LockSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	before := len(vm.Profiles())
+	if _, err := vm.CloneProfile(source.ID, "Zweite Kopie"); err == nil {
+		t.Fatal("Kopie trotz gesperrtem Passwort-Speicher")
+	}
+	if len(vm.Profiles()) != before {
+		t.Error("unvollständige Kopie angelegt")
+	}
+}
+
+func TestPinServerCertRequiresOptIn(t *testing.T) {
+	vm := newTestManager(t)
+	profile, _ := vm.DefaultProfile()
+	pin := "pin-sha256:" + strings.Repeat("A", 43) + "="
+
+	vm.pinServerCert(profile.ID, profile.VPNServer, pin)
+	if got, _ := vm.Profile(profile.ID); got.ServerCert != "" {
+		t.Errorf("Fingerprint ohne Zustimmung festgelegt: %q", got.ServerCert)
+	}
+
+	profile.PinServerCert = true
+	if err := vm.UpdateProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	vm.pinServerCert(profile.ID, profile.VPNServer, pin)
+	if got, _ := vm.Profile(profile.ID); got.ServerCert != pin {
+		t.Errorf("Fingerprint nicht festgelegt: %q", got.ServerCert)
+	}
+}
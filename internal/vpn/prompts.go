@@ -0,0 +1,273 @@
+package vpn
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promptKind ist die Art einer Eingabeaufforderung von openconnect.
+type promptKind int
+
+const (
+	promptUnknown      promptKind = iota
+	promptCertPassword            // Passphrase für PKCS#12/PEM-Schlüssel
+	promptUsername
+	promptPassword
+	promptGroup        // Auswahl von Gruppe/Realm/Gateway
+	promptSecondFactor // OTP, Token, Challenge
+	promptServerCert   // Rückfrage zum Server-Zertifikat (siehe servercert.go)
+)
+
+// authPrompt ist eine zerlegte Eingabeaufforderung, z.B. "GROUP: [VPN|Admin]:".
+type authPrompt struct {
+	kind    promptKind
+	label   string   // ohne Doppelpunkt und Auswahl, z.B. "GROUP"
+	options []string // Auswahlmöglichkeiten bei Listen
+}
+
+// Bezeichnungen der Eingabefelder (Kleinschreibung, Präfix genügt). Die
+// Reihenfolge zählt: "Second Password" ist ein zweiter Faktor, kein Passwort.
+var promptLabels = []struct {
+	prefix string
+	kind   promptKind
+}{
+	{"enter pkcs#12 pass phrase", promptCertPassword},
+	{"enter pem pass phrase", promptCertPassword},
+	{"enter pass phrase", promptCertPassword},
+	{"enter 'yes' to accept", promptServerCert},
+	{"second password", promptSecondFactor},
+	{"secondary password", promptSecondFactor},
+	{"password#2", promptSecondFactor},
+	{"username", promptUsername},
+	{"user name", promptUsername},
+	{"login", promptUsername},
+	{"password", promptPassword},
+	{"passwd", promptPassword},
+	{"group", promptGroup},
+	{"realm", promptGroup},
+	{"gateway", promptGroup},
+	{"authgroup", promptGroup},
+	{"response", promptSecondFactor},
+	{"challenge", promptSecondFactor},
+	{"verification code", promptSecondFactor},
+	{"passcode", promptSecondFactor},
+	{"token", promptSecondFactor},
+	{"otp", promptSecondFactor},
+	{"one-time", promptSecondFactor},
+	{"pin", promptSecondFactor},
+}
+
+// parsePrompt zerlegt eine Eingabeaufforderung. openconnect schreibt sie
+// ohne Zeilenumbruch, Auswahllisten als "<Label> [<a>|<b>]:".
+func parsePrompt(text string) authPrompt {
+	p := authPrompt{label: strings.TrimSpace(text)}
+	p.label = strings.TrimSpace(strings.TrimSuffix(p.label, ":"))
+
+	if open := strings.LastIndex(p.label, "["); open >= 0 && strings.HasSuffix(p.label, "]") {
+		p.options = strings.Split(p.label[open+1:len(p.label)-1], "|")
+		p.label = strings.TrimSuffix(strings.TrimSpace(p.label[:open]), ":")
+	}
+
+	lower := strings.ToLower(p.label)
+	for _, l := range promptLabels {
+		if strings.HasPrefix(lower, l.prefix) {
+			p.kind = l.kind
+			break
+		}
+	}
+	return p
+}
+
+// isPromptText erkennt eine unvollständige Zeile als Eingabeaufforderung.
+func isPromptText(text string) bool {
+	trimmed := strings.TrimRight(text, " \t")
+	return strings.HasSuffix(trimmed, ":") || strings.HasSuffix(trimmed, "?")
+}
+
+// promptQuiet ist die Wartezeit, nach der eine unvollständige Zeile als
+// Eingabeaufforderung gilt. openconnect schreibt danach nichts mehr, bis es
+// eine Antwort bekommt; eine auf mehrere Lesevorgänge verteilte Zeile, die
+// zufällig auf ":" endet, wird dagegen gleich fortgesetzt.
+const promptQuiet = 250 * time.Millisecond
+
+// outputChunk ist das Ergebnis eines Lesevorgangs.
+type outputChunk struct {
+	data []byte
+	err  error
+}
+
+// scanOutput liest r bis EOF und ruft line für jede vollständige Zeile auf.
+// Bleibt eine unvollständige Zeile übrig, die wie eine Eingabeaufforderung
+// endet, und folgt promptQuiet lang nichts mehr, wird sie an prompt
+// übergeben; openconnect wartet dann auf stdin.
+func scanOutput(r io.Reader, line func(string), prompt func(string)) {
+	chunks := make(chan outputChunk)
+	go func() {
+		for {
+			buf := make([]byte, 4096)
+			n, err := r.Read(buf)
+			chunks <- outputChunk{data: buf[:n], err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var pending []byte
+	var quiet <-chan time.Time
+	for {
+		select {
+		case chunk := <-chunks:
+			pending = append(pending, chunk.data...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				line(strings.TrimRight(string(pending[:i]), "\r"))
+				pending = pending[i+1:]
+			}
+
+			if chunk.err != nil {
+				if len(pending) > 0 {
+					line(string(pending))
+				}
+				return
+			}
+
+			quiet = nil
+			if len(pending) > 0 && isPromptText(string(pending)) {
+				quiet = time.After(promptQuiet)
+			}
+
+		case <-quiet:
+			prompt(string(pending))
+			pending = nil
+			quiet = nil
+		}
+	}
+}
+
+// maxGroupPrompts begrenzt wiederholte Gruppenabfragen (Formular nach
+// Gruppenwechsel erneut, danach gilt die Auswahl als abgelehnt).
+const maxGroupPrompts = 3
+
+// promptProgress sind die Fortschrittsmeldungen beim Beantworten.
+var promptProgress = map[promptKind]string{
+	promptCertPassword: "Sende Zertifikat-Passwort...",
+	promptUsername:     "Sende Benutzernamen...",
+	promptPassword:     "Sende VPN-Passwort...",
+	promptGroup:        "Wähle Gruppe...",
+	promptSecondFactor: "Sende TOTP-Code...",
+}
+
+// promptSecrets liefert die Antworten aus dem Profil bzw. Passwort-Speicher.
+type promptSecrets struct {
+	Username     string
+	Password     string
+	CertPassword string
+	AuthGroup    string
+	TOTPSeed     string // zweiter Faktor, siehe totp.go
+}
+
+// promptResponder beantwortet die Eingabeaufforderungen eines
+// Verbindungsversuchs. Eine wiederholte Frage nach demselben Geheimnis
+// bedeutet, dass die vorherige Antwort abgelehnt wurde.
+type promptResponder struct {
+	secrets promptSecrets
+	clock   totpClock
+
+	mu    sync.Mutex
+	asked map[promptKind]int
+}
+
+func newPromptResponder(secrets promptSecrets) *promptResponder {
+	return &promptResponder{secrets: secrets, clock: systemClock, asked: map[promptKind]int{}}
+}
+
+// answer liefert die Antwort auf eine Eingabeaufforderung. skip ist true,
+// wenn nicht geantwortet werden darf (Server-Zertifikat).
+func (r *promptResponder) answer(text string) (answer string, skip bool, err error) {
+	p := parsePrompt(text)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asked[p.kind]++
+	repeated := r.asked[p.kind] > 1
+
+	switch p.kind {
+	case promptServerCert:
+		return "", true, nil
+
+	case promptCertPassword:
+		switch {
+		case r.secrets.CertPassword == "":
+			return "", false, fmt.Errorf("Zertifikat ist passwortgeschützt, aber kein Zertifikat-Passwort gespeichert")
+		case repeated:
+			return "", false, fmt.Errorf("Zertifikat-Passwort wurde abgelehnt")
+		}
+		return r.secrets.CertPassword, false, nil
+
+	case promptUsername:
+		switch {
+		case r.secrets.Username == "":
+			return "", false, fmt.Errorf("Server fragt nach dem Benutzernamen, im Profil ist keiner hinterlegt")
+		case repeated:
+			return "", false, fmt.Errorf("Anmeldung fehlgeschlagen: Benutzername oder Passwort abgelehnt")
+		}
+		return r.secrets.Username, false, nil
+
+	case promptPassword:
+		switch {
+		case r.secrets.Password == "":
+			return "", false, fmt.Errorf("VPN-Passwort nicht gespeichert")
+		case repeated:
+			return "", false, fmt.Errorf("Anmeldung fehlgeschlagen: VPN-Passwort abgelehnt")
+		}
+		return r.secrets.Password, false, nil
+
+	case promptGroup:
+		// die Auswahl kommt bei AnyConnect nach einem Wechsel erneut
+		return r.answerGroup(p, r.asked[p.kind] > maxGroupPrompts)
+
+	case promptSecondFactor:
+		switch {
+		case r.secrets.TOTPSeed == "":
+			return "", false, fmt.Errorf("Server verlangt einen zweiten Faktor (%s), aber kein TOTP-Schlüssel gespeichert", p.label)
+		case repeated:
+			return "", false, fmt.Errorf("TOTP-Code wurde abgelehnt (Systemzeit und TOTP-Schlüssel prüfen)")
+		}
+		code, err := totpCode(r.secrets.TOTPSeed, r.clock)
+		return code, false, err
+	}
+	return "", false, fmt.Errorf("Unbekannte Eingabeaufforderung von openconnect: %q", strings.TrimSpace(text))
+}
+
+func (r *promptResponder) answerGroup(p authPrompt, repeated bool) (string, bool, error) {
+	group := r.secrets.AuthGroup
+	if repeated {
+		return "", false, fmt.Errorf("%s \"%s\" wurde abgelehnt", p.label, group)
+	}
+	if len(p.options) == 0 {
+		if group == "" {
+			return "", false, fmt.Errorf("Server fragt nach %s, im Profil ist keine hinterlegt", p.label)
+		}
+		return group, false, nil
+	}
+	for _, option := range p.options {
+		if strings.EqualFold(option, group) {
+			// nach der Gruppenwahl schickt der Server das Formular erneut
+			delete(r.asked, promptUsername)
+			delete(r.asked, promptPassword)
+			return option, false, nil
+		}
+	}
+	if group == "" {
+		return "", false, fmt.Errorf("Bitte %s im Profil wählen: %s", p.label, strings.Join(p.options, ", "))
+	}
+	return "", false, fmt.Errorf("%s \"%s\" nicht angeboten, verfügbar: %s", p.label, group, strings.Join(p.options, ", "))
+}
@@ -0,0 +1,280 @@
+package vpn
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// transcriptStep ist ein Lesevorgang aus einer aufgezeichneten Ausgabe von
+// openconnect --authenticate.
+type transcriptStep struct {
+	output string        // Ausgabe (ein Write auf die Pipe)
+	prompt bool          // Ausgabe endet mit einer Eingabeaufforderung
+	pause  time.Duration // Pause vor dem nächsten Schritt
+}
+
+// transcriptResult ist, was scanOutput und promptResponder aus einem
+// Transkript gemacht haben.
+type transcriptResult struct {
+	lines   []string
+	answers []string
+	err     error
+}
+
+// answerTimeout ist, wie lange runTranscript auf eine Antwort wartet: die
+// Ruhepause vor der Eingabeaufforderung plus das längste Warten auf einen
+// frischen TOTP-Code, mit Reserve.
+const answerTimeout = promptQuiet + totpMinValidity*time.Second + 2*time.Second
+
+// runTranscript spielt steps wie openconnect über eine Pipe ab: nach einer
+// Eingabeaufforderung geht es erst weiter, wenn sie beantwortet wurde.
+func runTranscript(t *testing.T, secrets promptSecrets, steps []transcriptStep) transcriptResult {
+	t.Helper()
+	return runTranscriptWith(t, newPromptResponder(secrets), steps)
+}
+
+// runTranscriptWith ist runTranscript mit vorbereitetem responder.
+func runTranscriptWith(t *testing.T, responder *promptResponder, steps []transcriptStep) transcriptResult {
+	t.Helper()
+	pr, pw := io.Pipe()
+
+	var mu sync.Mutex
+	var result transcriptResult
+	// true = weiter, false = abgebrochen; gepuffert, damit eine unerwartete
+	// Eingabeaufforderung scanOutput nicht blockiert
+	answered := make(chan bool, len(steps)+1)
+
+	go func() {
+		defer pw.Close()
+		for _, step := range steps {
+			if _, err := pw.Write([]byte(step.output)); err != nil {
+				return
+			}
+			if step.prompt {
+				select {
+				case ok := <-answered:
+					if !ok {
+						return
+					}
+				case <-time.After(answerTimeout):
+					t.Errorf("keine Antwort auf %q", step.output)
+					return
+				}
+			}
+			time.Sleep(step.pause)
+		}
+	}()
+
+	scanOutput(pr, func(line string) {
+		mu.Lock()
+		result.lines = append(result.lines, line)
+		mu.Unlock()
+	}, func(text string) {
+		answer, skip, err := responder.answer(text)
+		mu.Lock()
+		if err != nil {
+			result.err = err
+		} else if !skip {
+			result.answers = append(result.answers, answer)
+		}
+		mu.Unlock()
+		answered <- err == nil
+	})
+	return result
+}
+
+func TestTranscriptPKCS12Passphrase(t *testing.T) {
+	result := runTranscript(t, promptSecrets{CertPassword: "p12-secret"}, []transcriptStep{
+		{output: "POST https://vpn.example.com/\n"},
+		{output: "Using PKCS#12 certificate client.p12\n"},
+		{output: "Enter PKCS#12 pass phrase:", prompt: true},
+		{output: "\nUsing client certificate 'jdoe'\nConnected to 203.0.113.10:443\n"},
+	})
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if want := []string{"p12-secret"}; !reflect.DeepEqual(result.answers, want) {
+		t.Errorf("answers = %q, want %q", result.answers, want)
+	}
+}
+
+func TestTranscriptRejectedPKCS12Passphrase(t *testing.T) {
+	result := runTranscript(t, promptSecrets{CertPassword: "wrong"}, []transcriptStep{
+		{output: "Enter PKCS#12 pass phrase:", prompt: true},
+		{output: "\nFailed to decrypt PKCS#12 certificate file\nEnter PKCS#12 pass phrase:", prompt: true},
+	})
+	if result.err == nil || !strings.Contains(result.err.Error(), "abgelehnt") {
+		t.Fatalf("err = %v", result.err)
+	}
+}
+
+func TestTranscriptUsernamePassword(t *testing.T) {
+	result := runTranscript(t, promptSecrets{Username: "jdoe", Password: "hunter2"}, []transcriptStep{
+		{output: "POST https://vpn.example.com/\nConnected to 203.0.113.10:443\n"},
+		{output: "SSL negotiation with vpn.example.com\n"},
+		{output: "Connected to HTTPS on vpn.example.com with ciphersuite (TLS1.3)-(ECDHE-SECP256R1)-(RSA-PSS-RSAE-SHA256)-(AES-256-GCM)\n"},
+		{output: "XML POST enabled\nPlease enter your username and password.\n"},
+		{output: "Username:", prompt: true},
+		{output: "Password:", prompt: true},
+		{output: "POST https://vpn.example.com/\nGot CONNECT response: HTTP/1.1 200 OK\n"},
+	})
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if want := []string{"jdoe", "hunter2"}; !reflect.DeepEqual(result.answers, want) {
+		t.Errorf("answers = %q, want %q", result.answers, want)
+	}
+}
+
+func TestTranscriptGroupSelection(t *testing.T) {
+	secrets := promptSecrets{Username: "jdoe", Password: "hunter2", AuthGroup: "contractors"}
+	result := runTranscript(t, secrets, []transcriptStep{
+		{output: "XML POST enabled\nPlease enter your username and password.\n"},
+		{output: "GROUP: [Employees|Contractors]:", prompt: true},
+		{output: "POST https://vpn.example.com/\nXML POST enabled\nPlease enter your username and password.\n"},
+		{output: "Username:", prompt: true},
+		{output: "Password:", prompt: true},
+	})
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if want := []string{"Contractors", "jdoe", "hunter2"}; !reflect.DeepEqual(result.answers, want) {
+		t.Errorf("answers = %q, want %q", result.answers, want)
+	}
+}
+
+func TestTranscriptGroupNotOffered(t *testing.T) {
+	result := runTranscript(t, promptSecrets{AuthGroup: "Admins"}, []transcriptStep{
+		{output: "GROUP: [Employees|Contractors]:", prompt: true},
+	})
+	if result.err == nil || !strings.Contains(result.err.Error(), "Employees, Contractors") {
+		t.Fatalf("err = %v", result.err)
+	}
+}
+
+// fakeClock ist eine Uhr, die nur beim Warten weiterläuft.
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) totpClock() totpClock {
+	return totpClock{
+		now: func() time.Time { return c.now },
+		sleep: func(d time.Duration) {
+			c.slept = append(c.slept, d)
+			c.now = c.now.Add(d)
+		},
+	}
+}
+
+func TestTranscriptTOTP(t *testing.T) {
+	tests := []struct {
+		name  string
+		now   int64
+		want  string
+		slept []time.Duration
+	}{
+		{name: "mitten in der Periode", now: 1700000000, want: "324550"},
+		{name: "kurz vor Ablauf", now: 1700000009, want: "367665", slept: []time.Duration{time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(tt.now, 0)}
+			responder := newPromptResponder(promptSecrets{Username: "jdoe", Password: "hunter2", TOTPSeed: "JBSWY3DPEHPK3PXP"})
+			responder.clock = clock.totpClock()
+			result := runTranscriptWith(t, responder, []transcriptStep{
+				{output: "POST https://vpn.example.com/global-protect/prelogin.esp\n"},
+				{output: "Username:", prompt: true},
+				{output: "Password:", prompt: true},
+				{output: "POST https://vpn.example.com/ssl-vpn/login.esp\nChallenge: Enter the code from your authenticator app\n"},
+				{output: "Response:", prompt: true},
+				{output: "GlobalProtect login returned authentication-source=ldap\n"},
+			})
+			if result.err != nil {
+				t.Fatal(result.err)
+			}
+			if want := []string{"jdoe", "hunter2", tt.want}; !reflect.DeepEqual(result.answers, want) {
+				t.Errorf("answers = %q, want %q", result.answers, want)
+			}
+			if !reflect.DeepEqual(clock.slept, tt.slept) {
+				t.Errorf("gewartet = %v, want %v", clock.slept, tt.slept)
+			}
+		})
+	}
+}
+
+func TestTranscriptSecondFactorWithoutSeed(t *testing.T) {
+	result := runTranscript(t, promptSecrets{Username: "jdoe", Password: "hunter2"}, []transcriptStep{
+		{output: "Username:", prompt: true},
+		{output: "Password:", prompt: true},
+		{output: "Verification code:", prompt: true},
+	})
+	if result.err == nil || !strings.Contains(result.err.Error(), "kein TOTP-Schlüssel") {
+		t.Fatalf("err = %v", result.err)
+	}
+}
+
+func TestTranscriptUnknownPrompt(t *testing.T) {
+	result := runTranscript(t, promptSecrets{Username: "jdoe", Password: "hunter2"}, []transcriptStep{
+		{output: "POST https://vpn.example.com/\n"},
+		{output: "Please confirm your favourite colour:", prompt: true},
+	})
+	if result.err == nil || !strings.Contains(result.err.Error(), "Unbekannte Eingabeaufforderung") {
+		t.Fatalf("err = %v", result.err)
+	}
+	if len(result.answers) != 0 {
+		t.Errorf("answers = %q", result.answers)
+	}
+}
+
+func TestTranscriptLineSplitAcrossReads(t *testing.T) {
+	result := runTranscript(t, promptSecrets{Username: "jdoe", Password: "hunter2"}, []transcriptStep{
+		{output: "Got CONNECT response:", pause: 20 * time.Millisecond},
+		{output: " HTTP/1.1 200 OK\nCSTP connected. DPD 30, Keepalive 20\nConnected as 10.1.2.3, using SSL, with DTLS in progress\nEstablished DTLS connection (using GnuTLS). Ciphersuite (DTLS1.2)-(ECDHE-RSA)-(AES-256-GCM).\nSet up DTLS failed; using SSL instead? No:", pause: 20 * time.Millisecond},
+		{output: " it worked\n"},
+	})
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if len(result.answers) != 0 {
+		t.Errorf("answers = %q", result.answers)
+	}
+	want := []string{
+		"Got CONNECT response: HTTP/1.1 200 OK",
+		"CSTP connected. DPD 30, Keepalive 20",
+		"Connected as 10.1.2.3, using SSL, with DTLS in progress",
+		"Established DTLS connection (using GnuTLS). Ciphersuite (DTLS1.2)-(ECDHE-RSA)-(AES-256-GCM).",
+		"Set up DTLS failed; using SSL instead? No: it worked",
+	}
+	if !reflect.DeepEqual(result.lines, want) {
+		t.Errorf("lines = %q, want %q", result.lines, want)
+	}
+}
+
+func TestParsePrompt(t *testing.T) {
+	tests := []struct {
+		text    string
+		kind    promptKind
+		label   string
+		options []string
+	}{
+		{"Enter PKCS#12 pass phrase:", promptCertPassword, "Enter PKCS#12 pass phrase", nil},
+		{"Username:", promptUsername, "Username", nil},
+		{"Password:", promptPassword, "Password", nil},
+		{"Second Password:", promptSecondFactor, "Second Password", nil},
+		{"GROUP: [VPN|Admin]:", promptGroup, "GROUP", []string{"VPN", "Admin"}},
+		{"Enter 'yes' to accept, 'no' to abort; anything else to view:", promptServerCert, "Enter 'yes' to accept, 'no' to abort; anything else to view", nil},
+		{"Favourite colour:", promptUnknown, "Favourite colour", nil},
+	}
+	for _, tt := range tests {
+		p := parsePrompt(tt.text)
+		if p.kind != tt.kind || p.label != tt.label || !reflect.DeepEqual(p.options, tt.options) {
+			t.Errorf("parsePrompt(%q) = %+v", tt.text, p)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package vpn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpenConnectProtocol ist ein von openconnect unterstütztes VPN-Protokoll
+// (Profile.Protocol, Wert für --protocol).
+type OpenConnectProtocol struct {
+	Name  string
+	Label string
+
+	// Bezeichnung der Gruppen-/Realm-Auswahl (--authgroup); "" = nicht
+	// unterstützt, das Feld wird dann nicht übergeben
+	AuthGroup         string
+	AuthGroupRequired bool
+
+	// Client-Zertifikat erforderlich (sonst optional)
+	CertRequired bool
+
+	// Ausgabezeilen je Protokoll, zusätzlich zu den allgemeinen in stateFromOutput
+	connected      []string // Tunnel steht
+	authenticating []string // Anmeldung läuft
+	failures       []string // Anmeldung endgültig gescheitert
+}
+
+// Standardprotokoll für Profile ohne Protocol
+const defaultProtocol = "anyconnect"
+
+var openconnectProtocols = []OpenConnectProtocol{
+	{
+		Name:              "anyconnect",
+		Label:             "Cisco AnyConnect",
+		AuthGroup:         "Auth Group",
+		AuthGroupRequired: true,
+		CertRequired:      true,
+		connected:         []string{"CSTP connected"},
+		authenticating:    []string{"XML POST enabled"},
+	},
+	{
+		Name:           "gp",
+		Label:          "Palo Alto GlobalProtect",
+		connected:      []string{"ESP session established", "Connected as "},
+		authenticating: []string{"Portal reports GlobalProtect version", "GlobalProtect gateway"},
+		failures:       []string{"portal-userauthfailed", "gateway-userauthfailed", "Invalid username or password"},
+	},
+	{
+		Name:      "nc",
+		Label:     "Juniper Network Connect",
+		AuthGroup: "Realm",
+		connected: []string{"ESP session established", "oNCP negotiation complete"},
+		failures:  []string{"Invalid username or password", "Authentication failure"},
+	},
+	{
+		Name:      "pulse",
+		Label:     "Pulse Secure / Ivanti",
+		AuthGroup: "Realm",
+		connected: []string{"ESP session established", "Connected as "},
+		failures:  []string{"Authentication failure", "Invalid username or password"},
+	},
+	{
+		Name:           "fortinet",
+		Label:          "Fortinet FortiGate",
+		AuthGroup:      "Realm",
+		connected:      []string{"Established PPP", "Connected as "},
+		authenticating: []string{"SVPNCOOKIE"},
+		failures:       []string{"Invalid credentials", "Invalid username or password", "permission denied"},
+	},
+	{
+		Name:      "array",
+		Label:     "Array Networks",
+		AuthGroup: "Anmeldemethode",
+		connected: []string{"Connected as ", "Established PPP"},
+		failures:  []string{"Invalid username or password"},
+	},
+}
+
+// OpenConnectProtocols liefert die unterstützten Protokolle (für die Oberfläche).
+func OpenConnectProtocols() []OpenConnectProtocol {
+	return openconnectProtocols
+}
+
+// openconnectProtocolFor liefert ein Protokoll (leerer Name = anyconnect).
+func openconnectProtocolFor(name string) (OpenConnectProtocol, error) {
+	if name == "" {
+		name = defaultProtocol
+	}
+	for _, p := range openconnectProtocols {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return OpenConnectProtocol{}, fmt.Errorf("Unbekanntes Protokoll \"%s\"", name)
+}
+
+func containsAny(line string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(line, m) {
+			return true
+		}
+	}
+	return false
+}
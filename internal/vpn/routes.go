@@ -0,0 +1,235 @@
+package vpn
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"vpn-web/internal/models"
+)
+
+// defaultRoutes gehen bei neuen Profilen durch den Tunnel.
+var defaultRoutes = []string{"172.16.1.0/24", "192.168.13.0/24", "10.33.38.0/24"}
+
+var routeHostPattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?\.)*[A-Za-z]([A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// SplitRouteList trennt eine eingegebene Liste an Leerzeichen, Kommas und
+// Zeilenumbrüchen.
+func SplitRouteList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+}
+
+// parseRoutePrefix liest ein Netz (CIDR) oder eine einzelne Adresse. Bei
+// einem Netz werden Hostbits abgeschnitten (10.1.2.3/24 → 10.1.2.0/24).
+func parseRoutePrefix(entry string) (netip.Prefix, bool) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+		return prefix.Masked(), true
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil || addr.Zone() != "" {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+func formatRoutePrefix(prefix netip.Prefix) string {
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+// normalizeRoutes prüft die Einträge und bereinigt sie: Netze ohne Hostbits,
+// Hostnamen klein geschrieben, Doppelte und Netze, die in einem anderen
+// Eintrag derselben Liste enthalten sind, entfallen, benachbarte Netze
+// werden zusammengefasst. Ausnahmen müssen Netze
+// oder Adressen sein und dürfen keinen Eintrag vollständig verdecken.
+func normalizeRoutes(routes models.Routes) (models.Routes, error) {
+	var result models.Routes
+
+	var include []netip.Prefix
+	seenHosts := map[string]bool{}
+	for _, entry := range routes.Include {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, ok := parseRoutePrefix(entry); ok {
+			include = append(include, prefix)
+			continue
+		}
+		if len(entry) > 253 || !routeHostPattern.MatchString(entry) {
+			return models.Routes{}, fmt.Errorf("Ungültige Route \"%s\" (Netz, Adresse oder Hostname erwartet)", entry)
+		}
+		host := strings.ToLower(entry)
+		if !seenHosts[host] {
+			seenHosts[host] = true
+			result.Include = append(result.Include, host)
+		}
+	}
+
+	var exclude []netip.Prefix
+	for _, entry := range routes.Exclude {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, ok := parseRoutePrefix(entry)
+		if !ok {
+			return models.Routes{}, fmt.Errorf("Ungültige Ausnahme \"%s\" (Netz oder Adresse erwartet)", entry)
+		}
+		exclude = append(exclude, prefix)
+	}
+
+	include = mergePrefixes(include)
+	exclude = mergePrefixes(exclude)
+	for _, ex := range exclude {
+		for _, in := range include {
+			if ex.Bits() <= in.Bits() && ex.Overlaps(in) {
+				return models.Routes{}, fmt.Errorf("Ausnahme \"%s\" schließt die Route \"%s\" vollständig aus", formatRoutePrefix(ex), formatRoutePrefix(in))
+			}
+		}
+	}
+
+	prefixes := make([]string, 0, len(include))
+	for _, prefix := range include {
+		prefixes = append(prefixes, formatRoutePrefix(prefix))
+	}
+	result.Include = append(prefixes, result.Include...)
+	for _, prefix := range exclude {
+		result.Exclude = append(result.Exclude, formatRoutePrefix(prefix))
+	}
+	return result, nil
+}
+
+// mergePrefixes entfernt Doppelte und Netze, die in einem größeren Netz der
+// Liste enthalten sind, und fasst benachbarte gleich große Netze zusammen
+// (10.0.0.0/25 und 10.0.0.128/25 → 10.0.0.0/24); IPv4 vor IPv6, sonst nach
+// Adresse sortiert.
+func mergePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	for {
+		sort.Slice(prefixes, func(i, j int) bool {
+			if prefixes[i].Bits() != prefixes[j].Bits() {
+				return prefixes[i].Bits() < prefixes[j].Bits()
+			}
+			return prefixes[i].Addr().Less(prefixes[j].Addr())
+		})
+
+		var merged []netip.Prefix
+		for _, prefix := range prefixes {
+			covered := false
+			for _, m := range merged {
+				if m.Overlaps(prefix) {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				merged = append(merged, prefix)
+			}
+		}
+
+		// nach Größe und Adresse sortiert liegen Nachbarn direkt hintereinander
+		joined := false
+		for i := 0; i+1 < len(merged); i++ {
+			a, b := merged[i], merged[i+1]
+			if a.Bits() == 0 || a.Bits() != b.Bits() || a.Addr().Is4() != b.Addr().Is4() {
+				continue
+			}
+			if parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked(); parent == netip.PrefixFrom(b.Addr(), b.Bits()-1).Masked() {
+				merged[i], merged[i+1] = parent, parent
+				joined = true
+				i++
+			}
+		}
+		prefixes = merged
+		if !joined {
+			break
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].Addr().Less(prefixes[j].Addr())
+	})
+	return prefixes
+}
+
+// vpncScriptArgs liefert die Ziele für "vpn-web vpnc-script" (Schreibweise
+// wie bei vpn-slice): Routen unverändert, Ausnahmen mit vorangestelltem "%".
+func vpncScriptArgs(routes models.Routes) []string {
+	args := append([]string(nil), routes.Include...)
+	for _, entry := range routes.Exclude {
+		args = append(args, "%"+entry)
+	}
+	return args
+}
+
+// vpncScript liefert das Kommando für openconnect -s: vpn-web selbst als
+// vpnc-script (siehe internal/vpnc) mit den Routen und DNS-Domains des
+// Profils. openconnect führt es per /bin/sh aus.
+func vpncScript(routes models.Routes, domains []string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("Programmpfad von vpn-web nicht ermittelbar: %v", err)
+	}
+	args := []string{shellQuote(exe), "vpnc-script"}
+	for _, domain := range domains {
+		args = append(args, "--domain", domain)
+	}
+	args = append(args, vpncScriptArgs(routes)...)
+	return strings.Join(args, " "), nil
+}
+
+// shellQuote setzt einen Pfad in einfache Anführungszeichen.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// normalizeDNSDomains prüft die Domains für Split-DNS: klein geschrieben,
+// ohne führenden oder abschließenden Punkt, ohne Doppelte.
+func normalizeDNSDomains(domains []string) ([]string, error) {
+	var result []string
+	seen := map[string]bool{}
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+		if domain == "" || seen[domain] {
+			continue
+		}
+		if len(domain) > 253 || !routeHostPattern.MatchString(domain) {
+			return nil, fmt.Errorf("Ungültige DNS-Domain \"%s\"", domain)
+		}
+		seen[domain] = true
+		result = append(result, domain)
+	}
+	return result, nil
+}
+
+// migrateRoutesLocked übernimmt die früher als Text gespeicherten Netzwerke
+// in die Routen. Ungültige Einträge werden verworfen und protokolliert.
+func (vm *Manager) migrateRoutesLocked() {
+	for i := range vm.settings.Profiles {
+		p := &vm.settings.Profiles[i]
+		if p.Networks == "" {
+			continue
+		}
+		var include []string
+		for _, entry := range SplitRouteList(p.Networks) {
+			if _, err := normalizeRoutes(models.Routes{Include: []string{entry}}); err != nil {
+				vm.logf(LogWarn, "Route migration %s: dropping %q: %v", p.Name, entry, err)
+				continue
+			}
+			include = append(include, entry)
+		}
+		p.Routes, _ = normalizeRoutes(models.Routes{Include: include})
+		p.Networks = ""
+	}
+}
@@ -0,0 +1,116 @@
+package vpn
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"vpn-web/internal/models"
+)
+
+func TestParseRoutePrefix(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  string // leer = ungültig
+	}{
+		{"10.1.2.0/24", "10.1.2.0/24"},
+		{"10.1.2.3/24", "10.1.2.0/24"},
+		{"10.1.2.3", "10.1.2.3/32"},
+		{"0.0.0.0/0", "0.0.0.0/0"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"2001:db8:1:2::5/48", "2001:db8:1::/48"},
+		{"::ffff:10.1.2.3", "10.1.2.3/32"},
+		{"10.1.2.0/33", ""},
+		{"10.1.2/24", ""},
+		{"10.1.2.256", ""},
+		{"2001:db8::/129", ""},
+		{"fe80::1%eth0", ""},
+		{"vpn.example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		prefix, ok := parseRoutePrefix(tt.entry)
+		got := ""
+		if ok {
+			got = prefix.String()
+		}
+		if got != tt.want {
+			t.Errorf("parseRoutePrefix(%q) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRoutes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   models.Routes
+		want models.Routes
+	}{
+		{
+			name: "Hostbits und Doppelte",
+			in:   models.Routes{Include: []string{"10.1.2.3/24", " 10.1.2.0/24 ", "", "10.1.2.0/24"}},
+			want: models.Routes{Include: []string{"10.1.2.0/24"}},
+		},
+		{
+			name: "enthaltene Netze",
+			in:   models.Routes{Include: []string{"10.1.2.0/24", "10.0.0.0/8", "10.9.9.9", "192.168.1.0/24"}},
+			want: models.Routes{Include: []string{"10.0.0.0/8", "192.168.1.0/24"}},
+		},
+		{
+			name: "benachbarte Netze",
+			in:   models.Routes{Include: []string{"10.0.0.128/25", "10.0.0.0/25", "10.0.1.0/24"}},
+			want: models.Routes{Include: []string{"10.0.0.0/23"}},
+		},
+		{
+			name: "nicht zusammenfassbare Nachbarn",
+			in:   models.Routes{Include: []string{"10.0.1.0/24", "10.0.2.0/24"}},
+			want: models.Routes{Include: []string{"10.0.1.0/24", "10.0.2.0/24"}},
+		},
+		{
+			name: "IPv6 und IPv4",
+			in:   models.Routes{Include: []string{"2001:db8:0:1::/64", "10.0.0.1", "2001:db8::/64", "2001:DB8::5"}},
+			want: models.Routes{Include: []string{"10.0.0.1", "2001:db8::/63"}},
+		},
+		{
+			name: "Hostnamen",
+			in:   models.Routes{Include: []string{"Intranet.Example.com", "172.16.0.0/12", "intranet.example.com", "wiki"}},
+			want: models.Routes{Include: []string{"172.16.0.0/12", "intranet.example.com", "wiki"}},
+		},
+		{
+			name: "Ausnahmen",
+			in:   models.Routes{Include: []string{"10.0.0.0/8"}, Exclude: []string{"10.1.0.0/16", "10.1.2.3/16", "10.2.0.1"}},
+			want: models.Routes{Include: []string{"10.0.0.0/8"}, Exclude: []string{"10.1.0.0/16", "10.2.0.1"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := normalizeRoutes(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: normalizeRoutes = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRoutesRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		in   models.Routes
+		want string
+	}{
+		{"ungültiges CIDR", models.Routes{Include: []string{"10.0.0.0/33"}}, "Ungültige Route \"10.0.0.0/33\""},
+		{"ungültiger Hostname", models.Routes{Include: []string{"-intranet.example.com"}}, "Ungültige Route"},
+		{"Hostname mit Unterstrich", models.Routes{Include: []string{"my_host"}}, "Ungültige Route"},
+		{"Hostname zu lang", models.Routes{Include: []string{strings.Repeat("a.", 127) + "de"}}, "Ungültige Route"},
+		{"Ausnahme als Hostname", models.Routes{Include: []string{"10.0.0.0/8"}, Exclude: []string{"intranet.example.com"}}, "Ungültige Ausnahme"},
+		{"Ausnahme verdeckt Route", models.Routes{Include: []string{"10.1.0.0/16"}, Exclude: []string{"10.0.0.0/8"}}, "schließt die Route \"10.1.0.0/16\" vollständig aus"},
+		{"Ausnahme gleich Route", models.Routes{Include: []string{"2001:db8::/32"}, Exclude: []string{"2001:db8::/32"}}, "vollständig aus"},
+	}
+	for _, tt := range tests {
+		if _, err := normalizeRoutes(tt.in); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
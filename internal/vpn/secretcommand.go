@@ -0,0 +1,148 @@
+package vpn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"vpn-web/internal/models"
+)
+
+const defaultSecretCommandTimeout = 10 * time.Second
+
+// runSecretCommand führt das Kommando ohne Shell aus und liefert die erste
+// Zeile der Ausgabe (bei `pass show` steht das Passwort in Zeile 1).
+// Das Ergebnis wird nirgends gespeichert.
+func runSecretCommand(sc models.SecretCommand, profile models.Profile) (string, error) {
+	args, err := expandSecretCommand(sc.Command, profile)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := defaultSecretCommandTimeout
+	if sc.Timeout > 0 {
+		timeout = time.Duration(sc.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s: Zeitüberschreitung nach %s", args[0], timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", args[0], firstLine(msg))
+		}
+		return "", fmt.Errorf("%s: %v", args[0], err)
+	}
+
+	secret := firstLine(stdout.String())
+	if secret == "" {
+		return "", fmt.Errorf("%s: keine Ausgabe", args[0])
+	}
+	return secret, nil
+}
+
+// validateSecretCommand prüft Syntax und Existenz des Programms.
+func validateSecretCommand(command string) error {
+	args, err := splitCommandLine(command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("Kommando ist leer")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return fmt.Errorf("Programm \"%s\" nicht gefunden", args[0])
+	}
+	return nil
+}
+
+// expandSecretCommand zerlegt das Kommando und ersetzt die Platzhalter
+// je Argument, damit eingesetzte Werte nie neue Argumente erzeugen.
+func expandSecretCommand(command string, profile models.Profile) ([]string, error) {
+	args, err := splitCommandLine(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("Kommando ist leer")
+	}
+
+	replacer := strings.NewReplacer(
+		"{profile}", profile.Name,
+		"{username}", profile.Username,
+		"{server}", profile.VPNServer,
+	)
+	for i := range args {
+		args[i] = replacer.Replace(args[i])
+	}
+	return args, nil
+}
+
+// splitCommandLine zerlegt eine Kommandozeile nach Shell-Regeln für
+// Leerzeichen, einfache/doppelte Anführungszeichen und Backslash.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\' && quote != '\'':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("Kommando endet mit Backslash")
+			}
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Anführungszeichen nicht geschlossen")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimRight(line, "\r")
+}
@@ -0,0 +1,130 @@
+package vpn
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"vpn-web/internal/keychain"
+)
+
+// Verfügbare Backends für den Passwort-Speicher
+const (
+	SecretStoreKeychain      = "keychain"       // macOS Keychain (security)
+	SecretStoreSecretService = "secret-service" // GNOME Keyring/KWallet (secret-tool)
+	SecretStoreFile          = "file"           // verschlüsselte Datei mit Master-Passphrase
+)
+
+func defaultSecretStore() string {
+	switch {
+	case runtime.GOOS == "darwin":
+		return SecretStoreKeychain
+	case runtime.GOOS == "linux" && keychain.SecretServiceAvailable():
+		return SecretStoreSecretService
+	default:
+		return SecretStoreFile
+	}
+}
+
+func (vm *Manager) newSecretStore(kind string) keychain.SecretStore {
+	switch kind {
+	case SecretStoreKeychain:
+		return keychain.NewKeychainManager()
+	case SecretStoreSecretService:
+		return keychain.NewSecretServiceStore()
+	default:
+		return keychain.NewFileStore(filepath.Join(filepath.Dir(vm.settingsFile), ".vpn_web_secrets.json"))
+	}
+}
+
+// SecretStoreStatus beschreibt das aktive Backend für die Web-Oberfläche.
+type SecretStoreStatus struct {
+	Backend     string `json:"backend"`
+	Label       string `json:"label"`
+	Lockable    bool   `json:"lockable"`
+	Locked      bool   `json:"locked"`
+	Initialized bool   `json:"initialized"`
+}
+
+func (vm *Manager) SecretStoreStatus() SecretStoreStatus {
+	vm.mu.Lock()
+	status := SecretStoreStatus{Backend: vm.settings.SecretStore}
+	vm.mu.Unlock()
+
+	switch status.Backend {
+	case SecretStoreKeychain:
+		status.Label = "macOS Keychain"
+	case SecretStoreSecretService:
+		status.Label = "Secret Service (GNOME Keyring/KWallet)"
+	default:
+		status.Label = "verschlüsselter Passwort-Datei"
+	}
+
+	if locker, ok := vm.secrets.(keychain.Locker); ok {
+		status.Lockable = true
+		status.Locked = locker.Locked()
+		status.Initialized = locker.Initialized()
+	}
+	return status
+}
+
+// SecretsLocked meldet, ob der Passwort-Speicher erst entsperrt werden muss.
+func (vm *Manager) SecretsLocked() bool {
+	locker, ok := vm.secrets.(keychain.Locker)
+	return ok && locker.Locked()
+}
+
+// UnlockSecrets entsperrt den Passwort-Speicher.
+func (vm *Manager) UnlockSecrets(passphrase string) error {
+	locker, ok := vm.secrets.(keychain.Locker)
+	if !ok {
+		return fmt.Errorf("Passwort-Speicher \"%s\" unterstützt kein Entsperren", vm.SecretStoreStatus().Backend)
+	}
+	if err := locker.Unlock(passphrase); err != nil {
+		return err
+	}
+	return vm.migratePendingSecrets()
+}
+
+// CreateSecrets legt den Passwort-Speicher mit einer neuen Passphrase an.
+func (vm *Manager) CreateSecrets(passphrase, confirm string) error {
+	locker, ok := vm.secrets.(keychain.Locker)
+	if !ok {
+		return fmt.Errorf("Passwort-Speicher \"%s\" benötigt keine Passphrase", vm.SecretStoreStatus().Backend)
+	}
+	if err := locker.Create(passphrase, confirm); err != nil {
+		return err
+	}
+	return vm.migratePendingSecrets()
+}
+
+// migratePendingSecrets verschiebt nach dem Entsperren die noch
+// vorgemerkten Einträge des alten Formats und PresharedKeys, die noch im
+// Profil stehen.
+func (vm *Manager) migratePendingSecrets() error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	changed := vm.migrateWireGuardKeysLocked()
+	if len(vm.settings.LegacySecrets) > 0 {
+		vm.migrateLegacySecretsLocked()
+		changed = true
+	}
+	if changed {
+		return vm.saveSettingsLocked()
+	}
+	return nil
+}
+
+// LockSecrets sperrt den Passwort-Speicher wieder.
+func (vm *Manager) LockSecrets() error {
+	locker, ok := vm.secrets.(keychain.Locker)
+	if !ok {
+		return fmt.Errorf("Passwort-Speicher \"%s\" unterstützt kein Sperren", vm.SecretStoreStatus().Backend)
+	}
+	locker.Lock()
+	return nil
+}
+
+// StoredAccounts listet alle Accounts im Passwort-Speicher.
+func (vm *Manager) StoredAccounts() ([]string, error) {
+	return vm.secrets.List()
+}
@@ -0,0 +1,183 @@
+package vpn
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerCertPrompt ist ein Server-Zertifikat, das der Benutzer bestätigen
+// muss, bevor erneut verbunden wird (Trust on First Use).
+type ServerCertPrompt struct {
+	Profile     string    `json:"profile"`
+	Host        string    `json:"host,omitempty"`
+	Fingerprint string    `json:"fingerprint"`        // pin-sha256:...
+	Reason      string    `json:"reason,omitempty"`   // z.B. "signer not found"
+	Previous    string    `json:"previous,omitempty"` // bisheriger Pin: Zertifikat hat sich geändert
+	Time        time.Time `json:"time"`
+}
+
+var pinPattern = regexp.MustCompile(`pin-sha256:[A-Za-z0-9+/=]+`)
+
+// serverCertWatcher erkennt in der openconnect-Ausgabe die Rückfrage zu
+// einem unbekannten Server-Zertifikat:
+//
+//	Certificate from VPN server "vpn.example.com" failed verification.
+//	Reason: signer not found
+//	To trust this server in future, perhaps add this to your command line:
+//	    --servercert pin-sha256:...
+//	Enter 'yes' to accept, 'no' to abort; anything else to view:
+//
+// sowie ein abweichendes Zertifikat bei gesetztem --servercert.
+type serverCertWatcher struct {
+	mu     sync.Mutex
+	host   string
+	reason string
+}
+
+func newServerCertWatcher() *serverCertWatcher {
+	return &serverCertWatcher{}
+}
+
+// check wertet eine Zeile aus. ok ist true, wenn openconnect ein nicht
+// vertrauenswürdiges Zertifikat meldet; pin kann bei changed leer sein.
+func (w *serverCertWatcher) check(line string) (pin string, changed, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(trimmed, "Certificate from VPN server"):
+		if _, rest, found := strings.Cut(trimmed, "\""); found {
+			w.host, _, _ = strings.Cut(rest, "\"")
+		}
+	case strings.HasPrefix(trimmed, "Reason: "):
+		w.reason = strings.TrimPrefix(trimmed, "Reason: ")
+	case strings.Contains(trimmed, "certificate didn't match"):
+		ok, changed = true, true
+	case strings.HasPrefix(trimmed, "--servercert "):
+		ok = true
+	}
+	if ok {
+		pin = pinPattern.FindString(trimmed)
+	}
+	return pin, changed, ok
+}
+
+func (w *serverCertWatcher) details() (host, reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.host, w.reason
+}
+
+// serverCertFailure legt die Rückfrage für die Oberfläche an und liefert
+// die Fehlermeldung für den Verbindungsversuch.
+func (s *Session) serverCertFailure(w *serverCertWatcher, pin string, changed bool) string {
+	host, reason := w.details()
+	if host == "" {
+		host = s.Profile.VPNServer
+	}
+
+	prompt := &ServerCertPrompt{
+		Profile:     s.Profile.ID,
+		Host:        host,
+		Fingerprint: pin,
+		Reason:      reason,
+		Time:        time.Now(),
+	}
+	if changed {
+		prompt.Previous = s.Profile.ServerCert
+	}
+
+	vm := s.vm
+	vm.mu.Lock()
+	if pin != "" {
+		vm.certPrompt = prompt
+	}
+	vm.mu.Unlock()
+
+	if changed {
+		vm.logf(LogError, "Server certificate for %s changed: expected %s, got %s", host, s.Profile.ServerCert, pin)
+		return fmt.Sprintf("ACHTUNG: Server-Zertifikat von %s hat sich geändert (erwartet %s). Verbindung abgebrochen.", host, s.Profile.ServerCert)
+	}
+	vm.logf(LogWarn, "Unknown server certificate for %s: %s", host, pin)
+	return fmt.Sprintf("Unbekanntes Server-Zertifikat von %s. Bitte Fingerprint prüfen und bestätigen.", host)
+}
+
+// AcceptServerCert speichert den bestätigten Fingerprint im Profil; er wird
+// bei den folgenden Verbindungen als --servercert übergeben.
+func (vm *Manager) AcceptServerCert(profileID, fingerprint string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	prompt := vm.certPrompt
+	if prompt == nil || prompt.Profile != profileID || prompt.Fingerprint != fingerprint {
+		return fmt.Errorf("Keine passende Zertifikatsrückfrage offen")
+	}
+	profile := vm.settings.Profile(profileID)
+	if profile == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+
+	profile.ServerCert = fingerprint
+	profile.LastModified = time.Now().Format(time.RFC3339)
+	if err := vm.saveSettingsLocked(); err != nil {
+		return err
+	}
+	vm.logf(LogInfo, "Server certificate for profile %s accepted: %s", profile.Name, fingerprint)
+	vm.certPrompt = nil
+	vm.events.publish(EventState, vm.statusEventLocked())
+	return nil
+}
+
+// pinServerCert speichert nach der ersten erfolgreichen Anmeldung den
+// Fingerprint, den openconnect --authenticate meldet (Trust on First Use),
+// nur für Profile mit PinServerCert. Das Zertifikat hat openconnect dabei
+// gegen die CAs geprüft; jede Erneuerung muss danach bestätigt werden.
+func (vm *Manager) pinServerCert(profileID, server, fingerprint string) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	profile := vm.settings.Profile(profileID)
+	if profile == nil || !profile.PinServerCert || profile.ServerCert != "" || profile.VPNServer != server {
+		return
+	}
+	if pinPattern.FindString(fingerprint) != fingerprint {
+		vm.logf(LogWarn, "Ignoring unexpected server fingerprint %q", fingerprint)
+		return
+	}
+	profile.ServerCert = fingerprint
+	profile.LastModified = time.Now().Format(time.RFC3339)
+	if err := vm.saveSettingsLocked(); err != nil {
+		vm.logf(LogWarn, "Saving server certificate for profile %s failed: %v", profile.Name, err)
+		return
+	}
+	vm.logf(LogInfo, "Server certificate for profile %s pinned: %s", profile.Name, fingerprint)
+}
+
+// RejectServerCert verwirft eine offene Zertifikatsrückfrage.
+func (vm *Manager) RejectServerCert() {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.certPrompt != nil {
+		vm.certPrompt = nil
+		vm.events.publish(EventState, vm.statusEventLocked())
+	}
+}
+
+// ForgetServerCert entfernt den gespeicherten Fingerprint eines Profils;
+// beim nächsten Verbinden wird erneut nachgefragt.
+func (vm *Manager) ForgetServerCert(profileID string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	profile := vm.settings.Profile(profileID)
+	if profile == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	profile.ServerCert = ""
+	profile.LastModified = time.Now().Format(time.RFC3339)
+	return vm.saveSettingsLocked()
+}
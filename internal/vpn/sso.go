@@ -0,0 +1,152 @@
+package vpn
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// SSOLogin ist eine laufende Anmeldung per Browser (SAML/SSO). Die
+// Oberfläche öffnet URL; das Cookie kommt entweder über openconnect selbst
+// (Rückleitung des IdP an openconnect) oder über SubmitSSOCookie.
+type SSOLogin struct {
+	Profile string    `json:"profile"`
+	URL     string    `json:"url,omitempty"` // leer, bis openconnect die Login-URL meldet
+	Time    time.Time `json:"time"`
+}
+
+// ssoAttempt ist der interne Teil einer SSO-Anmeldung, geschützt durch vm.mu.
+type ssoAttempt struct {
+	login  SSOLogin
+	token  string      // Kennung für den Aufruf von openconnect (--external-browser)
+	cookie chan string // von Hand übergebenes Cookie
+	cancel chan struct{}
+}
+
+// ssoTimeout begrenzt die Anmeldung im Browser (MFA dauert).
+const ssoTimeout = 5 * time.Minute
+
+// ssoCallbackEnv übergibt dem --external-browser-Aufruf die Rückruf-URL.
+const ssoCallbackEnv = "VPN_WEB_SSO_CALLBACK"
+
+// SetBaseURL legt die Adresse fest, unter der die Oberfläche lokal
+// erreichbar ist (für den Rückruf aus openconnect).
+func (vm *Manager) SetBaseURL(base string) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.baseURL = strings.TrimSuffix(base, "/")
+}
+
+// beginSSO legt eine SSO-Anmeldung für profileID an und liefert die
+// Umgebungsvariable für openconnect.
+func (vm *Manager) beginSSO(profileID string) (*ssoAttempt, string) {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	attempt := &ssoAttempt{
+		login:  SSOLogin{Profile: profileID, Time: time.Now()},
+		token:  hex.EncodeToString(b),
+		cookie: make(chan string, 1),
+		cancel: make(chan struct{}),
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.sso = attempt
+	callback := vm.baseURL + "/sso/callback?token=" + attempt.token
+	vm.events.publish(EventState, vm.statusEventLocked())
+	return attempt, ssoCallbackEnv + "=" + callback
+}
+
+// endSSO entfernt die SSO-Anmeldung, sobald der Anmeldeprozess beendet ist.
+func (vm *Manager) endSSO(attempt *ssoAttempt) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.sso == attempt {
+		vm.sso = nil
+		vm.events.publish(EventState, vm.statusEventLocked())
+	}
+}
+
+// cancelSSOLocked bricht eine wartende SSO-Anmeldung ab (Trennen).
+func (vm *Manager) cancelSSOLocked() {
+	if vm.sso != nil {
+		close(vm.sso.cancel)
+		vm.sso = nil
+	}
+}
+
+func (vm *Manager) ssoLoginLocked() *SSOLogin {
+	if vm.sso == nil {
+		return nil
+	}
+	login := vm.sso.login
+	return &login
+}
+
+// SetSSOURL übernimmt die Login-URL des Identity Providers, die openconnect
+// über --external-browser meldet. token muss zur laufenden Anmeldung passen.
+func (vm *Manager) SetSSOURL(token, loginURL string) error {
+	u, err := url.Parse(loginURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("Ungültige Login-URL")
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.sso == nil || token == "" || token != vm.sso.token {
+		return fmt.Errorf("Keine passende SSO-Anmeldung offen")
+	}
+	vm.sso.login.URL = loginURL
+	vm.logf(LogInfo, "SSO login URL received: %s://%s%s", u.Scheme, u.Host, u.Path)
+	vm.events.publish(EventState, vm.statusEventLocked())
+	return nil
+}
+
+// SubmitSSOCookie übergibt ein Sitzungs-Cookie, das der Benutzer nach der
+// Anmeldung im Browser erhalten hat (z.B. webvpn-Cookie). Der Tunnel wird
+// damit ohne weitere Anmeldung gestartet.
+func (vm *Manager) SubmitSSOCookie(profileID, cookie string) error {
+	cookie = strings.TrimSpace(cookie)
+	if cookie == "" {
+		return fmt.Errorf("Kein Cookie angegeben")
+	}
+	if strings.ContainsAny(cookie, "\r\n") {
+		return fmt.Errorf("Cookie darf keine Zeilenumbrüche enthalten")
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.sso == nil || vm.sso.login.Profile != profileID {
+		return fmt.Errorf("Keine passende SSO-Anmeldung offen")
+	}
+	notify(vm.sso.cookie, cookie)
+	return nil
+}
+
+// RunExternalBrowser wird in main vor allem anderen aufgerufen. openconnect
+// startet im SSO-Modus vpn-web selbst als --external-browser mit der
+// Login-URL als Argument; die URL geht dann an die laufende Oberfläche.
+// handled ist false bei einem normalen Programmstart.
+func RunExternalBrowser(args []string) (handled bool, err error) {
+	callback := os.Getenv(ssoCallbackEnv)
+	if callback == "" || len(args) != 1 {
+		return false, nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.PostForm(callback, url.Values{"action": {"url"}, "url": {args[0]}})
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return true, fmt.Errorf("SSO-Rückruf fehlgeschlagen: %s", resp.Status)
+	}
+	return true, nil
+}
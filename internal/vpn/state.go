@@ -0,0 +1,163 @@
+package vpn
+
+import (
+	"strings"
+	"time"
+)
+
+// State ist der Verbindungszustand des Managers.
+type State string
+
+const (
+	StateDisconnected   State = "disconnected"
+	StateStarting       State = "starting"
+	StateAuthenticating State = "authenticating"
+	StateConnected      State = "connected"
+	StateDisconnecting  State = "disconnecting"
+	StateReconnecting   State = "reconnecting"
+	StateFailed         State = "failed"
+)
+
+// transitions listet die erlaubten Zustandswechsel.
+var transitions = map[State][]State{
+	StateDisconnected:   {StateStarting, StateConnected},
+	StateStarting:       {StateAuthenticating, StateConnected, StateFailed, StateDisconnecting, StateDisconnected},
+	StateAuthenticating: {StateConnected, StateReconnecting, StateFailed, StateDisconnecting, StateDisconnected},
+	StateConnected:      {StateReconnecting, StateDisconnecting, StateDisconnected, StateFailed},
+	StateReconnecting:   {StateAuthenticating, StateConnected, StateFailed, StateDisconnecting, StateDisconnected},
+	StateDisconnecting:  {StateDisconnected, StateConnected, StateFailed},
+	StateFailed:         {StateStarting, StateReconnecting, StateDisconnected, StateConnected, StateDisconnecting},
+}
+
+func canTransition(from, to State) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Status ist der vollständige Zustand, wie ihn /status liefert.
+type Status struct {
+	State       State     `json:"state"`
+	Connected   bool      `json:"connected"`
+	Profile     string    `json:"profile,omitempty"`
+	ProfileName string    `json:"profile_name,omitempty"`
+	Since       time.Time `json:"since"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	LastExit   *ExitInfo         `json:"last_exit,omitempty"`
+	Reconnect  *ReconnectInfo    `json:"reconnect,omitempty"`
+	ServerCert *ServerCertPrompt `json:"server_cert,omitempty"`
+	SSO        *SSOLogin         `json:"sso,omitempty"`
+
+	CertWarnings []CertWarning `json:"cert_warnings,omitempty"` // Client-Zertifikate kurz vor Ablauf
+}
+
+// setState führt einen Zustandswechsel aus. Ungültige Wechsel werden
+// protokolliert und ignoriert. errMsg wird bei StateFailed als letzter
+// Fehler übernommen; ein erfolgreicher Start löscht ihn.
+func (vm *Manager) setState(to State, errMsg string) bool {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.setStateLocked(to, errMsg)
+}
+
+func (vm *Manager) setStateLocked(to State, errMsg string) bool {
+	from := vm.state
+	if from == to {
+		if errMsg != "" && errMsg != vm.lastError {
+			vm.lastError = errMsg
+			vm.events.publish(EventState, vm.statusEventLocked())
+		}
+		return true
+	}
+	if !canTransition(from, to) {
+		vm.logf(LogWarn, "Ignoring state transition %s -> %s", from, to)
+		return false
+	}
+
+	vm.state = to
+	vm.stateSince = time.Now()
+	switch {
+	case errMsg != "":
+		vm.lastError = errMsg
+	case to == StateStarting:
+		vm.lastError = ""
+	}
+
+	if to == StateFailed {
+		vm.logf(LogError, "State: %s -> %s (%s)", from, to, vm.lastError)
+	} else {
+		vm.logf(LogInfo, "State: %s -> %s", from, to)
+	}
+	vm.events.publish(EventState, vm.statusEventLocked())
+	return true
+}
+
+// State liefert den aktuellen Zustand.
+func (vm *Manager) State() State {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.state
+}
+
+// Status liefert den Zustand und gleicht ihn mit dem tatsächlich laufenden
+// Prozess ab (z.B. extern gestartete oder unbemerkt beendete Verbindungen).
+func (vm *Manager) Status() Status {
+	connected := vm.IsConnected()
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	switch {
+	case connected && (vm.state == StateDisconnected || vm.state == StateFailed):
+		vm.setStateLocked(StateConnected, "")
+	case !connected && !vm.supervised && vm.reconnectAttempt == 0 &&
+		(vm.state == StateConnected || vm.state == StateReconnecting):
+		vm.setStateLocked(StateFailed, "Verbindung unerwartet beendet")
+	}
+
+	status := vm.statusEventLocked()
+	status.Connected = connected
+	return status
+}
+
+// stateFromOutput leitet aus einer openconnect-Zeile den nächsten Zustand ab.
+// Neben den allgemeinen Meldungen zählen die des Protokolls.
+// ok ist false, wenn die Zeile keinen Zustandswechsel auslöst.
+func stateFromOutput(proto OpenConnectProtocol, current State, line string) (next State, ok bool) {
+	switch {
+	case strings.Contains(line, "Configured as") ||
+		strings.Contains(line, "VPN tunnel running") ||
+		strings.Contains(line, "Connected tun") ||
+		containsAny(line, proto.connected):
+		return StateConnected, true
+
+	case isFailureLine(line) || containsAny(line, proto.failures):
+		return StateFailed, true
+
+	case current == StateConnected &&
+		(strings.Contains(line, "Dead Peer Detection detected dead peer") ||
+			strings.Contains(strings.ToLower(line), "reconnect")):
+		return StateReconnecting, true
+
+	case (current == StateStarting || current == StateReconnecting) &&
+		(strings.HasPrefix(line, "POST ") ||
+			strings.HasPrefix(line, "Connected to ") ||
+			strings.Contains(line, "SSL negotiation with") ||
+			strings.Contains(line, "Got HTTP response") ||
+			strings.Contains(line, "Please enter your username") ||
+			containsAny(line, proto.authenticating)):
+		return StateAuthenticating, true
+	}
+	return current, false
+}
+
+func isFailureLine(line string) bool {
+	return strings.Contains(line, "Login failed") ||
+		strings.Contains(line, "Failed to decrypt") ||
+		strings.Contains(line, "Authentication failed") ||
+		strings.Contains(line, "Certificate verification failed")
+}
@@ -0,0 +1,135 @@
+package vpn
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"vpn-web/internal/models"
+)
+
+// SudoError unterscheidet fehlgeschlagene Authentifizierung (falsches oder
+// fehlendes Passwort, keine sudoers-Berechtigung) von einem Fehler des
+// eigentlichen Kommandos.
+type SudoError struct {
+	Auth    bool
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e *SudoError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SudoError) Error() string {
+	if e.Auth {
+		return "Sudo-Authentifizierung fehlgeschlagen: " + e.Output
+	}
+	if e.Output != "" {
+		return fmt.Sprintf("%s fehlgeschlagen: %s", e.Command, e.Output)
+	}
+	return fmt.Sprintf("%s fehlgeschlagen: %v", e.Command, e.Err)
+}
+
+// sudoAuthMessages sind Meldungen von sudo selbst (nicht vom Zielkommando).
+var sudoAuthMessages = []string{
+	"Sorry, try again",
+	"incorrect password attempt",
+	"a password is required",
+	"no password was provided",
+	"a terminal is required",
+	"is not in the sudoers file",
+	"is not allowed to execute",
+}
+
+func isSudoAuthFailure(output string) bool {
+	for _, msg := range sudoAuthMessages {
+		if strings.Contains(output, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// sudoPassword liefert "" bei passwordless sudo für program, sonst das
+// gespeicherte bzw. per Kommando abgerufene Sudo-Passwort. Geprüft wird mit
+// "sudo -n -l <program>", da sudoers (siehe install-macos.sh) nur einzelne
+// Programme ohne Passwort erlaubt; "sudo -n true" schlüge dort fehl.
+func (vm *Manager) sudoPassword(program string) (string, error) {
+	check := []string{"-n", "true"}
+	if program != "" {
+		check = []string{"-n", "-l", program}
+	}
+	if exec.Command("sudo", check...).Run() == nil {
+		return "", nil
+	}
+
+	password, err := vm.GetSudoPassword()
+	if err != nil || password == "" {
+		return "", fmt.Errorf("Sudo-Berechtigung erforderlich. Bitte Sudo-Passwort in Einstellungen speichern oder passwordless sudo konfigurieren.")
+	}
+	return password, nil
+}
+
+// sudoPasswordFor liefert das Sudo-Passwort für ein Profil. Mit
+// installiertem Helper braucht openconnect kein sudo.
+func (vm *Manager) sudoPasswordFor(profile models.Profile) (string, error) {
+	backend, err := vm.backendFor(profile)
+	if err != nil {
+		return vm.sudoPassword("")
+	}
+	if backend == Backend(vm.openconnect) && vm.openconnect.helper.Available() {
+		return "", nil
+	}
+	var program string
+	switch b := backend.(type) {
+	case *openconnectBackend:
+		program = b.findOpenConnectPath()
+	case *wireguardBackend:
+		program = b.findWgQuickPath()
+	case *openvpnBackend:
+		program = b.findOpenVPNPath()
+	}
+	return vm.sudoPassword(program)
+}
+
+// sudoArgs baut die sudo-Argumente. Mit Passwort liest sudo es über -S als
+// erste Zeile von stdin (ohne Prompt, -k ignoriert gecachte Credentials,
+// damit die Zeile nie beim Zielkommando landet). Das Passwort erscheint so
+// weder in argv noch auf der Festplatte.
+func sudoArgs(password string, args ...string) []string {
+	if password == "" {
+		return append([]string{"-n", "--"}, args...)
+	}
+	return append([]string{"-S", "-k", "-p", "", "--"}, args...)
+}
+
+// runSudo führt ein Kommando per sudo aus und klassifiziert Fehler.
+func runSudo(password string, args ...string) error {
+	_, err := runSudoOutput(password, args...)
+	return err
+}
+
+// runSudoOutput wie runSudo, liefert zusätzlich stdout und stderr.
+func runSudoOutput(password string, args ...string) (string, error) {
+	cmd := exec.Command("sudo", sudoArgs(password, args...)...)
+	if password != "" {
+		cmd.Stdin = strings.NewReader(password + "\n")
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		out := strings.TrimSpace(output.String())
+		return output.String(), &SudoError{
+			Auth:    isSudoAuthFailure(out),
+			Command: strings.Join(args, " "),
+			Output:  out,
+			Err:     err,
+		}
+	}
+	return output.String(), nil
+}
@@ -0,0 +1,231 @@
+package vpn
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os/exec"
+	"syscall"
+	"time"
+	"vpn-web/internal/models"
+)
+
+// Standardwerte der automatischen Wiederverbindung
+const (
+	defaultReconnectAttempts = 5
+	defaultReconnectDelay    = 5 * time.Second
+	defaultReconnectMaxDelay = 5 * time.Minute
+)
+
+// ExitInfo beschreibt das Ende des zuletzt überwachten VPN-Prozesses.
+type ExitInfo struct {
+	Code          int       `json:"code"` // -1 = durch Signal beendet oder nicht gestartet
+	Reason        string    `json:"reason"`
+	Time          time.Time `json:"time"`
+	UserInitiated bool      `json:"user_initiated"`
+}
+
+// ReconnectInfo beschreibt eine laufende automatische Wiederverbindung.
+type ReconnectInfo struct {
+	Attempt     int        `json:"attempt"`
+	MaxAttempts int        `json:"max_attempts"`
+	NextAttempt *time.Time `json:"next_attempt,omitempty"` // nil = Versuch läuft
+}
+
+// ReconnectPolicy liefert die wirksamen Einstellungen der Wiederverbindung.
+func (vm *Manager) ReconnectPolicy() models.ReconnectPolicy {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.reconnectPolicyLocked()
+}
+
+func (vm *Manager) reconnectPolicyLocked() models.ReconnectPolicy {
+	policy := models.ReconnectPolicy{MaxAttempts: defaultReconnectAttempts}
+	if vm.settings.Reconnect != nil {
+		policy = *vm.settings.Reconnect
+	}
+	if policy.InitialDelay <= 0 {
+		policy.InitialDelay = int(defaultReconnectDelay / time.Second)
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = int(defaultReconnectMaxDelay / time.Second)
+	}
+	return policy
+}
+
+// SetReconnectAttempts setzt die maximale Anzahl automatischer
+// Wiederverbindungsversuche (0 = aus).
+func (vm *Manager) SetReconnectAttempts(attempts int) error {
+	if attempts < 0 {
+		return fmt.Errorf("Anzahl der Versuche darf nicht negativ sein")
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	policy := models.ReconnectPolicy{MaxAttempts: attempts}
+	if vm.settings.Reconnect != nil {
+		policy.InitialDelay = vm.settings.Reconnect.InitialDelay
+		policy.MaxDelay = vm.settings.Reconnect.MaxDelay
+	}
+	vm.settings.Reconnect = &policy
+	return vm.saveSettingsLocked()
+}
+
+// reconnectDelay berechnet die Wartezeit vor Versuch attempt (ab 1):
+// exponentiell wachsend, begrenzt auf MaxDelay und um ±20% gestreut, damit
+// nicht alle Clients nach einem Server-Neustart gleichzeitig anklopfen.
+func reconnectDelay(policy models.ReconnectPolicy, attempt int) time.Duration {
+	delay := time.Duration(policy.InitialDelay) * time.Second
+	maxDelay := time.Duration(policy.MaxDelay) * time.Second
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	jitter := 0.8 + 0.4*rand.Float64()
+	return time.Duration(float64(delay) * jitter)
+}
+
+// reap wartet auf das Ende des Prozesses (name für die Meldung). Erst
+// aufrufen, wenn stdout und stderr vollständig gelesen sind (siehe
+// exec.Cmd.StdoutPipe).
+func reap(cmd *exec.Cmd, name string) ExitInfo {
+	err := cmd.Wait()
+	exit := ExitInfo{Code: -1, Time: time.Now()}
+
+	state := cmd.ProcessState
+	switch {
+	case state == nil:
+		exit.Reason = err.Error()
+	case !state.Exited():
+		exit.Reason = name + " beendet: " + state.String()
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			exit.Reason = name + " durch Signal beendet: " + ws.Signal().String()
+		}
+	default:
+		exit.Code = state.ExitCode()
+		exit.Reason = fmt.Sprintf("%s beendet mit Exit-Code %d", name, exit.Code)
+	}
+	return exit
+}
+
+// processEnded wertet das Ende des überwachten Prozesses aus und plant bei
+// einem unerwarteten Abbruch eine Wiederverbindung. wasConnected gibt an,
+// ob der Tunnel stand; fatal ist gesetzt, wenn ein erneuter Versuch sinnlos
+// ist (z.B. falsches Passwort, gesperrtes Konto).
+func (vm *Manager) processEnded(profileID string, exit ExitInfo, wasConnected bool, fatal string) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	vm.supervised = false
+	exit.UserInitiated = vm.userStopped
+	vm.lastExit = &exit
+	vm.logf(LogInfo, "%s", exit.Reason)
+
+	if vm.userStopped {
+		// Disconnect setzt den Zustand selbst
+		return
+	}
+
+	policy := vm.reconnectPolicyLocked()
+	retry := fatal == "" &&
+		(wasConnected || vm.reconnectAttempt > 0) &&
+		vm.reconnectAttempt < policy.MaxAttempts &&
+		vm.activeProfile == profileID
+
+	if !retry {
+		attempts := vm.reconnectAttempt
+		vm.resetReconnectLocked()
+		switch {
+		case vm.state == StateFailed:
+		case attempts > 0:
+			vm.setStateLocked(StateFailed, fmt.Sprintf("%d Wiederverbindungsversuche erfolglos: %s", attempts, exit.Reason))
+		case vm.state == StateStarting || vm.state == StateAuthenticating:
+			vm.setStateLocked(StateFailed, "Verbindungsaufbau fehlgeschlagen: "+exit.Reason)
+		case vm.state == StateConnected || vm.state == StateReconnecting:
+			vm.setStateLocked(StateFailed, "Verbindung unerwartet beendet: "+exit.Reason)
+		}
+		return
+	}
+
+	vm.reconnectAttempt++
+	delay := reconnectDelay(policy, vm.reconnectAttempt)
+	vm.nextReconnect = time.Now().Add(delay)
+	cancel := make(chan struct{})
+	vm.reconnectCancel = cancel
+
+	vm.setStateLocked(StateReconnecting, fmt.Sprintf("%s, Wiederverbindung %d/%d in %s",
+		exit.Reason, vm.reconnectAttempt, policy.MaxAttempts, delay.Round(time.Second)))
+
+	go vm.reconnectAfter(profileID, delay, cancel)
+}
+
+// reconnectAfter startet nach Ablauf der Wartezeit einen neuen
+// Verbindungsversuch, sofern er nicht zwischenzeitlich abgebrochen wurde.
+func (vm *Manager) reconnectAfter(profileID string, delay time.Duration, cancel <-chan struct{}) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-cancel:
+		return
+	}
+
+	vm.mu.Lock()
+	if vm.userStopped || vm.reconnectCancel != cancel {
+		vm.mu.Unlock()
+		return
+	}
+	vm.reconnectCancel = nil
+	vm.nextReconnect = time.Time{}
+	attempt := vm.reconnectAttempt
+	vm.events.publish(EventState, vm.statusEventLocked())
+	vm.mu.Unlock()
+
+	profile, ok := vm.Profile(profileID)
+	if !ok {
+		vm.processEnded(profileID, ExitInfo{Code: -1, Reason: "Profil nicht gefunden", Time: time.Now()}, false, "Profil nicht gefunden")
+		return
+	}
+
+	vm.logs.startSession(fmt.Sprintf("%s (Wiederverbindung %d)", profile.Name, attempt))
+	vm.progress(fmt.Sprintf("Wiederverbindung, Versuch %d...", attempt))
+
+	session, err := vm.prepareSession(profile)
+	if err != nil {
+		vm.logf(LogError, "Reconnect failed: %v", err)
+		vm.processEnded(profileID, ExitInfo{Code: -1, Reason: "nicht gestartet: " + err.Error(), Time: time.Now()}, false, "")
+		return
+	}
+
+	vm.runSession(session)
+}
+
+// resetReconnectLocked bricht eine geplante Wiederverbindung ab.
+func (vm *Manager) resetReconnectLocked() {
+	if vm.reconnectCancel != nil {
+		close(vm.reconnectCancel)
+		vm.reconnectCancel = nil
+	}
+	vm.reconnectAttempt = 0
+	vm.nextReconnect = time.Time{}
+}
+
+// reconnectInfoLocked liefert die Angaben für Status, nil außerhalb einer
+// Wiederverbindung.
+func (vm *Manager) reconnectInfoLocked() *ReconnectInfo {
+	if vm.reconnectAttempt == 0 {
+		return nil
+	}
+	info := &ReconnectInfo{
+		Attempt:     vm.reconnectAttempt,
+		MaxAttempts: vm.reconnectPolicyLocked().MaxAttempts,
+	}
+	if !vm.nextReconnect.IsZero() {
+		next := vm.nextReconnect
+		info.NextAttempt = &next
+	}
+	return info
+}
@@ -0,0 +1,153 @@
+package vpn
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"vpn-web/internal/keychain"
+)
+
+// totpKey ist ein TOTP-Schlüssel nach RFC 6238.
+type totpKey struct {
+	secret    []byte
+	algorithm string // SHA1, SHA256 oder SHA512
+	digits    int
+	period    int // Sekunden
+}
+
+// parseTOTPSeed akzeptiert eine otpauth://totp/...-URI oder einen
+// Base32-Schlüssel (Leerzeichen und Bindestriche werden ignoriert).
+func parseTOTPSeed(text string) (totpKey, error) {
+	key := totpKey{algorithm: "SHA1", digits: 6, period: 30}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return key, errors.New("Kein TOTP-Schlüssel angegeben")
+	}
+
+	secret := text
+	if strings.HasPrefix(strings.ToLower(text), "otpauth:") {
+		u, err := url.Parse(text)
+		if err != nil {
+			return key, fmt.Errorf("Ungültige otpauth-URI: %v", err)
+		}
+		if u.Host != "totp" {
+			return key, fmt.Errorf("Nur TOTP wird unterstützt, nicht \"%s\"", u.Host)
+		}
+		q := u.Query()
+		secret = q.Get("secret")
+		if a := q.Get("algorithm"); a != "" {
+			key.algorithm = strings.ToUpper(a)
+		}
+		if d := q.Get("digits"); d != "" {
+			if key.digits, err = strconv.Atoi(d); err != nil || key.digits < 6 || key.digits > 8 {
+				return key, fmt.Errorf("Ungültige Stellenzahl \"%s\"", d)
+			}
+		}
+		if p := q.Get("period"); p != "" {
+			if key.period, err = strconv.Atoi(p); err != nil || key.period <= 0 {
+				return key, fmt.Errorf("Ungültige Periode \"%s\"", p)
+			}
+		}
+	}
+
+	if _, err := key.hash(); err != nil {
+		return key, err
+	}
+
+	secret = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(secret))
+	decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(decoded) == 0 {
+		return key, errors.New("TOTP-Schlüssel ist kein gültiges Base32")
+	}
+	key.secret = decoded
+	return key, nil
+}
+
+func (k totpKey) hash() (func() hash.Hash, error) {
+	switch k.algorithm {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("Unbekannter TOTP-Algorithmus \"%s\"", k.algorithm)
+}
+
+// code berechnet den Code für den Zeitpunkt t (RFC 6238 mit RFC 4226).
+func (k totpKey) code(t time.Time) string {
+	newHash, _ := k.hash()
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(t.Unix())/uint64(k.period))
+
+	mac := hmac.New(newHash, k.secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < k.digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.digits, value%mod)
+}
+
+// totpMinValidity ist, wie lange ein Code mindestens noch gültig sein muss;
+// sonst wird auf die nächste Periode gewartet.
+const totpMinValidity = 3
+
+// totpClock liefert die Uhrzeit und wartet; in Tests ersetzbar.
+type totpClock struct {
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+var systemClock = totpClock{now: time.Now, sleep: time.Sleep}
+
+// totpCode liefert den aktuellen Code. Läuft die Periode in Kürze ab, wird
+// auf die nächste gewartet, damit der Code beim Server noch gültig ist.
+func totpCode(seed string, clock totpClock) (string, error) {
+	key, err := parseTOTPSeed(seed)
+	if err != nil {
+		return "", err
+	}
+	now := clock.now()
+	if remaining := key.period - int(now.Unix()%int64(key.period)); remaining < totpMinValidity {
+		clock.sleep(time.Duration(remaining) * time.Second)
+		now = clock.now()
+	}
+	return key.code(now), nil
+}
+
+// ImportTOTPSeed prüft einen TOTP-Schlüssel (otpauth-URI oder Base32) und
+// legt ihn im Passwort-Speicher ab.
+func (vm *Manager) ImportTOTPSeed(profileID, text string) error {
+	if _, ok := vm.Profile(profileID); !ok {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	if _, err := parseTOTPSeed(text); err != nil {
+		return err
+	}
+	return vm.secrets.Store(profileAccount(profileID, secretTOTP), strings.TrimSpace(text))
+}
+
+// RemoveTOTPSeed löscht den TOTP-Schlüssel eines Profils.
+func (vm *Manager) RemoveTOTPSeed(profileID string) error {
+	if err := vm.secrets.Delete(profileAccount(profileID, secretTOTP)); err != nil && !errors.Is(err, keychain.ErrNotFound) {
+		return err
+	}
+	return nil
+}
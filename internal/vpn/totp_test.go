@@ -0,0 +1,85 @@
+package vpn
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Testwerte aus RFC 6238, Anhang B: die Schlüssel sind der ASCII-Text
+// "1234567890" wiederholt auf die Länge des jeweiligen Hashes.
+var rfc6238Secrets = map[string]string{
+	"SHA1":   "12345678901234567890",
+	"SHA256": "12345678901234567890123456789012",
+	"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, tt := range tests {
+		key := totpKey{secret: []byte(rfc6238Secrets[tt.algorithm]), algorithm: tt.algorithm, digits: 8, period: 30}
+		if got := key.code(time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("%s T=%d: code = %s, want %s", tt.algorithm, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestParseTOTPSeedURI(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(rfc6238Secrets["SHA256"]))
+	key, err := parseTOTPSeed("otpauth://totp/VPN:jdoe?secret=" + strings.ToLower(secret) + "&algorithm=sha256&digits=8&period=30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := key.code(time.Unix(59, 0)); got != "46119246" {
+		t.Errorf("code = %s, want 46119246", got)
+	}
+	if key, err = parseTOTPSeed("GEZD GNBV-GY3T QOJQ"); err != nil {
+		t.Fatal(err)
+	}
+	if key.digits != 6 || key.algorithm != "SHA1" || string(key.secret) != "1234567890" {
+		t.Errorf("Standardwerte = %+v", key)
+	}
+}
+
+func TestParseTOTPSeedInvalid(t *testing.T) {
+	tests := []struct {
+		seed string
+		want string
+	}{
+		{"", "Kein TOTP-Schlüssel"},
+		{"otpauth://hotp/VPN?secret=GEZDGNBV", "Nur TOTP"},
+		{"otpauth://totp/VPN?secret=GEZDGNBV&algorithm=MD5", "Unbekannter TOTP-Algorithmus"},
+		{"otpauth://totp/VPN?secret=GEZDGNBV&digits=9", "Ungültige Stellenzahl"},
+		{"otpauth://totp/VPN?secret=GEZDGNBV&period=0", "Ungültige Periode"},
+		{"not base32!", "kein gültiges Base32"},
+	}
+	for _, tt := range tests {
+		if _, err := parseTOTPSeed(tt.seed); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseTOTPSeed(%q) = %v, want %q", tt.seed, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package vpn
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"vpn-web/internal/models"
+)
+
+// wireguardBackend baut Tunnel mit wg-quick auf. Die Konfiguration liegt
+// ohne PrivateKey und PresharedKeys im Profil, die Schlüssel im
+// Passwort-Speicher; wg-quick
+// bekommt eine temporäre Datei, die nach dem Aufruf wieder gelöscht wird.
+type wireguardBackend struct {
+	configDir string // temporäre Konfigurationen (0700)
+	linkDir   string // Linux: Netzwerk-Interfaces
+
+	// Programmpfad; leer = an den üblichen Orten suchen
+	wgQuickPath string
+}
+
+func newWireGuardBackend(configDir string) *wireguardBackend {
+	return &wireguardBackend{configDir: configDir, linkDir: "/sys/class/net"}
+}
+
+func (b *wireguardBackend) Name() string {
+	return BackendWireGuard
+}
+
+// wireguardInterface leitet den Interface-Namen aus der Profil-ID ab
+// (wg-quick: höchstens 15 Zeichen, Dateiname = Interface-Name).
+func wireguardInterface(profile models.Profile) string {
+	id := profile.ID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return "wg-" + id
+}
+
+func (b *wireguardBackend) Validate(s *Session) error {
+	if _, err := parseWireGuardConfig(s.Profile.WireGuardConfig); err != nil {
+		return err
+	}
+
+	key, err := s.Secret(secretWireGuard)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return errors.New("WireGuard-Schlüssel nicht im Passwort-Speicher gefunden. Bitte Konfiguration mit PrivateKey importieren.")
+	}
+	if _, err := b.presharedKeys(s); err != nil {
+		return err
+	}
+
+	if b.findWgQuickPath() == "" {
+		return errors.New("wg-quick nicht gefunden (wireguard-tools installieren)")
+	}
+	return nil
+}
+
+// Connect ruft wg-quick up auf. Der Tunnel läuft danach ohne überwachten
+// Prozess weiter, Status prüft das Interface.
+func (b *wireguardBackend) Connect(s *Session) *ExitInfo {
+	iface := wireguardInterface(s.Profile)
+	key, _ := s.Secret(secretWireGuard)
+	psks, _ := b.presharedKeys(s)
+
+	s.Progress("Starte WireGuard-Interface " + iface + "...")
+
+	if err := b.runWgQuick(s, "up", key, psks); err != nil {
+		s.Fail("wg-quick up fehlgeschlagen: " + err.Error())
+		return &ExitInfo{Code: exitCode(err), Reason: "wg-quick up fehlgeschlagen", Time: time.Now()}
+	}
+
+	if !b.Status(s.Profile) {
+		s.Fail("WireGuard-Interface " + iface + " nicht gefunden")
+		return &ExitInfo{Code: 0, Reason: "wg-quick up ohne Interface beendet", Time: time.Now()}
+	}
+
+	s.Connected()
+	return nil
+}
+
+// Disconnect ruft wg-quick down auf (die Schlüssel werden dafür nicht benötigt).
+func (b *wireguardBackend) Disconnect(s *Session) error {
+	if err := b.runWgQuick(s, "down", "", nil); err != nil {
+		return errors.New("wg-quick down fehlgeschlagen: " + err.Error())
+	}
+	if !waitDisconnected(b, s.Profile, 5*time.Second) {
+		return errors.New("Disconnect unsicher: WireGuard-Interface besteht weiterhin")
+	}
+	return nil
+}
+
+// Status prüft, ob das Interface existiert. Unter macOS legt wg-quick den
+// Namen des utun-Interfaces in /var/run/wireguard/<name>.name ab.
+func (b *wireguardBackend) Status(profile models.Profile) bool {
+	iface := wireguardInterface(profile)
+	switch runtime.GOOS {
+	case "linux":
+		return fileExists(filepath.Join(b.linkDir, iface))
+	case "darwin":
+		data, err := os.ReadFile(filepath.Join("/var/run/wireguard", iface+".name"))
+		if err != nil {
+			return false
+		}
+		utun := strings.TrimSpace(string(data))
+		return utun != "" && exec.Command("ifconfig", utun).Run() == nil
+	}
+	return false
+}
+
+// presharedKeys lädt die PresharedKeys der Peers (PublicKey → Schlüssel)
+// aus dem Passwort-Speicher.
+func (b *wireguardBackend) presharedKeys(s *Session) (map[string]string, error) {
+	stored, err := s.Secret(secretWireGuardPSK)
+	if err != nil || stored == "" {
+		return nil, err
+	}
+	var psks map[string]string
+	if err := json.Unmarshal([]byte(stored), &psks); err != nil {
+		return nil, fmt.Errorf("Fehler beim Laden des %s: %v", secretLabels[secretWireGuardPSK], err)
+	}
+	return psks, nil
+}
+
+// runWgQuick schreibt die Konfiguration (mit privateKey und den
+// PresharedKeys, falls gesetzt) in eine temporäre Datei, ruft wg-quick per
+// sudo auf und protokolliert dessen Ausgabe.
+func (b *wireguardBackend) runWgQuick(s *Session, action, privateKey string, psks map[string]string) error {
+	if err := os.MkdirAll(b.configDir, 0700); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(b.configDir, "run-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	config := withKeys(s.Profile.WireGuardConfig, privateKey, psks)
+	path := filepath.Join(dir, wireguardInterface(s.Profile)+".conf")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		return err
+	}
+
+	output, err := runSudoOutput(s.SudoPassword, b.findWgQuickPath(), action, path)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line != "" {
+			s.Output(LogSourceStderr, line)
+		}
+	}
+	return err
+}
+
+func (b *wireguardBackend) findWgQuickPath() string {
+	if b.wgQuickPath != "" {
+		return b.wgQuickPath
+	}
+	paths := []string{"/usr/local/bin/wg-quick", "/opt/homebrew/bin/wg-quick"}
+	for _, path := range paths {
+		if fileExists(path) {
+			return path
+		}
+	}
+	if path, err := exec.LookPath("wg-quick"); err == nil {
+		return path
+	}
+	return ""
+}
+
+// exitCode liefert den Exit-Code eines fehlgeschlagenen Kommandos oder -1.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// wireguardKeys sind die erlaubten Schlüssel je Abschnitt. wg-quick läuft
+// als root; PreUp/PostUp/PreDown/PostDown würden beliebige Kommandos
+// ausführen und sind deshalb wie alle unbekannten Schlüssel verboten.
+var wireguardKeys = map[string]map[string]bool{
+	"interface": {
+		"privatekey": true, "address": true, "dns": true, "mtu": true,
+		"table": true, "listenport": true, "fwmark": true, "saveconfig": true,
+	},
+	"peer": {
+		"publickey": true, "presharedkey": true, "allowedips": true,
+		"endpoint": true, "persistentkeepalive": true,
+	},
+}
+
+// wireguardConfig ist eine zerlegte wg-quick-Konfiguration.
+type wireguardConfig struct {
+	config        string // ohne PrivateKey und PresharedKeys
+	privateKey    string
+	presharedKeys map[string]string // PublicKey des Peers → PresharedKey
+}
+
+// parseWireGuardConfig prüft eine wg-quick-Konfiguration und trennt
+// PrivateKey und PresharedKeys ab. Verlangt werden [Interface] mit Address und mindestens ein
+// [Peer] mit PublicKey und AllowedIPs; erlaubt sind nur die Schlüssel aus
+// wireguardKeys.
+func parseWireGuardConfig(text string) (wireguardConfig, error) {
+	var result wireguardConfig
+	if strings.TrimSpace(text) == "" {
+		return result, errors.New("Keine WireGuard-Konfiguration hinterlegt")
+	}
+
+	var lines []string
+	section := ""
+	hasInterface, hasAddress := false, false
+	peers, completePeers := 0, 0
+	peerKey, peerIPs := "", false
+	peerPSK := ""
+	endPeer := func() {
+		if section != "peer" {
+			return
+		}
+		if peerKey != "" && peerIPs {
+			completePeers++
+		}
+		if peerKey != "" && peerPSK != "" {
+			if result.presharedKeys == nil {
+				result.presharedKeys = map[string]string{}
+			}
+			result.presharedKeys[peerKey] = peerPSK
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			endPeer()
+			switch strings.ToLower(line) {
+			case "[interface]":
+				section = "interface"
+				hasInterface = true
+			case "[peer]":
+				section = "peer"
+				peers++
+				peerKey, peerIPs, peerPSK = "", false, ""
+			default:
+				return result, fmt.Errorf("Unbekannter Abschnitt %s", line)
+			}
+			lines = append(lines, line)
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		value = strings.TrimSpace(value)
+		switch {
+		case !ok:
+			return result, fmt.Errorf("Ungültige Zeile \"%s\"", line)
+		case section == "":
+			return result, fmt.Errorf("%s außerhalb eines Abschnitts", name)
+		case !wireguardKeys[section][key]:
+			return result, fmt.Errorf("Schlüssel %s ist nicht erlaubt", name)
+		case key == "saveconfig" && !strings.EqualFold(value, "false"):
+			return result, errors.New("SaveConfig ist nur mit false erlaubt")
+		case section == "interface" && key == "privatekey":
+			result.privateKey = value
+			continue // nicht im Profil speichern
+		case section == "interface" && key == "address":
+			hasAddress = true
+		case section == "peer" && key == "publickey":
+			peerKey = value
+		case section == "peer" && key == "presharedkey":
+			peerPSK = value
+			continue // nicht im Profil speichern
+		case section == "peer" && key == "allowedips":
+			peerIPs = true
+		}
+		lines = append(lines, line)
+	}
+	endPeer()
+
+	switch {
+	case !hasInterface || !hasAddress:
+		return result, errors.New("WireGuard-Konfiguration: [Interface] mit Address fehlt")
+	case peers == 0 || completePeers != peers:
+		return result, errors.New("WireGuard-Konfiguration: [Peer] mit PublicKey und AllowedIPs fehlt")
+	}
+
+	result.config = strings.Join(lines, "\n") + "\n"
+	return result, nil
+}
+
+// withKeys fügt den PrivateKey hinter der Zeile [Interface] und jeden
+// PresharedKey hinter den PublicKey seines Peers ein. Schlüssel, die noch in
+// config stehen (nicht migriertes Profil), werden dabei übernommen.
+func withKeys(config, privateKey string, psks map[string]string) string {
+	if parsed, err := parseWireGuardConfig(config); err == nil && (parsed.privateKey != "" || len(parsed.presharedKeys) > 0) {
+		if privateKey == "" {
+			privateKey = parsed.privateKey
+		}
+		merged := maps.Clone(parsed.presharedKeys)
+		if merged == nil {
+			merged = map[string]string{}
+		}
+		maps.Copy(merged, psks)
+		psks = merged
+		config = parsed.config
+	}
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(config, "\n") {
+		b.WriteString(line)
+		trimmed := strings.TrimSpace(line)
+		if privateKey != "" && strings.EqualFold(trimmed, "[interface]") {
+			b.WriteString("PrivateKey = " + privateKey + "\n")
+		}
+		name, value, ok := strings.Cut(trimmed, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "publickey") {
+			if psk := psks[strings.TrimSpace(value)]; psk != "" {
+				b.WriteString("PresharedKey = " + psk + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// ImportWireGuardConfig übernimmt eine wg-quick-Konfiguration in das Profil.
+// Ein enthaltener PrivateKey und PresharedKeys werden in den
+// Passwort-Speicher verschoben; fehlen sie, bleiben die gespeicherten.
+func (vm *Manager) ImportWireGuardConfig(profileID, text string) error {
+	parsed, err := parseWireGuardConfig(text)
+	if err != nil {
+		return err
+	}
+
+	if parsed.privateKey != "" {
+		if err := vm.secrets.Store(profileAccount(profileID, secretWireGuard), parsed.privateKey); err != nil {
+			return err
+		}
+	}
+	if err := vm.storePresharedKeys(profileID, parsed.presharedKeys); err != nil {
+		return err
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	profile := vm.settings.Profile(profileID)
+	if profile == nil {
+		return fmt.Errorf("Profil nicht gefunden")
+	}
+	profile.WireGuardConfig = parsed.config
+	profile.LastModified = time.Now().Format(time.RFC3339)
+	return vm.saveSettingsLocked()
+}
+
+// storePresharedKeys legt die PresharedKeys eines Profils als JSON im
+// Passwort-Speicher ab.
+func (vm *Manager) storePresharedKeys(profileID string, psks map[string]string) error {
+	if len(psks) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(psks)
+	if err != nil {
+		return err
+	}
+	return vm.secrets.Store(profileAccount(profileID, secretWireGuardPSK), string(data))
+}
+
+// migrateWireGuardKeysLocked verschiebt PresharedKeys, die noch im Klartext
+// in der Konfiguration eines Profils stehen, in den Passwort-Speicher. Ist
+// er gesperrt, bleibt die Konfiguration unverändert; das Entsperren
+// versucht es erneut. Liefert true, wenn ein Profil geändert wurde.
+func (vm *Manager) migrateWireGuardKeysLocked() bool {
+	changed := false
+	for i := range vm.settings.Profiles {
+		profile := &vm.settings.Profiles[i]
+		if profile.WireGuardConfig == "" {
+			continue
+		}
+		parsed, err := parseWireGuardConfig(profile.WireGuardConfig)
+		if err != nil || len(parsed.presharedKeys) == 0 {
+			continue
+		}
+		psks := parsed.presharedKeys
+		if stored, err := vm.secrets.Get(profileAccount(profile.ID, secretWireGuardPSK)); err == nil && stored != "" {
+			// bereits gespeicherte Schlüssel anderer Peers behalten
+			var existing map[string]string
+			if json.Unmarshal([]byte(stored), &existing) == nil {
+				for peer, psk := range psks {
+					existing[peer] = psk
+				}
+				psks = existing
+			}
+		}
+		if err := vm.storePresharedKeys(profile.ID, psks); err != nil {
+			vm.logf(LogWarn, "Moving WireGuard preshared keys of profile %s postponed: %v", profile.Name, err)
+			continue
+		}
+		// ein von Hand eingetragener PrivateKey bleibt stehen
+		profile.WireGuardConfig = withKeys(parsed.config, parsed.privateKey, nil)
+		changed = true
+	}
+	return changed
+}
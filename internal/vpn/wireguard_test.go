@@ -0,0 +1,143 @@
+package vpn
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testWGPrivate = "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	testWGPeer1   = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+	testWGPeer2   = "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0="
+	testWGPSK1    = "FpCyhws9cxwWoV4xELtfJvjJN+zQVRPISllRWgeopVE="
+)
+
+const testWireGuardConfig = `[Interface]
+PrivateKey = ` + testWGPrivate + `
+Address = 10.0.0.2/32
+DNS = 10.0.0.1
+
+# Büro
+[Peer]
+PresharedKey = ` + testWGPSK1 + `
+PublicKey = ` + testWGPeer1 + `
+Endpoint = vpn.example.com:51820
+AllowedIPs = 10.0.0.0/8
+
+[Peer]
+PublicKey = ` + testWGPeer2 + `
+AllowedIPs = 192.168.0.0/16
+`
+
+func TestParseWireGuardConfigSplitsKeys(t *testing.T) {
+	parsed, err := parseWireGuardConfig(testWireGuardConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.privateKey != testWGPrivate {
+		t.Errorf("privateKey = %q", parsed.privateKey)
+	}
+	if want := map[string]string{testWGPeer1: testWGPSK1}; !reflect.DeepEqual(parsed.presharedKeys, want) {
+		t.Errorf("presharedKeys = %v, want %v", parsed.presharedKeys, want)
+	}
+	for _, secret := range []string{testWGPrivate, testWGPSK1} {
+		if strings.Contains(parsed.config, secret) {
+			t.Errorf("Schlüssel %s in der Profil-Konfiguration:\n%s", secret, parsed.config)
+		}
+	}
+	if !strings.Contains(parsed.config, "# Büro\n[Peer]\nPublicKey = "+testWGPeer1) {
+		t.Errorf("Konfiguration verändert:\n%s", parsed.config)
+	}
+}
+
+func TestParseWireGuardConfigRejects(t *testing.T) {
+	peer := "\n[Peer]\nPublicKey = " + testWGPeer1 + "\nAllowedIPs = 10.0.0.0/8\n"
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"leer", "  \n", "Keine WireGuard-Konfiguration"},
+		{"PreUp", "[Interface]\nAddress = 10.0.0.2/32\nPreUp = touch /tmp/x\n" + peer, "PreUp ist nicht erlaubt"},
+		{"PostUp", "[Interface]\nAddress = 10.0.0.2/32\nPostUp = iptables -F\n" + peer, "PostUp ist nicht erlaubt"},
+		{"PreDown", "[Interface]\nAddress = 10.0.0.2/32\npredown = rm -rf /\n" + peer, "predown ist nicht erlaubt"},
+		{"PostDown im Peer", "[Interface]\nAddress = 10.0.0.2/32\n" + peer + "PostDown = id\n", "PostDown ist nicht erlaubt"},
+		{"SaveConfig", "[Interface]\nAddress = 10.0.0.2/32\nSaveConfig = true\n" + peer, "SaveConfig ist nur mit false"},
+		{"unbekannter Abschnitt", "[Interface]\nAddress = 10.0.0.2/32\n[Hooks]\n" + peer, "Unbekannter Abschnitt [Hooks]"},
+		{"außerhalb", "Address = 10.0.0.2/32\n[Interface]\n" + peer, "außerhalb eines Abschnitts"},
+		{"ohne Gleichheitszeichen", "[Interface]\nAddress 10.0.0.2/32\n" + peer, "Ungültige Zeile"},
+		{"ohne Address", "[Interface]\nDNS = 10.0.0.1\n" + peer, "[Interface] mit Address fehlt"},
+		{"ohne Peer", "[Interface]\nAddress = 10.0.0.2/32\n", "[Peer] mit PublicKey und AllowedIPs fehlt"},
+		{"Peer ohne AllowedIPs", "[Interface]\nAddress = 10.0.0.2/32\n[Peer]\nPublicKey = " + testWGPeer1 + "\n", "[Peer] mit PublicKey und AllowedIPs fehlt"},
+	}
+	for _, tt := range tests {
+		if _, err := parseWireGuardConfig(tt.config); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestWithKeysRestoresKeys(t *testing.T) {
+	parsed, err := parseWireGuardConfig(testWireGuardConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := withKeys(parsed.config, parsed.privateKey, parsed.presharedKeys)
+	if !strings.Contains(full, "[Interface]\nPrivateKey = "+testWGPrivate+"\n") {
+		t.Errorf("PrivateKey fehlt:\n%s", full)
+	}
+	if !strings.Contains(full, "PublicKey = "+testWGPeer1+"\nPresharedKey = "+testWGPSK1+"\n") {
+		t.Errorf("PresharedKey fehlt:\n%s", full)
+	}
+	if strings.Count(full, "PresharedKey") != 1 {
+		t.Errorf("PresharedKey beim falschen Peer:\n%s", full)
+	}
+
+	// nicht migriertes Profil: Schlüssel in der Konfiguration nicht doppelt
+	again := withKeys(testWireGuardConfig, testWGPrivate, parsed.presharedKeys)
+	if strings.Count(again, "PrivateKey") != 1 || strings.Count(again, "PresharedKey") != 1 {
+		t.Errorf("Schlüssel doppelt:\n%s", again)
+	}
+}
+
+func TestImportWireGuardConfigStoresPresharedKeys(t *testing.T) {
+	vm := newTestManager(t)
+	profile, _ := vm.DefaultProfile()
+	if err := vm.ImportWireGuardConfig(profile.ID, testWireGuardConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := vm.Profile(profile.ID)
+	if strings.Contains(got.WireGuardConfig, testWGPSK1) || strings.Contains(got.WireGuardConfig, testWGPrivate) {
+		t.Errorf("Schlüssel im Profil:\n%s", got.WireGuardConfig)
+	}
+	if key, _ := vm.secrets.Get(profileAccount(profile.ID, secretWireGuard)); key != testWGPrivate {
+		t.Errorf("PrivateKey = %q", key)
+	}
+	stored, _ := vm.secrets.Get(profileAccount(profile.ID, secretWireGuardPSK))
+	var psks map[string]string
+	if err := json.Unmarshal([]byte(stored), &psks); err != nil || psks[testWGPeer1] != testWGPSK1 {
+		t.Errorf("PresharedKeys = %q (%v)", stored, err)
+	}
+}
+
+func TestMigrateWireGuardPresharedKeys(t *testing.T) {
+	vm := newTestManager(t)
+	profile, _ := vm.DefaultProfile()
+	vm.mu.Lock()
+	vm.settings.Profile(profile.ID).WireGuardConfig = strings.Replace(testWireGuardConfig, "PrivateKey = "+testWGPrivate+"\n", "", 1)
+	vm.mu.Unlock()
+
+	if err := vm.migratePendingSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := vm.Profile(profile.ID)
+	if strings.Contains(got.WireGuardConfig, "PresharedKey") {
+		t.Errorf("PresharedKey nicht verschoben:\n%s", got.WireGuardConfig)
+	}
+	if stored, _ := vm.secrets.Get(profileAccount(profile.ID, secretWireGuardPSK)); !strings.Contains(stored, testWGPSK1) {
+		t.Errorf("PresharedKeys = %q", stored)
+	}
+}
@@ -0,0 +1,215 @@
+package vpnc
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultHostsFile erhält die Einträge der Hostnamen aus den Routen.
+const DefaultHostsFile = "/etc/hosts"
+
+// DefaultResolverDir enthält unter macOS je Domain eine Resolver-Datei
+// (man 5 resolver).
+const DefaultResolverDir = "/etc/resolver"
+
+// backupSuffix kennzeichnet eine vorher vorhandene Resolver-Datei, die
+// beim Trennen zurückkommt.
+const backupSuffix = ".vpn-web-backup"
+
+// domainList ist ein wiederholbares Flag (--domain a --domain b).
+type domainList []string
+
+func (l *domainList) String() string { return strings.Join(*l, ",") }
+
+func (l *domainList) Set(v string) error {
+	v = strings.ToLower(strings.Trim(strings.TrimSpace(v), "."))
+	if len(v) > 253 || !hostPattern.MatchString(v) {
+		return fmt.Errorf("Ungültige DNS-Domain \"%s\"", v)
+	}
+	*l = append(*l, v)
+	return nil
+}
+
+func (s *Script) resolverMarker() string {
+	return "# vpn-web " + s.Env.TunDev
+}
+
+// setupDNS lässt die Domains des Profils über die DNS-Server des VPN
+// auflösen: unter Linux per systemd-resolved für das Tunnel-Interface, unter
+// macOS mit einer Datei je Domain in /etc/resolver. Server in unreachable
+// (Route durch den Tunnel fehlgeschlagen, siehe dnsRoutes) bleiben außen vor.
+func (s *Script) setupDNS(unreachable []netip.Addr) error {
+	if len(s.Domains) == 0 {
+		return nil
+	}
+	if len(s.Env.DNS) == 0 {
+		s.logf("Das VPN liefert keine DNS-Server, Split-DNS entfällt")
+		return nil
+	}
+
+	var servers []string
+	for _, addr := range s.Env.DNS {
+		if !slices.Contains(unreachable, addr) {
+			servers = append(servers, addr.String())
+		}
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("Split-DNS entfällt: kein DNS-Server des VPN über den Tunnel erreichbar")
+	}
+
+	if s.GOOS == "linux" {
+		if s.DryRun == nil && !fileExists("/usr/bin/resolvectl") && !fileExists("/bin/resolvectl") {
+			return fmt.Errorf("Split-DNS benötigt systemd-resolved (resolvectl nicht gefunden)")
+		}
+		routing := make([]string, len(s.Domains))
+		for i, domain := range s.Domains {
+			routing[i] = "~" + domain // nur Routing-Domain, keine Suchdomain
+		}
+		if err := s.run(append([]string{"resolvectl", "dns", s.Env.TunDev}, servers...)...); err != nil {
+			return err
+		}
+		if err := s.run(append([]string{"resolvectl", "domain", s.Env.TunDev}, routing...)...); err != nil {
+			return err
+		}
+		return s.run("resolvectl", "default-route", s.Env.TunDev, "false")
+	}
+
+	var content strings.Builder
+	content.WriteString(s.resolverMarker() + "\n")
+	for _, server := range servers {
+		content.WriteString("nameserver " + server + "\n")
+	}
+	if s.DryRun == nil {
+		if err := os.MkdirAll(s.ResolverDir, 0755); err != nil {
+			return err
+		}
+	}
+	for _, domain := range s.Domains {
+		path := filepath.Join(s.ResolverDir, domain)
+		if s.DryRun != nil {
+			fmt.Fprintf(s.DryRun, "resolver %s %s\n", path, strings.Join(servers, " "))
+			continue
+		}
+		if data, err := os.ReadFile(path); err == nil && !strings.HasPrefix(string(data), "# vpn-web ") {
+			if err := os.Rename(path, path+backupSuffix); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(path, []byte(content.String()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeDNS nimmt die Einstellungen von setupDNS zurück. Unter Linux
+// verschwinden sie ohnehin mit dem Interface.
+func (s *Script) removeDNS() error {
+	if s.GOOS == "linux" {
+		if len(s.Domains) > 0 {
+			s.run("resolvectl", "revert", s.Env.TunDev)
+		}
+		return nil
+	}
+	if s.DryRun != nil {
+		for _, domain := range s.Domains {
+			fmt.Fprintf(s.DryRun, "resolver %s entfernen\n", filepath.Join(s.ResolverDir, domain))
+		}
+		return nil
+	}
+	return removeResolverFiles(s.ResolverDir, func(marker string) bool {
+		return marker == s.resolverMarker()
+	})
+}
+
+// removeResolverFiles löscht die von vpn-web angelegten Resolver-Dateien,
+// deren Kennzeichnung match erfüllt, und stellt vorher vorhandene wieder her.
+func removeResolverFiles(dir string, match func(marker string) bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var failed []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, backupSuffix) || !entry.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		marker, _, _ := strings.Cut(string(data), "\n")
+		if !strings.HasPrefix(marker, "# vpn-web ") || !match(marker) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		if fileExists(path + backupSuffix) {
+			if err := os.Rename(path+backupSuffix, path); err != nil {
+				failed = append(failed, err.Error())
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Resolver-Dateien: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Cleanup entfernt Hosts-Einträge und Resolver-Dateien von Tunneln, deren
+// Interface nicht mehr existiert, etwa nach einem Absturz von openconnect,
+// bei dem das vpnc-script nicht mehr zum Trennen aufgerufen wurde.
+func Cleanup(hostsFile, resolverDir string) error {
+	gone := func(dev string) bool {
+		_, err := net.InterfaceByName(dev)
+		return err != nil
+	}
+
+	var failed []string
+	devices := map[string]bool{}
+	if data, err := os.ReadFile(hostsFile); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if dev, ok := strings.CutPrefix(line, "# vpn-web "); ok {
+				dev, _, _ = strings.Cut(dev, " ")
+				devices[dev] = true
+			}
+		}
+	}
+	for dev := range devices {
+		if !devPattern.MatchString(dev) || !gone(dev) {
+			continue
+		}
+		s := &Script{Env: &Env{TunDev: dev}, HostsFile: hostsFile}
+		if err := s.writeHosts(nil); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	err := removeResolverFiles(resolverDir, func(marker string) bool {
+		return gone(strings.TrimPrefix(marker, "# vpn-web "))
+	})
+	if err != nil {
+		failed = append(failed, err.Error())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Aufräumen unvollständig: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
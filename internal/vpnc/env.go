@@ -0,0 +1,140 @@
+package vpnc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var devPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]{0,14}$`)
+
+// Env sind die Angaben, die openconnect dem vpnc-script in der Umgebung
+// übergibt (siehe vpnc-script von vpnc/openconnect).
+type Env struct {
+	Reason     string       // pre-init, connect, disconnect, reconnect, attempt-reconnect
+	TunDev     string       // TUNDEV
+	Gateway    netip.Addr   // VPNGATEWAY
+	IP4Address netip.Addr   // INTERNAL_IP4_ADDRESS
+	IP6Address netip.Prefix // INTERNAL_IP6_NETMASK bzw. INTERNAL_IP6_ADDRESS/128
+	MTU        int          // INTERNAL_IP4_MTU
+	DNS        []netip.Addr // INTERNAL_IP4_DNS, INTERNAL_IP6_DNS
+	Domain     string       // CISCO_DEF_DOMAIN
+
+	// vom Gateway vorgegebene Routen (CISCO_SPLIT_INC_*, CISCO_IPV6_SPLIT_INC_*
+	// bzw. _EXC_*)
+	SplitInclude []netip.Prefix
+	SplitExclude []netip.Prefix
+}
+
+// readEnv liest und prüft die Umgebung. Das Script läuft als root; nichts
+// davon wird ungeprüft an ip/route/ifconfig weitergegeben.
+func readEnv(getenv func(string) string) (*Env, error) {
+	env := &Env{
+		Reason: getenv("reason"),
+		TunDev: getenv("TUNDEV"),
+		Domain: strings.TrimSpace(getenv("CISCO_DEF_DOMAIN")),
+	}
+	if env.Reason == "" {
+		return nil, fmt.Errorf("Variable \"reason\" fehlt (Aufruf nur durch openconnect)")
+	}
+	if env.TunDev != "" && !devPattern.MatchString(env.TunDev) {
+		return nil, fmt.Errorf("Ungültiges Tunnel-Interface \"%s\"", env.TunDev)
+	}
+
+	var err error
+	if v := getenv("VPNGATEWAY"); v != "" {
+		if env.Gateway, err = netip.ParseAddr(v); err != nil {
+			return nil, fmt.Errorf("Ungültiges VPNGATEWAY \"%s\"", v)
+		}
+	}
+	if v := getenv("INTERNAL_IP4_ADDRESS"); v != "" {
+		if env.IP4Address, err = netip.ParseAddr(v); err != nil || !env.IP4Address.Is4() {
+			return nil, fmt.Errorf("Ungültige INTERNAL_IP4_ADDRESS \"%s\"", v)
+		}
+	}
+	if v := getenv("INTERNAL_IP6_NETMASK"); strings.Contains(v, "/") {
+		if env.IP6Address, err = netip.ParsePrefix(v); err != nil || !env.IP6Address.Addr().Is6() {
+			return nil, fmt.Errorf("Ungültige INTERNAL_IP6_NETMASK \"%s\"", v)
+		}
+	} else if v := getenv("INTERNAL_IP6_ADDRESS"); v != "" {
+		addr, err := netip.ParseAddr(v)
+		if err != nil || !addr.Is6() {
+			return nil, fmt.Errorf("Ungültige INTERNAL_IP6_ADDRESS \"%s\"", v)
+		}
+		env.IP6Address = netip.PrefixFrom(addr, 128)
+	}
+	if v := getenv("INTERNAL_IP4_MTU"); v != "" {
+		if env.MTU, err = strconv.Atoi(v); err != nil || env.MTU < 576 || env.MTU > 65535 {
+			return nil, fmt.Errorf("Ungültige INTERNAL_IP4_MTU \"%s\"", v)
+		}
+	}
+	for _, name := range []string{"INTERNAL_IP4_DNS", "INTERNAL_IP6_DNS"} {
+		for _, v := range strings.Fields(getenv(name)) {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("Ungültiger DNS-Server \"%s\"", v)
+			}
+			env.DNS = append(env.DNS, addr)
+		}
+	}
+	if env.Domain != "" && !hostPattern.MatchString(env.Domain) {
+		env.Domain = "" // nur informativ; ungültig = ignorieren
+	}
+
+	for _, split := range []struct {
+		name string
+		list *[]netip.Prefix
+	}{
+		{"CISCO_SPLIT_INC", &env.SplitInclude},
+		{"CISCO_IPV6_SPLIT_INC", &env.SplitInclude},
+		{"CISCO_SPLIT_EXC", &env.SplitExclude},
+		{"CISCO_IPV6_SPLIT_EXC", &env.SplitExclude},
+	} {
+		prefixes, err := splitPrefixes(getenv, split.name)
+		if err != nil {
+			return nil, err
+		}
+		*split.list = append(*split.list, prefixes...)
+	}
+	return env, nil
+}
+
+// splitPrefixes liest eine Liste <name>=Anzahl mit <name>_<i>_ADDR und
+// <name>_<i>_MASKLEN (IPv4 alternativ <name>_<i>_MASK).
+func splitPrefixes(getenv func(string) string, name string) ([]netip.Prefix, error) {
+	count, _ := strconv.Atoi(getenv(name))
+	var prefixes []netip.Prefix
+	for i := 0; i < count && i < 1000; i++ {
+		key := fmt.Sprintf("%s_%d_", name, i)
+		addr, err := netip.ParseAddr(getenv(key + "ADDR"))
+		if err != nil {
+			return nil, fmt.Errorf("Ungültige Angabe %sADDR", key)
+		}
+		length := -1
+		if v := getenv(key + "MASKLEN"); v != "" {
+			length, _ = strconv.Atoi(v)
+		} else if mask, err := netip.ParseAddr(getenv(key + "MASK")); err == nil && mask.Is4() {
+			length = maskBits(mask)
+		}
+		prefix, err := addr.Prefix(length)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("Ungültige Netzmaske für %sADDR", key)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+func maskBits(mask netip.Addr) int {
+	b := mask.As4()
+	m := binary.BigEndian.Uint32(b[:])
+	ones := bits.LeadingZeros32(^m)
+	if ones < 32 && m<<ones != 0 {
+		return -1 // nicht zusammenhängend
+	}
+	return ones
+}
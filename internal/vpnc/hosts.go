@@ -0,0 +1,167 @@
+package vpnc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hostEntry ist eine Zeile im Hosts-Block eines Tunnels.
+type hostEntry struct {
+	addr netip.Addr
+	name string
+}
+
+func (s *Script) hostsMarker(which string) string {
+	return fmt.Sprintf("# vpn-web %s %s", s.Env.TunDev, which)
+}
+
+// resolveHosts löst die Hostnamen über die DNS-Server des VPN auf (ohne
+// solche über den System-Resolver). Nicht auflösbare Namen werden gemeldet
+// und übersprungen.
+func (s *Script) resolveHosts() []hostEntry {
+	if len(s.Targets.Hosts) == 0 {
+		return nil
+	}
+
+	resolver := net.DefaultResolver
+	if len(s.Env.DNS) > 0 {
+		servers := s.Env.DNS
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				var err error
+				for _, server := range servers {
+					var conn net.Conn
+					if conn, err = d.DialContext(ctx, network, netip.AddrPortFrom(server, 53).String()); err == nil {
+						return conn, nil
+					}
+				}
+				return nil, err
+			},
+		}
+	}
+
+	var entries []hostEntry
+	for _, host := range s.Targets.Hosts {
+		if s.DryRun != nil {
+			fmt.Fprintf(s.DryRun, "# resolve %s\n", host)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+		cancel()
+		if err != nil || len(addrs) == 0 {
+			s.logf("%s nicht auflösbar: %v", host, err)
+			continue
+		}
+		for _, addr := range addrs {
+			entries = append(entries, hostEntry{addr: addr.Unmap(), name: host})
+		}
+	}
+	return entries
+}
+
+// readHosts liest den Block des Tunnels aus der Hosts-Datei.
+func (s *Script) readHosts() []hostEntry {
+	data, err := os.ReadFile(s.HostsFile)
+	if err != nil {
+		return nil
+	}
+	var entries []hostEntry
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		switch line {
+		case s.hostsMarker("begin"):
+			inBlock = true
+			continue
+		case s.hostsMarker("end"):
+			inBlock = false
+			continue
+		}
+		if !inBlock {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if addr, err := netip.ParseAddr(fields[0]); err == nil {
+			entries = append(entries, hostEntry{addr: addr, name: fields[1]})
+		}
+	}
+	return entries
+}
+
+// writeHosts ersetzt den Block des Tunnels in der Hosts-Datei (nil =
+// entfernen). Die Datei wird über eine temporäre Datei ersetzt.
+func (s *Script) writeHosts(entries []hostEntry) error {
+	if s.DryRun != nil {
+		for _, e := range entries {
+			fmt.Fprintf(s.DryRun, "hosts %s %s\n", e.addr, e.name)
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(s.HostsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	inBlock, found := false, false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		switch {
+		case line == s.hostsMarker("begin"):
+			inBlock, found = true, true
+		case line == s.hostsMarker("end"):
+			inBlock = false
+		case !inBlock:
+			lines = append(lines, line)
+		}
+	}
+	if !found && len(entries) == 0 {
+		return nil
+	}
+	if len(data) == 0 {
+		lines = nil // keine Leerzeile vor dem Block
+	}
+	if len(entries) > 0 {
+		lines = append(lines, s.hostsMarker("begin"))
+		for _, e := range entries {
+			lines = append(lines, e.addr.String()+"\t"+e.name)
+		}
+		lines = append(lines, s.hostsMarker("end"))
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(s.HostsFile); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.HostsFile), ".hosts-vpn-web-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.HostsFile)
+}
@@ -0,0 +1,564 @@
+// Package vpnc ersetzt vpnc-script und vpn-slice: openconnect ruft
+// "vpn-web vpnc-script <Ziele>" per -s auf, das Programm richtet das
+// Tunnel-Interface ein, legt nur die Routen des Profils an und leitet die
+// DNS-Domains des Profils an die DNS-Server des VPN (Split-DNS).
+package vpnc
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+var hostPattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?\.)*[A-Za-z]([A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// Targets sind die Ziele aus der Kommandozeile, in derselben Schreibweise
+// wie bei vpn-slice: Netze, Adressen und Hostnamen, Ausnahmen mit "%".
+type Targets struct {
+	Include []netip.Prefix
+	Hosts   []string
+	Exclude []netip.Prefix
+}
+
+func parseTargets(args []string) (Targets, error) {
+	var t Targets
+	for _, arg := range args {
+		if ex, ok := strings.CutPrefix(arg, "%"); ok {
+			prefix, ok := parsePrefix(ex)
+			if !ok {
+				return Targets{}, fmt.Errorf("Ungültige Ausnahme \"%s\"", ex)
+			}
+			t.Exclude = append(t.Exclude, prefix)
+			continue
+		}
+		if prefix, ok := parsePrefix(arg); ok {
+			t.Include = append(t.Include, prefix)
+			continue
+		}
+		if len(arg) > 253 || !hostPattern.MatchString(arg) {
+			return Targets{}, fmt.Errorf("Ungültiges Ziel \"%s\"", arg)
+		}
+		t.Hosts = append(t.Hosts, strings.ToLower(arg))
+	}
+	return t, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, bool) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err == nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+// Main ist das vpnc-script (Aufruf durch openconnect:
+// vpn-web vpnc-script [--dry-run Datei] [--domain Domain ...] [Ziel ...]
+// [%Ausnahme ...]).
+// Ohne Ziele gelten die vom Gateway vorgegebenen Routen.
+func Main(args []string) error {
+	flags := flag.NewFlagSet("vpnc-script", flag.ContinueOnError)
+	dryRun := flags.String("dry-run", "", "Befehle nicht ausführen, sondern an diese Datei anhängen (- = Standardausgabe)")
+	hostsFile := flags.String("hosts", DefaultHostsFile, "Hosts-Datei für die Einträge der Hostnamen")
+	resolverDir := flags.String("resolver-dir", DefaultResolverDir, "Verzeichnis der Resolver-Dateien (macOS)")
+	var domains domainList
+	flags.Var(&domains, "domain", "über die DNS-Server des VPN aufzulösende Domain (mehrfach möglich)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	env, err := readEnv(os.Getenv)
+	if err != nil {
+		return err
+	}
+	targets, err := parseTargets(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	s := &Script{
+		Env:         env,
+		Targets:     targets,
+		Domains:     domains,
+		GOOS:        runtime.GOOS,
+		HostsFile:   *hostsFile,
+		ResolverDir: *resolverDir,
+		Log:         os.Stderr,
+	}
+	switch *dryRun {
+	case "":
+	case "-":
+		s.DryRun = os.Stdout
+	default:
+		f, err := os.OpenFile(*dryRun, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		s.DryRun = f
+	}
+	return s.Run()
+}
+
+// Script führt einen Aufruf durch openconnect aus.
+type Script struct {
+	Env         *Env
+	Targets     Targets
+	Domains     []string // Split-DNS
+	GOOS        string   // "linux" oder "darwin"
+	HostsFile   string
+	ResolverDir string
+	Log         io.Writer
+
+	// DryRun erhält die Befehle (je Zeile einer), statt sie auszuführen;
+	// nil = ausführen
+	DryRun io.Writer
+}
+
+// Run bearbeitet den Anlass aus der Umgebung (reason).
+func (s *Script) Run() error {
+	if s.GOOS != "linux" && s.GOOS != "darwin" {
+		return fmt.Errorf("vpnc-script wird auf %s nicht unterstützt", s.GOOS)
+	}
+	switch s.Env.Reason {
+	case "pre-init", "reconnect", "attempt-reconnect":
+		return nil
+	case "connect":
+		if s.Env.TunDev == "" {
+			return fmt.Errorf("Variable TUNDEV fehlt")
+		}
+		return s.connect()
+	case "disconnect":
+		if s.Env.TunDev == "" {
+			return fmt.Errorf("Variable TUNDEV fehlt")
+		}
+		return s.disconnect()
+	}
+	s.logf("Anlass \"%s\" wird ignoriert", s.Env.Reason)
+	return nil
+}
+
+func (s *Script) logf(format string, args ...any) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "vpnc-script: "+format+"\n", args...)
+	}
+}
+
+// run führt einen Befehl aus (im Probelauf: schreibt ihn nur auf).
+func (s *Script) run(args ...string) error {
+	if s.DryRun != nil {
+		fmt.Fprintln(s.DryRun, strings.Join(args, " "))
+		return nil
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = []string{"PATH=/usr/sbin:/usr/bin:/sbin:/bin"}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// query führt einen nur lesenden Befehl aus. Im Probelauf wird er als
+// Kommentar aufgeschrieben und liefert keine Ausgabe.
+func (s *Script) query(args ...string) string {
+	if s.DryRun != nil {
+		fmt.Fprintln(s.DryRun, "# "+strings.Join(args, " "))
+		return ""
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = []string{"PATH=/usr/sbin:/usr/bin:/sbin:/bin"}
+	out, _ := cmd.Output()
+	return string(out)
+}
+
+// routes liefert die Netze durch den Tunnel und die am Tunnel vorbei.
+// Liegt das Gateway selbst in einem Netz des Tunnels, wird es zur Ausnahme.
+// Eine Standardroute wird als zwei halbe Netze angelegt, damit die
+// bestehende Standardroute unangetastet bleibt.
+func (s *Script) routes(hostAddrs []netip.Addr) (include, exclude []netip.Prefix) {
+	include = append(include, s.Targets.Include...)
+	if len(include) == 0 && len(s.Targets.Hosts) == 0 {
+		include = s.Env.SplitInclude
+	}
+	for _, addr := range hostAddrs {
+		include = append(include, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	exclude = append(append(exclude, s.Targets.Exclude...), s.Env.SplitExclude...)
+
+	var split []netip.Prefix
+	for _, prefix := range include {
+		if prefix.Bits() != 0 {
+			split = append(split, prefix)
+			continue
+		}
+		if prefix.Addr().Is4() {
+			split = append(split, netip.MustParsePrefix("0.0.0.0/1"), netip.MustParsePrefix("128.0.0.0/1"))
+		} else {
+			split = append(split, netip.MustParsePrefix("::/1"), netip.MustParsePrefix("8000::/1"))
+		}
+	}
+	include = split
+
+	if gw := s.Env.Gateway; gw.IsValid() {
+		for _, prefix := range include {
+			if prefix.Contains(gw) && prefix.Bits() < gw.BitLen() {
+				exclude = append(exclude, netip.PrefixFrom(gw, gw.BitLen()))
+				break
+			}
+		}
+	}
+	return include, exclude
+}
+
+// dnsRoutes liefert Host-Routen zu den DNS-Servern des VPN. Sie führen immer
+// durch den Tunnel, auch wenn kein angegebenes Netz sie enthält; sonst
+// liefe die Auflösung der Hostnamen ins Leere (wie bei vpn-slice).
+func (s *Script) dnsRoutes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, addr := range s.Env.DNS {
+		if addr == s.Env.Gateway {
+			continue // das Gateway selbst nie in den Tunnel
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes
+}
+
+func (s *Script) connect() error {
+	if s.DryRun == nil {
+		// Reste eines abgestürzten Tunnels
+		if err := Cleanup(s.HostsFile, s.ResolverDir); err != nil {
+			s.logf("%v", err)
+		}
+	}
+	if err := s.setupInterface(); err != nil {
+		return err
+	}
+
+	// DNS-Server zuerst, die Hostnamen und das Split-DNS verwenden sie
+	var failed []string
+	var unreachableDNS []netip.Addr
+	dns := s.dnsRoutes()
+	for _, prefix := range dns {
+		if err := s.run(s.addTunnelRoute(prefix)...); err != nil {
+			failed = append(failed, err.Error())
+			unreachableDNS = append(unreachableDNS, prefix.Addr())
+		}
+	}
+
+	hosts := s.resolveHosts()
+	var hostAddrs []netip.Addr
+	for _, entry := range hosts {
+		hostAddrs = append(hostAddrs, entry.addr)
+	}
+	include, exclude := s.routes(hostAddrs)
+	if len(include) == 0 {
+		s.logf("Keine Routen angegeben, der Tunnel bleibt ungenutzt")
+	}
+
+	// Ausnahmen über das bisherige Gateway, ermittelt bevor Routen in den
+	// Tunnel zeigen
+	gateways := map[bool][2]string{}
+	for _, prefix := range exclude {
+		ipv6 := prefix.Addr().Is6()
+		if _, ok := gateways[ipv6]; !ok {
+			gw, dev := s.defaultGateway(ipv6)
+			gateways[ipv6] = [2]string{gw, dev}
+		}
+		gw, dev := gateways[ipv6][0], gateways[ipv6][1]
+		if gw == "" && dev == "" && s.DryRun == nil {
+			failed = append(failed, prefix.String()+": kein bisheriges Gateway")
+			continue
+		}
+		if s.GOOS == "darwin" && s.DryRun == nil && s.ownsDarwinRoute(prefix, false) {
+			// Rest eines abgestürzten Tunnels, sonst schlägt "route add" fehl
+			s.run(darwinRoute("delete", prefix)...)
+		}
+		if err := s.run(s.addExcludeRoute(prefix, gw, dev)...); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	for _, prefix := range include {
+		if slices.Contains(dns, prefix) {
+			continue
+		}
+		if err := s.run(s.addTunnelRoute(prefix)...); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if err := s.writeHosts(hosts); err != nil {
+		failed = append(failed, err.Error())
+	}
+	if err := s.setupDNS(unreachableDNS); err != nil {
+		failed = append(failed, err.Error())
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Routen/DNS unvollständig: %s", strings.Join(failed, "; "))
+	}
+	s.logf("Routen über %s: %d, DNS-Server: %d, Ausnahmen: %d", s.Env.TunDev, len(include), len(dns), len(exclude))
+	return nil
+}
+
+// disconnect entfernt die angelegten Routen, Hosts-Einträge und
+// Split-DNS-Einstellungen. Gelöscht werden nur Routen, die vpn-web angelegt
+// hat (siehe deleteTunnelRoute, deleteExcludeRoute); Fehler dabei sind ohne
+// Bedeutung, Routen in den Tunnel verschwinden ohnehin mit dem Interface.
+func (s *Script) disconnect() error {
+	hosts := s.readHosts()
+	var hostAddrs []netip.Addr
+	for _, entry := range hosts {
+		hostAddrs = append(hostAddrs, entry.addr)
+	}
+	include, exclude := s.routes(hostAddrs)
+	for _, prefix := range s.dnsRoutes() {
+		if !slices.Contains(include, prefix) {
+			include = append(include, prefix)
+		}
+	}
+	for _, prefix := range include {
+		s.deleteTunnelRoute(prefix)
+	}
+	for _, prefix := range exclude {
+		s.deleteExcludeRoute(prefix)
+	}
+	dnsErr := s.removeDNS()
+	if err := s.writeHosts(nil); err != nil {
+		return err
+	}
+	return dnsErr
+}
+
+func (s *Script) setupInterface() error {
+	env := s.Env
+	if s.GOOS == "linux" {
+		args := []string{"ip", "link", "set", "dev", env.TunDev, "up"}
+		if env.MTU > 0 {
+			args = append(args, "mtu", fmt.Sprint(env.MTU))
+		}
+		if err := s.run(args...); err != nil {
+			return err
+		}
+		if env.IP4Address.IsValid() {
+			addr := env.IP4Address.String()
+			if err := s.run("ip", "addr", "add", addr+"/32", "peer", addr, "dev", env.TunDev); err != nil {
+				return err
+			}
+		}
+		if env.IP6Address.IsValid() {
+			if err := s.run("ip", "-6", "addr", "add", env.IP6Address.String(), "dev", env.TunDev); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if env.IP4Address.IsValid() {
+		addr := env.IP4Address.String()
+		args := []string{"ifconfig", env.TunDev, "inet", addr, addr, "netmask", "255.255.255.255"}
+		if env.MTU > 0 {
+			args = append(args, "mtu", fmt.Sprint(env.MTU))
+		}
+		if err := s.run(append(args, "up")...); err != nil {
+			return err
+		}
+	}
+	if env.IP6Address.IsValid() {
+		if err := s.run("ifconfig", env.TunDev, "inet6", env.IP6Address.Addr().String(), "prefixlen", fmt.Sprint(env.IP6Address.Bits())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultGateway liefert Gateway und Interface der bestehenden
+// Standardroute (IPv4 bzw. IPv6).
+func (s *Script) defaultGateway(ipv6 bool) (gw, dev string) {
+	if s.GOOS == "linux" {
+		args := []string{"ip", "route", "show", "default"}
+		if ipv6 {
+			args = []string{"ip", "-6", "route", "show", "default"}
+		}
+		fields := strings.Fields(firstLine(s.query(args...)))
+		for i := 0; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "via":
+				gw = fields[i+1]
+			case "dev":
+				dev = fields[i+1]
+			}
+		}
+	} else {
+		args := []string{"route", "-n", "get", "default"}
+		if ipv6 {
+			args = []string{"route", "-n", "get", "-inet6", "default"}
+		}
+		for _, line := range strings.Split(s.query(args...), "\n") {
+			key, value, _ := strings.Cut(strings.TrimSpace(line), ":")
+			switch key {
+			case "gateway":
+				gw = strings.TrimSpace(value)
+			case "interface":
+				dev = strings.TrimSpace(value)
+			}
+		}
+	}
+	if _, err := netip.ParseAddr(gw); err != nil {
+		gw = ""
+	}
+	if !devPattern.MatchString(dev) {
+		dev = ""
+	}
+	if s.DryRun != nil && gw == "" && dev == "" {
+		return "GATEWAY", "DEV" // Platzhalter im Probelauf
+	}
+	return gw, dev
+}
+
+// Kennzeichnung der Routen von vpn-web, damit beim Trennen keine fremden
+// Routen gelöscht werden: unter Linux ein eigenes Routing-Protokoll (siehe
+// /etc/iproute2/rt_protos) und für Ausnahmen eine eigene Metrik, damit
+// "ip route replace" keine gleichlautende Route des LANs ersetzt; unter
+// macOS das Flag RTF_PROTO1.
+const (
+	linuxRouteProto    = "117"
+	linuxExcludeMetric = "4711"
+	darwinRouteFlag    = "PROTO1"
+)
+
+func (s *Script) addTunnelRoute(prefix netip.Prefix) []string {
+	if s.GOOS == "linux" {
+		return []string{"ip", "route", "replace", prefix.String(), "dev", s.Env.TunDev, "proto", linuxRouteProto}
+	}
+	return append(darwinRoute("add", prefix), "-interface", s.Env.TunDev, "-proto1")
+}
+
+func (s *Script) addExcludeRoute(prefix netip.Prefix, gw, dev string) []string {
+	if s.GOOS == "linux" {
+		args := []string{"ip", "route", "replace", prefix.String()}
+		if gw != "" {
+			args = append(args, "via", gw)
+		}
+		if dev != "" {
+			args = append(args, "dev", dev)
+		}
+		return append(args, "proto", linuxRouteProto, "metric", linuxExcludeMetric)
+	}
+	if gw == "" {
+		return append(darwinRoute("add", prefix), "-interface", dev, "-proto1")
+	}
+	return append(darwinRoute("add", prefix), gw, "-proto1")
+}
+
+// deleteTunnelRoute löscht eine Route, sofern sie in den Tunnel zeigt.
+func (s *Script) deleteTunnelRoute(prefix netip.Prefix) {
+	if s.GOOS == "linux" {
+		s.run("ip", "route", "del", prefix.String(), "dev", s.Env.TunDev, "proto", linuxRouteProto)
+		return
+	}
+	if s.ownsDarwinRoute(prefix, true) {
+		s.run(darwinRoute("delete", prefix)...)
+	}
+}
+
+// deleteExcludeRoute löscht eine Ausnahme, sofern vpn-web sie angelegt hat.
+func (s *Script) deleteExcludeRoute(prefix netip.Prefix) {
+	if s.GOOS == "linux" {
+		s.run("ip", "route", "del", prefix.String(), "proto", linuxRouteProto, "metric", linuxExcludeMetric)
+		return
+	}
+	if s.ownsDarwinRoute(prefix, false) {
+		s.run(darwinRoute("delete", prefix)...)
+	}
+}
+
+// ownsDarwinRoute prüft unter macOS per "route get", ob die Route für genau
+// dieses Ziel von vpn-web stammt: in den Tunnel (tunnel) bzw. mit dem Flag
+// PROTO1. Im Probelauf gilt das als gegeben.
+func (s *Script) ownsDarwinRoute(prefix netip.Prefix, tunnel bool) bool {
+	out := s.query(darwinRoute("get", prefix)...)
+	if s.DryRun != nil {
+		return true
+	}
+	dev := ""
+	if tunnel {
+		dev = s.Env.TunDev
+	}
+	return darwinRouteOwned(out, prefix, dev)
+}
+
+// darwinRouteOwned wertet die Ausgabe von "route -n get" aus. Mit dev muss
+// die Route über dieses Interface gehen, sonst das Flag PROTO1 tragen.
+func darwinRouteOwned(out string, prefix netip.Prefix, dev string) bool {
+	var destination, iface, flags string
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch key {
+		case "destination":
+			destination = strings.TrimSpace(value)
+		case "interface":
+			iface = strings.TrimSpace(value)
+		case "flags":
+			flags = strings.TrimSpace(value)
+		}
+	}
+	if addr, err := netip.ParseAddr(destination); err != nil || addr.Unmap() != prefix.Addr() {
+		return false // nur eine allgemeinere Route passt
+	}
+	if dev != "" {
+		return iface == dev
+	}
+	return strings.Contains(flags, darwinRouteFlag)
+}
+
+// RemoveExcludes entfernt nach einem Absturz von openconnect die Ausnahmen
+// (Routen am Tunnel vorbei); Routen in den Tunnel verschwinden mit dessen
+// Interface. Unter Linux werden alle Routen mit dem Protokoll von vpn-web
+// gelöscht, unter macOS die angegebenen, sofern sie von vpn-web stammen.
+func RemoveExcludes(exclude []netip.Prefix) error {
+	s := &Script{Env: &Env{}, GOOS: runtime.GOOS}
+	switch s.GOOS {
+	case "linux":
+		var failed []string
+		for _, family := range []string{"-4", "-6"} {
+			if err := s.run("ip", family, "route", "flush", "proto", linuxRouteProto); err != nil {
+				failed = append(failed, err.Error())
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("Routen: %s", strings.Join(failed, "; "))
+		}
+	case "darwin":
+		for _, prefix := range exclude {
+			s.deleteExcludeRoute(prefix)
+		}
+	}
+	return nil
+}
+
+func darwinRoute(action string, prefix netip.Prefix) []string {
+	args := []string{"route", "-n", action}
+	if prefix.Addr().Is6() {
+		args = append(args, "-inet6")
+	}
+	if prefix.IsSingleIP() {
+		return append(args, "-host", prefix.Addr().String())
+	}
+	return append(args, "-net", prefix.String())
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
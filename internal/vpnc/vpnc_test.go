@@ -0,0 +1,319 @@
+package vpnc
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testEnv ist die Umgebung, mit der openconnect das vpnc-script aufruft.
+func testEnv(reason string) map[string]string {
+	return map[string]string{
+		"reason":               reason,
+		"TUNDEV":               "tun0",
+		"VPNGATEWAY":           "203.0.113.10",
+		"INTERNAL_IP4_ADDRESS": "10.8.0.5",
+		"INTERNAL_IP4_MTU":     "1400",
+		"INTERNAL_IP4_DNS":     "10.8.0.1",
+	}
+}
+
+// dryRun führt das Script im Probelauf aus und liefert die Befehle.
+func dryRun(t *testing.T, goos string, env map[string]string, args ...string) string {
+	t.Helper()
+	e, err := readEnv(func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := parseTargets(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	s := &Script{
+		Env:         e,
+		Targets:     targets,
+		Domains:     []string{"corp.example.com"},
+		GOOS:        goos,
+		HostsFile:   filepath.Join(t.TempDir(), "hosts"),
+		ResolverDir: "/etc/resolver",
+		DryRun:      &out,
+	}
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func checkCommands(t *testing.T, got, want string) {
+	t.Helper()
+	want = strings.TrimLeft(want, "\n")
+	if got != want {
+		t.Errorf("Befehle:\n%s\nerwartet:\n%s", got, want)
+	}
+}
+
+func TestDryRunLinuxConnect(t *testing.T) {
+	got := dryRun(t, "linux", testEnv("connect"), "10.0.0.0/8", "192.168.50.7", "%10.1.2.0/24", "%192.168.1.0/24")
+	checkCommands(t, got, `
+ip link set dev tun0 up mtu 1400
+ip addr add 10.8.0.5/32 peer 10.8.0.5 dev tun0
+ip route replace 10.8.0.1/32 dev tun0 proto 117
+# ip route show default
+ip route replace 10.1.2.0/24 via GATEWAY dev DEV proto 117 metric 4711
+ip route replace 192.168.1.0/24 via GATEWAY dev DEV proto 117 metric 4711
+ip route replace 10.0.0.0/8 dev tun0 proto 117
+ip route replace 192.168.50.7/32 dev tun0 proto 117
+resolvectl dns tun0 10.8.0.1
+resolvectl domain tun0 ~corp.example.com
+resolvectl default-route tun0 false
+`)
+}
+
+func TestDryRunLinuxDisconnect(t *testing.T) {
+	got := dryRun(t, "linux", testEnv("disconnect"), "10.0.0.0/8", "%10.1.2.0/24")
+	// nur Routen über das Tunnel-Interface bzw. mit Protokoll und Metrik von
+	// vpn-web, nie eine gleichlautende Route des LANs
+	checkCommands(t, got, `
+ip route del 10.0.0.0/8 dev tun0 proto 117
+ip route del 10.8.0.1/32 dev tun0 proto 117
+ip route del 10.1.2.0/24 proto 117 metric 4711
+resolvectl revert tun0
+`)
+}
+
+func TestDryRunLinuxDefaultRoute(t *testing.T) {
+	// Standardroute als zwei halbe Netze, das Gateway selbst am Tunnel vorbei
+	got := dryRun(t, "linux", testEnv("connect"), "0.0.0.0/0")
+	checkCommands(t, got, `
+ip link set dev tun0 up mtu 1400
+ip addr add 10.8.0.5/32 peer 10.8.0.5 dev tun0
+ip route replace 10.8.0.1/32 dev tun0 proto 117
+# ip route show default
+ip route replace 203.0.113.10/32 via GATEWAY dev DEV proto 117 metric 4711
+ip route replace 0.0.0.0/1 dev tun0 proto 117
+ip route replace 128.0.0.0/1 dev tun0 proto 117
+resolvectl dns tun0 10.8.0.1
+resolvectl domain tun0 ~corp.example.com
+resolvectl default-route tun0 false
+`)
+}
+
+func TestDryRunLinuxGatewayRoutes(t *testing.T) {
+	// ohne Ziele gelten die Routen des Gateways (CISCO_SPLIT_*)
+	env := testEnv("connect")
+	env["CISCO_SPLIT_INC"] = "1"
+	env["CISCO_SPLIT_INC_0_ADDR"] = "172.16.0.0"
+	env["CISCO_SPLIT_INC_0_MASK"] = "255.240.0.0"
+	env["CISCO_SPLIT_EXC"] = "1"
+	env["CISCO_SPLIT_EXC_0_ADDR"] = "172.16.5.0"
+	env["CISCO_SPLIT_EXC_0_MASKLEN"] = "24"
+	got := dryRun(t, "linux", env)
+	for _, want := range []string{
+		"ip route replace 172.16.5.0/24 via GATEWAY dev DEV proto 117 metric 4711\n",
+		"ip route replace 172.16.0.0/12 dev tun0 proto 117\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q fehlt in:\n%s", want, got)
+		}
+	}
+}
+
+func TestDryRunDarwinConnect(t *testing.T) {
+	got := dryRun(t, "darwin", testEnv("connect"), "10.0.0.0/8", "192.168.50.7", "%10.1.2.0/24")
+	checkCommands(t, got, `
+ifconfig tun0 inet 10.8.0.5 10.8.0.5 netmask 255.255.255.255 mtu 1400 up
+route -n add -host 10.8.0.1 -interface tun0 -proto1
+# route -n get default
+route -n add -net 10.1.2.0/24 GATEWAY -proto1
+route -n add -net 10.0.0.0/8 -interface tun0 -proto1
+route -n add -host 192.168.50.7 -interface tun0 -proto1
+resolver /etc/resolver/corp.example.com 10.8.0.1
+`)
+}
+
+func TestDryRunDarwinDisconnect(t *testing.T) {
+	got := dryRun(t, "darwin", testEnv("disconnect"), "10.0.0.0/8", "%10.1.2.0/24")
+	// gelöscht wird erst nach der Prüfung per "route get" (darwinRouteOwned)
+	checkCommands(t, got, `
+# route -n get -net 10.0.0.0/8
+route -n delete -net 10.0.0.0/8
+# route -n get -host 10.8.0.1
+route -n delete -host 10.8.0.1
+# route -n get -net 10.1.2.0/24
+route -n delete -net 10.1.2.0/24
+resolver /etc/resolver/corp.example.com entfernen
+`)
+}
+
+func TestDryRunRoutesDNSBeforeResolving(t *testing.T) {
+	// die DNS-Server liegen in keinem angegebenen Netz und müssen vor der
+	// Auflösung der Hostnamen über den Tunnel erreichbar sein
+	env := testEnv("connect")
+	env["INTERNAL_IP4_DNS"] = "172.20.0.53 172.20.1.53"
+	env["INTERNAL_IP6_DNS"] = "fd00::53"
+	got := dryRun(t, "linux", env, "192.168.50.0/24", "intranet.example.com")
+	checkCommands(t, got, `
+ip link set dev tun0 up mtu 1400
+ip addr add 10.8.0.5/32 peer 10.8.0.5 dev tun0
+ip route replace 172.20.0.53/32 dev tun0 proto 117
+ip route replace 172.20.1.53/32 dev tun0 proto 117
+ip route replace fd00::53/128 dev tun0 proto 117
+# resolve intranet.example.com
+ip route replace 192.168.50.0/24 dev tun0 proto 117
+resolvectl dns tun0 172.20.0.53 172.20.1.53 fd00::53
+resolvectl domain tun0 ~corp.example.com
+resolvectl default-route tun0 false
+`)
+
+	got = dryRun(t, "linux", testEnv("disconnect"), "192.168.50.0/24")
+	if !strings.Contains(got, "ip route del 10.8.0.1/32 dev tun0 proto 117\n") {
+		t.Errorf("Route zum DNS-Server wird nicht entfernt:\n%s", got)
+	}
+}
+
+func TestDryRunSplitDNSUsesRoutedServers(t *testing.T) {
+	// Split-DNS ohne Hostnamen: der DNS-Server liegt außerhalb der Netze
+	env := testEnv("connect")
+	env["INTERNAL_IP4_DNS"] = "172.20.0.53"
+	for goos, want := range map[string][2]string{
+		"linux":  {"ip route replace 172.20.0.53/32 dev tun0 proto 117", "resolvectl dns tun0 172.20.0.53"},
+		"darwin": {"route -n add -host 172.20.0.53 -interface tun0 -proto1", "resolver /etc/resolver/corp.example.com 172.20.0.53"},
+	} {
+		got := dryRun(t, goos, env, "192.168.50.0/24")
+		route, resolver := strings.Index(got, want[0]+"\n"), strings.Index(got, want[1]+"\n")
+		if route < 0 || resolver < 0 || route > resolver {
+			t.Errorf("%s: Route zum DNS-Server fehlt oder folgt dem Resolver:\n%s", goos, got)
+		}
+	}
+}
+
+func TestSetupDNSSkipsUnreachableServers(t *testing.T) {
+	var out bytes.Buffer
+	s := &Script{
+		Env:     &Env{TunDev: "tun0", DNS: []netip.Addr{netip.MustParseAddr("172.20.0.53"), netip.MustParseAddr("172.20.1.53")}},
+		Domains: []string{"corp.example.com"},
+		GOOS:    "linux",
+		DryRun:  &out,
+	}
+	if err := s.setupDNS([]netip.Addr{netip.MustParseAddr("172.20.0.53")}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "resolvectl dns tun0 172.20.1.53\n") {
+		t.Errorf("Befehle:\n%s", out.String())
+	}
+	if err := s.setupDNS(s.Env.DNS); err == nil {
+		t.Error("Split-DNS ohne erreichbaren DNS-Server eingerichtet")
+	}
+}
+
+func TestDryRunIgnoresOtherReasons(t *testing.T) {
+	for _, reason := range []string{"pre-init", "reconnect", "attempt-reconnect"} {
+		if got := dryRun(t, "linux", testEnv(reason), "10.0.0.0/8"); got != "" {
+			t.Errorf("%s: %q", reason, got)
+		}
+	}
+}
+
+func TestDarwinRouteOwned(t *testing.T) {
+	const excludeRoute = `   route to: 10.1.2.0
+destination: 10.1.2.0
+       mask: 255.255.255.0
+    gateway: 192.168.1.1
+  interface: en0
+      flags: <UP,GATEWAY,DONE,STATIC,PRCLONING,PROTO1>
+`
+	const lanRoute = `   route to: 192.168.1.0
+destination: 192.168.1.0
+       mask: 255.255.255.0
+  interface: en0
+      flags: <UP,DONE,CLONING,STATIC>
+`
+	const tunnelRoute = `   route to: 10.0.0.0
+destination: 10.0.0.0
+       mask: 255.0.0.0
+  interface: utun4
+      flags: <UP,DONE,STATIC,PRCLONING,PROTO1>
+`
+	const defaultRoute = `   route to: 10.1.2.0
+destination: default
+       mask: default
+    gateway: 192.168.1.1
+  interface: en0
+      flags: <UP,GATEWAY,DONE,STATIC,PRCLONING>
+`
+	tests := []struct {
+		name   string
+		out    string
+		prefix string
+		dev    string
+		want   bool
+	}{
+		{"eigene Ausnahme", excludeRoute, "10.1.2.0/24", "", true},
+		{"Route des LANs", lanRoute, "192.168.1.0/24", "", false},
+		{"nur Standardroute", defaultRoute, "10.1.2.0/24", "", false},
+		{"Tunnel", tunnelRoute, "10.0.0.0/8", "utun4", true},
+		{"anderer Tunnel", tunnelRoute, "10.0.0.0/8", "utun5", false},
+		{"keine Ausgabe", "", "10.0.0.0/8", "", false},
+	}
+	for _, tt := range tests {
+		if got := darwinRouteOwned(tt.out, netip.MustParsePrefix(tt.prefix), tt.dev); got != tt.want {
+			t.Errorf("%s: %v, erwartet %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHostsBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := &Script{Env: &Env{TunDev: "tun0"}, HostsFile: path}
+	entries := []hostEntry{{addr: netip.MustParseAddr("10.0.0.7"), name: "intranet.example.com"}}
+	if err := s.writeHosts(entries); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "127.0.0.1\tlocalhost\n# vpn-web tun0 begin\n10.0.0.7\tintranet.example.com\n# vpn-web tun0 end\n"
+	if string(data) != want {
+		t.Errorf("hosts = %q, erwartet %q", data, want)
+	}
+	if got := s.readHosts(); len(got) != 1 || got[0] != entries[0] {
+		t.Errorf("readHosts = %v", got)
+	}
+
+	if err := s.writeHosts(nil); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != "127.0.0.1\tlocalhost\n" {
+		t.Errorf("hosts nach dem Trennen = %q", data)
+	}
+}
+
+func TestParseTargetsRejects(t *testing.T) {
+	for _, arg := range []string{"%intranet.example.com", "10.0.0.0/33", "bad_host", "; rm -rf /"} {
+		if _, err := parseTargets([]string{arg}); err == nil {
+			t.Errorf("%q akzeptiert", arg)
+		}
+	}
+}
+
+func TestReadEnvRejects(t *testing.T) {
+	for name, value := range map[string]string{
+		"TUNDEV":               "tun0; reboot",
+		"VPNGATEWAY":           "gateway",
+		"INTERNAL_IP4_ADDRESS": "10.0.0.1/24",
+		"INTERNAL_IP4_DNS":     "10.0.0.1 $(id)",
+		"INTERNAL_IP4_MTU":     "100",
+	} {
+		env := testEnv("connect")
+		env[name] = value
+		if _, err := readEnv(func(k string) string { return env[k] }); err == nil {
+			t.Errorf("%s=%q akzeptiert", name, value)
+		}
+	}
+}
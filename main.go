@@ -6,10 +6,36 @@ import (
 	"os"
 	"path/filepath"
 	"vpn-web/internal/handlers"
+	"vpn-web/internal/helper"
 	"vpn-web/internal/vpn"
+	"vpn-web/internal/vpnc"
 )
 
 func main() {
+	// Privilegierter Helper (als root): vpn-web helper --user <name>
+	if len(os.Args) > 1 && os.Args[1] == "helper" {
+		if err := helper.Main(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Aufruf durch openconnect als vpnc-script (-s): Interface und Routen
+	if len(os.Args) > 1 && os.Args[1] == "vpnc-script" {
+		if err := vpnc.Main(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Aufruf durch openconnect als --external-browser (Anmeldung per SSO)
+	if handled, err := vpn.RunExternalBrowser(os.Args[1:]); handled {
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	vm := vpn.NewVPNManager()
 	h := handlers.NewHandlers(vm)
 
@@ -21,12 +47,30 @@ func main() {
 	// Routes
 	http.HandleFunc("/", h.IndexHandler)
 	http.HandleFunc("/status", h.StatusHandler)
+	http.HandleFunc("/events", h.EventsHandler)
+	http.HandleFunc("/logs", h.LogsHandler)
 	http.HandleFunc("/settings", h.SettingsHandler)
 	http.HandleFunc("/connect", h.ConnectHandler)
 	http.HandleFunc("/disconnect", h.DisconnectHandler)
+	http.HandleFunc("/servercert", h.ServerCertHandler)
+	http.HandleFunc("/certificates", h.CertificatesHandler)
+	http.HandleFunc("/csr", h.CSRHandler)
+	http.HandleFunc("/sso/callback", h.SSOCallbackHandler)
+	http.HandleFunc("/profiles", h.ProfilesHandler)
+	http.HandleFunc("/profiles/create", h.CreateProfileHandler)
+	http.HandleFunc("/profiles/clone", h.CloneProfileHandler)
+	http.HandleFunc("/profiles/rename", h.RenameProfileHandler)
+	http.HandleFunc("/profiles/delete", h.DeleteProfileHandler)
+	http.HandleFunc("/profiles/default", h.DefaultProfileHandler)
+	http.HandleFunc("/secrets", h.SecretsHandler)
+	http.HandleFunc("/secrets/unlock", h.UnlockSecretsHandler)
+	http.HandleFunc("/secrets/create", h.CreateSecretsHandler)
+	http.HandleFunc("/secrets/lock", h.LockSecretsHandler)
 
+	// Nur lokal erreichbar: über die Oberfläche lassen sich Kommandos
+	// hinterlegen, die beim Verbinden ausgeführt werden
 	log.Println("🔐 VPN Manager: http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	log.Fatal(http.ListenAndServe("127.0.0.1:8080", handlers.LocalOnly(http.DefaultServeMux)))
 }
 
 func getWebDir() string {
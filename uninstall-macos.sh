@@ -8,6 +8,7 @@ INSTALL_DIR="/usr/local/bin"
 WEB_INSTALL_DIR="/usr/local/share/vpn-web"
 SERVICE_NAME="com.vpnweb"
 PLIST_FILE="$HOME/Library/LaunchAgents/$SERVICE_NAME.plist"
+HELPER_PLIST="/Library/LaunchDaemons/$SERVICE_NAME.helper.plist"
 
 warn() { echo -e "\033[1;33m[WARNING]\033[0m $1"; }
 success() { echo -e "\033[0;32m[SUCCESS]\033[0m $1"; }
@@ -19,6 +20,7 @@ warn "This will remove:"
 echo "  • Binary: $INSTALL_DIR/$APP_NAME"
 echo "  • Web assets: $WEB_INSTALL_DIR"
 echo "  • LaunchAgent: $PLIST_FILE"
+echo "  • Helper: $HELPER_PLIST"
 echo "  • Configuration files (optional)"
 echo ""
 read -p "Continue? (y/N): " -n 1 -r
@@ -33,6 +35,14 @@ if launchctl list | grep -q "$SERVICE_NAME" 2>/dev/null; then
     success "Service stopped"
 fi
 
+# Helper stoppen
+if [[ -f "$HELPER_PLIST" ]]; then
+    sudo launchctl unload "$HELPER_PLIST" 2>/dev/null || true
+    sudo rm "$HELPER_PLIST"
+    sudo rm -f /var/run/vpn-web-helper.sock
+    success "Helper removed"
+fi
+
 # Dateien entfernen
 [[ -f "$PLIST_FILE" ]] && { rm "$PLIST_FILE"; success "LaunchAgent removed"; }
 [[ -f "$INSTALL_DIR/$APP_NAME" ]] && { sudo rm "$INSTALL_DIR/$APP_NAME"; success "Binary removed"; }
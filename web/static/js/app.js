@@ -35,9 +35,240 @@ function setButtonLoading(buttonId, loading) {
   }
 }
 
-function updateStatus() {
-  console.log("Updating status...");
+const STATE_LABELS = {
+  disconnected: "❌ Getrennt",
+  starting: "⏳ Wird gestartet...",
+  authenticating: "🔑 Authentifizierung...",
+  connected: "✅ Verbunden",
+  disconnecting: "⏳ Wird getrennt...",
+  reconnecting: "🔄 Verbindung wird wiederhergestellt...",
+  failed: "⚠️ Fehlgeschlagen",
+};
+
+const STATE_CLASSES = {
+  connected: "connected",
+  starting: "pending",
+  authenticating: "pending",
+  disconnecting: "pending",
+  reconnecting: "pending",
+  failed: "failed",
+};
+
+let lastState = null;
+
+function renderStatus(data) {
+  const status = document.getElementById("status");
+  if (!status) return;
+
+  const state = data.state || (data.connected ? "connected" : "disconnected");
+  let text = STATE_LABELS[state] || state;
+  if (data.profile_name && state !== "disconnected") {
+    text += ` (${data.profile_name})`;
+  }
+  if (data.reconnect) {
+    text += ` – Versuch ${data.reconnect.attempt}/${data.reconnect.max_attempts}`;
+    if (data.reconnect.next_attempt) {
+      text += ` um ${new Date(data.reconnect.next_attempt).toLocaleTimeString()}`;
+    }
+  }
+
+  status.textContent = text;
+  status.className = `status ${STATE_CLASSES[state] || "disconnected"}`;
+  status.title = data.last_error
+    ? `${data.last_error} – seit ${new Date(data.since).toLocaleTimeString()}`
+    : `seit ${new Date(data.since).toLocaleTimeString()}`;
+  if (data.last_exit) {
+    status.title += `\nLetztes Prozessende: ${data.last_exit.reason} (${new Date(
+      data.last_exit.time
+    ).toLocaleTimeString()})`;
+  }
+
+  renderServerCert(data.server_cert);
+  renderSSO(data.sso);
+  renderCertWarnings(data.cert_warnings);
+
+  // Fehler nur beim Wechsel in "failed" melden
+  if (state === "failed" && lastState !== null && lastState !== "failed") {
+    showToast("Verbindung fehlgeschlagen: " + data.last_error, "error");
+  }
+  lastState = state;
+}
+
+// Status aus /status oder einem "state"-Event übernehmen
+function applyStatus(data) {
+  isConnected = data.connected;
+  renderStatus(data);
+  const busy = ["starting", "authenticating", "reconnecting"].includes(
+    data.state
+  );
+
+  const connectBtn = document.getElementById("connect-btn");
+  const disconnectBtn = document.getElementById("disconnect-btn");
+
+  if (connectBtn && disconnectBtn) {
+    // Connect Button: Aktiviert wenn NICHT verbunden UND NICHT connecting/disconnecting
+    connectBtn.disabled =
+      isConnected || busy || isConnecting || isDisconnecting;
+
+    // Disconnect Button: Aktiviert wenn verbunden (oder im Aufbau) UND NICHT disconnecting
+    disconnectBtn.disabled =
+      (!isConnected && !busy) ||
+      isDisconnecting ||
+      data.state === "disconnecting";
+
+    // Loading-Klassen entfernen wenn nicht aktiv
+    if (!isConnecting) {
+      connectBtn.classList.remove("loading");
+    }
+    if (!isDisconnecting) {
+      disconnectBtn.classList.remove("loading");
+    }
+  }
+
+  if (!busy) {
+    setProgress("");
+  }
+}
+
+// Rückfrage zu einem unbekannten oder geänderten Server-Zertifikat
+let pendingServerCert = null;
 
+function renderServerCert(prompt) {
+  const box = document.getElementById("servercert-prompt");
+  if (!box) return;
+  pendingServerCert = prompt || null;
+  if (!prompt) {
+    box.style.display = "none";
+    return;
+  }
+
+  let text = prompt.previous
+    ? `⚠️ Das Server-Zertifikat von ${prompt.host} hat sich geändert! Bisher: ${prompt.previous}. Neuer Fingerprint:`
+    : `🔏 Unbekanntes Server-Zertifikat von ${prompt.host}. Fingerprint:`;
+  if (prompt.reason) {
+    text = `${text} (${prompt.reason})`;
+  }
+  document.getElementById("servercert-text").textContent = text;
+  document.getElementById("servercert-fingerprint").textContent =
+    prompt.fingerprint;
+  box.classList.toggle("changed", !!prompt.previous);
+  box.style.display = "";
+}
+
+function answerServerCert(action) {
+  const formData = new FormData();
+  formData.append("action", action);
+  const pending = action !== "forget" ? pendingServerCert : null;
+  formData.append("profile", pending ? pending.profile : currentProfile());
+  if (pending) {
+    formData.append("fingerprint", pending.fingerprint);
+  }
+
+  return fetch("/servercert", { method: "POST", body: formData })
+    .then((r) => r.json())
+    .then((data) => {
+      showToast(data.message, data.success ? "success" : "error");
+      return data.success;
+    });
+}
+
+// Gespeicherte Client-Zertifikate: zuordnen, löschen, aufräumen
+function certificateAction(action, fields) {
+  const formData = new FormData();
+  formData.append("action", action);
+  formData.append("profile", currentProfile());
+  Object.entries(fields || {}).forEach(([key, value]) =>
+    formData.append(key, value)
+  );
+
+  fetch("/certificates", { method: "POST", body: formData })
+    .then((r) => r.json())
+    .then((data) => {
+      showToast(data.message, data.success ? "success" : "error");
+      if (data.success) setTimeout(() => location.reload(), 1000);
+    });
+}
+
+// Zertifikatsanforderung: erzeugen, signiertes Zertifikat übernehmen, verwerfen
+function csrAction(action, fields) {
+  const formData = new FormData();
+  formData.append("action", action);
+  formData.append("profile", currentProfile());
+  Object.entries(fields || {}).forEach(([key, value]) =>
+    formData.append(key, value)
+  );
+
+  fetch("/csr", { method: "POST", body: formData })
+    .then((r) => r.json())
+    .then((data) => {
+      showToast(data.message, data.success ? "success" : "error");
+      if (data.success) setTimeout(() => location.reload(), 1000);
+    });
+}
+
+// Laufende Anmeldung per Browser (SAML/SSO)
+let pendingSSO = null;
+
+function renderSSO(login) {
+  const box = document.getElementById("sso-prompt");
+  if (!box) return;
+  pendingSSO = login || null;
+  if (!login) {
+    box.style.display = "none";
+    return;
+  }
+
+  const link = document.getElementById("sso-link");
+  if (login.url) {
+    link.href = login.url;
+    link.style.display = "";
+    document.getElementById("sso-text").textContent =
+      "🌐 Bitte im Browser beim Identity Provider anmelden. Danach wird der Tunnel automatisch gestartet.";
+  } else {
+    link.style.display = "none";
+    document.getElementById("sso-text").textContent =
+      "🌐 Warte auf die Login-Seite des Gateways...";
+  }
+  box.style.display = "";
+}
+
+function submitSSOCookie() {
+  const input = document.getElementById("sso-cookie");
+  if (!pendingSSO || !input.value.trim()) return;
+
+  const formData = new FormData();
+  formData.append("action", "cookie");
+  formData.append("profile", pendingSSO.profile);
+  formData.append("cookie", input.value.trim());
+
+  fetch("/sso/callback", { method: "POST", body: formData })
+    .then((r) => r.json())
+    .then((data) => {
+      showToast(data.message, data.success ? "success" : "error");
+      if (data.success) input.value = "";
+    });
+}
+
+// Client-Zertifikate, die bald ablaufen oder abgelaufen sind
+function renderCertWarnings(warnings) {
+  const box = document.getElementById("cert-warnings");
+  if (!box) return;
+  box.replaceChildren();
+  (warnings || []).forEach((warning) => {
+    const p = document.createElement("p");
+    p.textContent = `⚠️ ${warning.message}`;
+    p.classList.toggle("expired", warning.days_left < 0);
+    box.appendChild(p);
+  });
+  box.style.display = box.childElementCount ? "" : "none";
+}
+
+function setProgress(message) {
+  const progress = document.getElementById("progress");
+  if (progress) progress.textContent = message;
+}
+
+function updateStatus() {
   fetch("/status")
     .then((r) => {
       if (!r.ok) throw new Error(`HTTP ${r.status}`);
@@ -45,46 +276,7 @@ function updateStatus() {
     })
     .then((data) => {
       console.log("Status response:", data);
-
-      // Status aktualisieren
-      isConnected = data.connected;
-
-      const status = document.getElementById("status");
-      if (status) {
-        status.textContent = data.connected ? "✅ Verbunden" : "❌ Getrennt";
-        status.className = `status ${
-          data.connected ? "connected" : "disconnected"
-        }`;
-      }
-
-      // Buttons direkt hier aktualisieren - NICHT in separater Funktion
-      const connectBtn = document.getElementById("connect-btn");
-      const disconnectBtn = document.getElementById("disconnect-btn");
-
-      if (connectBtn && disconnectBtn) {
-        // Connect Button: Aktiviert wenn NICHT verbunden UND NICHT connecting/disconnecting
-        connectBtn.disabled = isConnected || isConnecting || isDisconnecting;
-
-        // Disconnect Button: Aktiviert wenn verbunden UND NICHT disconnecting
-        disconnectBtn.disabled = !isConnected || isDisconnecting;
-
-        // Loading-Klassen entfernen wenn nicht aktiv
-        if (!isConnecting) {
-          connectBtn.classList.remove("loading");
-        }
-        if (!isDisconnecting) {
-          disconnectBtn.classList.remove("loading");
-        }
-
-        console.log(
-          `Buttons updated - Connect: ${
-            connectBtn.disabled ? "disabled" : "enabled"
-          }, Disconnect: ${disconnectBtn.disabled ? "disabled" : "enabled"}`
-        );
-        console.log(
-          `States - Connected: ${isConnected}, Connecting: ${isConnecting}, Disconnecting: ${isDisconnecting}`
-        );
-      }
+      applyStatus(data);
     })
     .catch((err) => {
       console.error("Status update failed:", err);
@@ -92,6 +284,55 @@ function updateStatus() {
     });
 }
 
+// Live-Updates per Server-Sent Events. Der Browser verbindet sich nach
+// Abbrüchen selbst neu und sendet Last-Event-ID; nur wenn die Verbindung
+// endgültig geschlossen wurde, wird sie hier neu aufgebaut.
+let eventSource = null;
+let lastEventId = "";
+
+function subscribeEvents() {
+  if (!window.EventSource) {
+    setInterval(updateStatus, 5000);
+    return;
+  }
+
+  const url = lastEventId
+    ? "/events?last_event_id=" + encodeURIComponent(lastEventId)
+    : "/events";
+  eventSource = new EventSource(url);
+
+  const handle = (handler) => (e) => {
+    if (e.lastEventId) lastEventId = e.lastEventId;
+    handler(JSON.parse(e.data));
+  };
+
+  eventSource.addEventListener(
+    "state",
+    handle((ev) => applyStatus(ev.data))
+  );
+  eventSource.addEventListener(
+    "progress",
+    handle((ev) => setProgress(ev.data))
+  );
+  eventSource.addEventListener(
+    "log",
+    handle((ev) => appendLogEntry(ev.data))
+  );
+
+  eventSource.onopen = () => setLive(true);
+  eventSource.onerror = () => {
+    setLive(false);
+    if (eventSource.readyState === EventSource.CLOSED) {
+      setTimeout(subscribeEvents, 3000);
+    }
+  };
+}
+
+function setLive(live) {
+  const status = document.getElementById("status");
+  if (status) status.classList.toggle("offline", !live);
+}
+
 function connectVPN() {
   console.log("Connect VPN clicked");
 
@@ -113,7 +354,10 @@ function connectVPN() {
     connectBtn.classList.add("loading");
   }
 
-  fetch("/connect", { method: "POST" })
+  const formData = new FormData();
+  formData.append("profile", currentProfile());
+
+  fetch("/connect", { method: "POST", body: formData })
     .then((r) => {
       if (!r.ok) throw new Error(`HTTP ${r.status}`);
       return r.json();
@@ -126,27 +370,7 @@ function connectVPN() {
         data.success ? 5000 : 10000
       );
 
-      if (data.success) {
-        // Häufigere Status-Updates für die nächsten 60 Sekunden
-        let pollCount = 0;
-        const maxPolls = 30; // 30 * 2s = 60s
-
-        const pollInterval = setInterval(() => {
-          updateStatus();
-          pollCount++;
-
-          if (pollCount >= maxPolls || isConnected) {
-            clearInterval(pollInterval);
-            console.log("Stopped frequent polling");
-          }
-        }, 2000);
-
-        showToast(
-          "Verbindung wird aufgebaut... Bitte warten Sie bis zu 60 Sekunden.",
-          "info",
-          5000
-        );
-      }
+      // Fortschritt und Ergebnis kommen über /events
     })
     .catch((err) => {
       console.error("Connect failed:", err);
@@ -155,15 +379,14 @@ function connectVPN() {
     .finally(() => {
       isConnecting = false;
       console.log("Connect operation finished");
-      // Status nach kurzer Verzögerung aktualisieren
-      setTimeout(updateStatus, 2000);
+      updateStatus();
     });
 }
 
 function disconnectVPN() {
   console.log("Disconnect VPN clicked");
 
-  if (isDisconnecting || !isConnected) {
+  if (isDisconnecting || (!isConnected && lastState === "disconnected")) {
     console.log("Disconnect blocked:", { isDisconnecting, isConnected });
     return;
   }
@@ -189,11 +412,6 @@ function disconnectVPN() {
         data.success ? "success" : "error",
         data.success ? 5000 : 10000
       );
-
-      // Status mehrfach aktualisieren
-      setTimeout(updateStatus, 500);
-      setTimeout(updateStatus, 2000);
-      setTimeout(updateStatus, 5000);
     })
     .catch((err) => {
       console.error("Disconnect failed:", err);
@@ -202,12 +420,26 @@ function disconnectVPN() {
     .finally(() => {
       isDisconnecting = false;
       console.log("Disconnect operation finished, updating status...");
-      setTimeout(updateStatus, 1000);
+      updateStatus();
     });
 }
 
 function validateForm() {
-  const requiredFields = ["vpn_server", "auth_group", "username"];
+  const requiredFields = {
+    openconnect: document.getElementById("sso").checked
+      ? ["vpn_server"]
+      : ["vpn_server", "username"],
+    wireguard: [],
+    openvpn: [],
+  }[selectedBackend()];
+  const protocol = selectedProtocol();
+  if (
+    selectedBackend() === "openconnect" &&
+    protocol &&
+    protocol.dataset.authgroupRequired === "true"
+  ) {
+    requiredFields.push("auth_group");
+  }
   const missingFields = [];
 
   requiredFields.forEach((fieldId) => {
@@ -232,28 +464,109 @@ function saveSettings() {
     return;
   }
 
+  // Geänderte Passwort-Kommandos laufen beim Verbinden als eigener Prozess
+  const changedCommands = [
+    "vpn_password_command",
+    "cert_password_command",
+    "sudo_password_command",
+  ]
+    .map((id) => document.getElementById(id))
+    .filter((input) => input.value.trim() && input.value !== input.defaultValue)
+    .map((input) => input.value.trim());
+  if (
+    changedCommands.length > 0 &&
+    !confirm(
+      "Diese Kommandos werden beim Verbinden ausgeführt:\n\n" +
+        changedCommands.join("\n") +
+        "\n\nNur speichern, wenn die Änderung von Ihnen stammt."
+    )
+  ) {
+    return;
+  }
+
   setButtonLoading("save-btn", true);
 
   const formData = new FormData();
 
   // Basis-Einstellungen
+  formData.append("profile", currentProfile());
+  formData.append("type", selectedBackend());
+  formData.append("protocol", document.getElementById("protocol").value);
+  if (selectedBackend() === "wireguard") {
+    formData.append(
+      "wireguard_config",
+      document.getElementById("wireguard_config").value
+    );
+  }
+  if (selectedBackend() === "openvpn") {
+    formData.append(
+      "openvpn_config",
+      document.getElementById("openvpn_config").value
+    );
+  }
   formData.append("vpn_server", document.getElementById("vpn_server").value);
   formData.append("auth_group", document.getElementById("auth_group").value);
   formData.append("username", document.getElementById("username").value);
-  formData.append("networks", document.getElementById("networks").value);
+  formData.append("sso", document.getElementById("sso").checked ? "1" : "0");
+  formData.append(
+    "pin_server_cert",
+    document.getElementById("pin_server_cert").checked ? "1" : "0"
+  );
+  formData.append(
+    "routes_include",
+    document.getElementById("routes_include").value
+  );
+  formData.append(
+    "routes_exclude",
+    document.getElementById("routes_exclude").value
+  );
+  formData.append("dns_domains", document.getElementById("dns_domains").value);
+  formData.append(
+    "reconnect_attempts",
+    document.getElementById("reconnect_attempts").value
+  );
+  formData.append(
+    "cert_warn_days",
+    document.getElementById("cert_warn_days").value
+  );
 
   // Passwörter (nur wenn eingegeben)
   const password = document.getElementById("password").value;
   const certPassword = document.getElementById("cert_password").value;
   const sudoPassword = document.getElementById("sudo_password").value;
 
+  // Kommandos immer senden, damit leere Felder das Kommando entfernen
+  ["vpn_password", "cert_password", "sudo_password"].forEach((prefix) => {
+    formData.append(
+      prefix + "_command",
+      document.getElementById(prefix + "_command").value
+    );
+    formData.append(
+      prefix + "_timeout",
+      document.getElementById(prefix + "_timeout").value
+    );
+  });
+
   if (password) formData.append("password", password);
   if (certPassword) formData.append("cert_password", certPassword);
   if (sudoPassword) formData.append("sudo_password", sudoPassword);
 
+  // TOTP-Schlüssel (zweiter Faktor)
+  const totpSeed = document.getElementById("totp_seed").value;
+  const totpRemove = document.getElementById("totp_remove");
+  if (totpRemove && totpRemove.checked) {
+    formData.append("totp_remove", "1");
+  } else if (totpSeed) {
+    formData.append("totp_seed", totpSeed);
+  }
+
   // Zertifikat-Upload
   const certFile = document.getElementById("certificate").files[0];
   if (certFile) formData.append("certificate", certFile);
+  const keyFile = document.getElementById("private_key").files[0];
+  if (keyFile) formData.append("private_key", keyFile);
+  const caFile = document.getElementById("ca_bundle").files[0];
+  if (caFile) formData.append("ca_bundle", caFile);
 
   fetch("/settings", {
     method: "POST",
@@ -275,8 +588,9 @@ function saveSettings() {
         document.getElementById("password").value = "";
         document.getElementById("cert_password").value = "";
         document.getElementById("sudo_password").value = "";
+        document.getElementById("totp_seed").value = "";
 
-        showToast("Passwörter sicher in Keychain gespeichert! 🔐", "success");
+        showToast("Passwörter sicher gespeichert! 🔐", "success");
         setTimeout(() => location.reload(), 2000);
       }
     })
@@ -287,6 +601,213 @@ function saveSettings() {
     });
 }
 
+function currentProfile() {
+  const select = document.getElementById("profile-select");
+  return select ? select.value : "";
+}
+
+function selectProfile(profileId) {
+  location.href = "/?profile=" + encodeURIComponent(profileId);
+}
+
+// Profil-Aktionen: POST an /profiles/<action>, danach Seite neu laden
+function profileAction(action, fields, reloadTo) {
+  const formData = new FormData();
+  formData.append("profile", currentProfile());
+  Object.entries(fields || {}).forEach(([key, value]) =>
+    formData.append(key, value)
+  );
+
+  return fetch("/profiles/" + action, { method: "POST", body: formData })
+    .then((r) => {
+      if (!r.ok) throw new Error(`HTTP ${r.status}`);
+      return r.json();
+    })
+    .then((data) => {
+      showToast(data.message, data.success ? "success" : "error");
+      if (data.success) {
+        const target = data.profile ? data.profile.id : reloadTo;
+        setTimeout(() => selectProfile(target || currentProfile()), 1000);
+      }
+    })
+    .catch((err) => {
+      console.error(`Profile ${action} failed:`, err);
+      showToast("Profil-Fehler: " + err.message, "error");
+    });
+}
+
+function createProfile() {
+  const name = prompt("Name des neuen Profils:");
+  if (name) profileAction("create", { name });
+}
+
+function cloneProfile() {
+  const select = document.getElementById("profile-select");
+  const current = select.options[select.selectedIndex].text.replace("★", "");
+  const name = prompt("Name der Kopie:", current.trim() + " (Kopie)");
+  if (name) profileAction("clone", { name });
+}
+
+function renameProfile() {
+  const name = prompt("Neuer Profilname:");
+  if (name) profileAction("rename", { name });
+}
+
+function deleteProfile() {
+  if (confirm("Profil inkl. gespeicherter Passwörter wirklich löschen?")) {
+    profileAction("delete", {}, "");
+  }
+}
+
+function setDefaultProfile() {
+  profileAction("default");
+}
+
+function unlockSecrets() {
+  const input = document.getElementById("secret-passphrase");
+  if (!input || !input.value) {
+    showToast("Bitte Master-Passphrase eingeben", "error");
+    return;
+  }
+
+  const formData = new FormData();
+  formData.append("passphrase", input.value);
+  secretStoreAction("unlock", formData);
+  input.value = "";
+}
+
+function createSecrets() {
+  const input = document.getElementById("secret-passphrase");
+  const confirmInput = document.getElementById("secret-passphrase-confirm");
+  if (!input.value) {
+    showToast("Bitte Master-Passphrase eingeben", "error");
+    return;
+  }
+  if (input.value !== confirmInput.value) {
+    showToast("Passphrasen stimmen nicht überein", "error");
+    return;
+  }
+
+  const formData = new FormData();
+  formData.append("passphrase", input.value);
+  formData.append("confirm", confirmInput.value);
+  secretStoreAction("create", formData);
+  input.value = "";
+  confirmInput.value = "";
+}
+
+function lockSecrets() {
+  secretStoreAction("lock", new FormData());
+}
+
+function secretStoreAction(action, formData) {
+  fetch("/secrets/" + action, { method: "POST", body: formData })
+    .then((r) => {
+      if (!r.ok) throw new Error(`HTTP ${r.status}`);
+      return r.json();
+    })
+    .then((data) => {
+      showToast(data.message, data.success ? "success" : "error");
+      if (data.success) setTimeout(() => location.reload(), 1000);
+    })
+    .catch((err) => {
+      console.error(`Secret store ${action} failed:`, err);
+      showToast("Passwort-Speicher: " + err.message, "error");
+    });
+}
+
+// Protokoll-Panel: lädt /logs mit Filtern, neue Zeilen kommen über /events
+const LOG_LEVEL_RANK = { info: 0, warn: 1, error: 2 };
+let displayedLogSession = null;
+
+function logQuery() {
+  const params = new URLSearchParams();
+  const session = document.getElementById("log-session").value;
+  const level = document.getElementById("log-level").value;
+  const source = document.getElementById("log-source").value;
+  if (session) params.set("session", session);
+  if (level) params.set("level", level);
+  if (source) params.set("source", source);
+  return params;
+}
+
+function loadLogs() {
+  const params = logQuery();
+  const download = document.getElementById("log-download");
+  const textParams = new URLSearchParams(params);
+  textParams.set("format", "text");
+  download.href = "/logs?" + textParams.toString();
+
+  fetch("/logs?" + params.toString())
+    .then((r) => {
+      if (!r.ok) throw new Error(`HTTP ${r.status}`);
+      return r.json();
+    })
+    .then((data) => {
+      renderLogSessions(data.sessions);
+      displayedLogSession = data.sessions.length
+        ? data.sessions[data.sessions.length - 1].id
+        : null;
+      const output = document.getElementById("log-output");
+      output.innerHTML = "";
+      data.entries.forEach((entry) => appendLogEntry(entry, true));
+    })
+    .catch((err) => {
+      console.error("Loading logs failed:", err);
+      showToast("Protokoll konnte nicht geladen werden: " + err.message, "error");
+    });
+}
+
+function renderLogSessions(sessions) {
+  const select = document.getElementById("log-session");
+  const selected = select.value;
+  select.innerHTML = "";
+  select.appendChild(new Option("Aktuelle Sitzung", ""));
+  sessions
+    .slice()
+    .reverse()
+    .forEach((s) => {
+      const label = `#${s.id} ${s.profile || "vor Verbindung"} – ${new Date(
+        s.started
+      ).toLocaleString()}`;
+      select.appendChild(new Option(label, String(s.id)));
+    });
+  select.appendChild(new Option("Alle Sitzungen", "all"));
+  select.value = selected;
+}
+
+function appendLogEntry(entry, force) {
+  const panel = document.getElementById("logs-panel");
+  const output = document.getElementById("log-output");
+  if (!output || (!force && !panel.classList.contains("active"))) return;
+
+  // Live-Zeilen nur anzeigen, wenn sie zu den gewählten Filtern passen
+  if (!force) {
+    const session = document.getElementById("log-session").value;
+    const level = document.getElementById("log-level").value;
+    const source = document.getElementById("log-source").value;
+    if (session && session !== "all") return;
+    if (!session && entry.session !== displayedLogSession) {
+      // Neue Sitzung begonnen: Ansicht neu laden
+      loadLogs();
+      return;
+    }
+    if (level && LOG_LEVEL_RANK[entry.level] < LOG_LEVEL_RANK[level]) return;
+    if (source && entry.source !== source) return;
+  }
+
+  const line = document.createElement("span");
+  line.className = entry.level;
+  line.textContent = `${new Date(entry.time).toLocaleTimeString()} ${
+    entry.source
+  }: ${entry.message}\n`;
+
+  const atBottom =
+    output.scrollTop + output.clientHeight >= output.scrollHeight - 20;
+  output.appendChild(line);
+  if (atBottom) output.scrollTop = output.scrollHeight;
+}
+
 function togglePanel(toggleId, panelId) {
   const toggle = document.getElementById(toggleId);
   const panel = document.getElementById(panelId);
@@ -309,6 +830,46 @@ function forceUpdateButtons() {
   }
 }
 
+// Verbindungstyp: nur die passenden Felder anzeigen
+function selectedBackend() {
+  const type = document.getElementById("type");
+  return type ? type.value : "openconnect";
+}
+
+function showBackendFields() {
+  const backend = selectedBackend();
+  document
+    .querySelectorAll(
+      ".backend-openconnect, .backend-wireguard, .backend-openvpn"
+    )
+    .forEach((el) => {
+      el.style.display = el.classList.contains("backend-" + backend)
+        ? ""
+        : "none";
+    });
+}
+
+// openconnect-Protokoll: Gruppen-/Realm-Feld je nach Protokoll benennen
+// oder ausblenden
+function selectedProtocol() {
+  const select = document.getElementById("protocol");
+  return select ? select.options[select.selectedIndex] : null;
+}
+
+function showProtocolFields() {
+  const protocol = selectedProtocol();
+  if (!protocol) return;
+  const authGroup = protocol.dataset.authgroup;
+  document.getElementById("auth_group_field").style.display = authGroup
+    ? ""
+    : "none";
+  if (authGroup) {
+    document.getElementById("auth_group_label").textContent =
+      authGroup +
+      (protocol.dataset.authgroupRequired === "true" ? ":" : " (optional):");
+  }
+}
+
 // Event Listeners
 document.addEventListener("DOMContentLoaded", () => {
   console.log("DOM loaded, setting up event listeners");
@@ -337,11 +898,167 @@ document.addEventListener("DOMContentLoaded", () => {
       togglePanel("settings-toggle", "settings-panel");
   }
 
-  // Initial status update
+  const typeSelect = document.getElementById("type");
+  if (typeSelect) {
+    typeSelect.onchange = showBackendFields;
+    showBackendFields();
+  }
+
+  const certAcceptBtn = document.getElementById("servercert-accept-btn");
+  if (certAcceptBtn) {
+    certAcceptBtn.onclick = () =>
+      answerServerCert("accept").then((ok) => {
+        if (ok) connectVPN();
+      });
+  }
+  const certRejectBtn = document.getElementById("servercert-reject-btn");
+  if (certRejectBtn) {
+    certRejectBtn.onclick = () => answerServerCert("reject");
+  }
+  const certForget = document.getElementById("servercert-forget");
+  if (certForget) {
+    certForget.onclick = (e) => {
+      e.preventDefault();
+      if (!confirm("Gespeichertes Server-Zertifikat wirklich zurücksetzen?")) {
+        return;
+      }
+      answerServerCert("forget").then((ok) => {
+        if (ok) document.getElementById("servercert-pinned").remove();
+      });
+    };
+  }
+
+  document.querySelectorAll(".cert-select-btn").forEach((btn) => {
+    btn.onclick = () => certificateAction("select", { id: btn.dataset.id });
+  });
+  document.querySelectorAll(".cert-unselect").forEach((el) => {
+    el.onclick = (e) => {
+      e.preventDefault();
+      certificateAction("unselect", { kind: el.dataset.kind });
+    };
+  });
+  document.querySelectorAll(".cert-delete-btn").forEach((btn) => {
+    btn.onclick = () => {
+      if (confirm("Zertifikat wirklich löschen?")) {
+        certificateAction("delete", { id: btn.dataset.id });
+      }
+    };
+  });
+  const certPruneBtn = document.getElementById("cert-prune-btn");
+  if (certPruneBtn) {
+    certPruneBtn.onclick = () => {
+      if (confirm("Alle Zertifikate löschen, die kein Profil verwendet?")) {
+        certificateAction("prune");
+      }
+    };
+  }
+
+  const csrGenerateBtn = document.getElementById("csr-generate-btn");
+  if (csrGenerateBtn) {
+    csrGenerateBtn.onclick = () => {
+      const fields = { key_type: document.getElementById("csr_key_type").value };
+      ["cn", "o", "ou", "l", "st", "c", "email"].forEach((name) => {
+        fields[name] = document.getElementById("csr_" + name).value;
+      });
+      csrAction("generate", fields);
+    };
+  }
+  const csrImportBtn = document.getElementById("csr-import-btn");
+  if (csrImportBtn) {
+    csrImportBtn.onclick = () => {
+      const file = document.getElementById("csr_certificate").files[0];
+      if (!file) {
+        showToast("Bitte das signierte Zertifikat auswählen", "error");
+        return;
+      }
+      csrAction("import", { certificate: file });
+    };
+  }
+  const csrCancelBtn = document.getElementById("csr-cancel-btn");
+  if (csrCancelBtn) {
+    csrCancelBtn.onclick = () => {
+      if (confirm("Anforderung und zugehörigen Schlüssel verwerfen?")) {
+        csrAction("cancel");
+      }
+    };
+  }
+
+  const ssoCookieBtn = document.getElementById("sso-cookie-btn");
+  if (ssoCookieBtn) {
+    ssoCookieBtn.onclick = submitSSOCookie;
+  }
+
+  const protocolSelect = document.getElementById("protocol");
+  if (protocolSelect) {
+    protocolSelect.onchange = showProtocolFields;
+    showProtocolFields();
+  }
+
+  // .ovpn-Datei in das Textfeld übernehmen (gespeichert wird mit "Speichern")
+  const openvpnFile = document.getElementById("openvpn_file");
+  if (openvpnFile) {
+    openvpnFile.onchange = () => {
+      const file = openvpnFile.files[0];
+      if (file) {
+        file.text().then((text) => {
+          document.getElementById("openvpn_config").value = text;
+        });
+      }
+    };
+  }
+
+  const logsToggle = document.getElementById("logs-toggle");
+  if (logsToggle) {
+    logsToggle.onclick = () => {
+      togglePanel("logs-toggle", "logs-panel");
+      if (document.getElementById("logs-panel").classList.contains("active")) {
+        loadLogs();
+      }
+    };
+    ["log-session", "log-level", "log-source"].forEach((id) => {
+      document.getElementById(id).onchange = loadLogs;
+    });
+  }
+
+  const profileSelect = document.getElementById("profile-select");
+  if (profileSelect) {
+    profileSelect.onchange = () => selectProfile(profileSelect.value);
+  }
+
+  const unlockBtn = document.getElementById("secret-unlock-btn");
+  if (unlockBtn) unlockBtn.onclick = unlockSecrets;
+  const createBtn = document.getElementById("secret-create-btn");
+  if (createBtn) createBtn.onclick = createSecrets;
+  const passphraseSubmit = createBtn ? createSecrets : unlockSecrets;
+  ["secret-passphrase", "secret-passphrase-confirm"].forEach((id) => {
+    const input = document.getElementById(id);
+    if (input) {
+      input.onkeydown = (e) => {
+        if (e.key === "Enter") passphraseSubmit();
+      };
+    }
+  });
+  const lockBtn = document.getElementById("secret-lock-btn");
+  if (lockBtn) lockBtn.onclick = lockSecrets;
+
+  const profileButtons = {
+    "profile-new-btn": createProfile,
+    "profile-clone-btn": cloneProfile,
+    "profile-rename-btn": renameProfile,
+    "profile-default-btn": setDefaultProfile,
+    "profile-delete-btn": deleteProfile,
+  };
+  Object.entries(profileButtons).forEach(([id, handler]) => {
+    const btn = document.getElementById(id);
+    if (btn) btn.onclick = handler;
+  });
+
+  // Initial status update, danach Live-Updates über /events
   updateStatus();
+  subscribeEvents();
 
-  // Periodic status updates
-  setInterval(updateStatus, 5000);
+  // Seltener Abgleich, um extern gestartete/beendete Verbindungen zu erkennen
+  setInterval(updateStatus, 30000);
 
   // Debug: Button-Status alle 10 Sekunden loggen
   setInterval(() => {